@@ -0,0 +1,82 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/spf13/cobra"
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/cache"
+    "github.com/AlexBurnes/pre-push/internal/repo"
+)
+
+// isCacheEnabled checks if step results may be taken from the cache
+func isCacheEnabled() bool {
+    switch strings.ToLower(os.Getenv("PRE_PUSH_CACHE")) {
+    case "0", "false", "no", "off":
+        return false
+    }
+    return true
+}
+
+// openCache opens the step result cache in .git/pre-push/cache
+func openCache(ctx context.Context) (*cache.Cache, error) {
+    stateDir, err := repo.StateDir(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return cache.New(filepath.Join(stateDir, "cache")), nil
+}
+
+// enableCache sets up the step result cache of an executor if caching is enabled
+func enableCache(ctx context.Context, executor *preexec.BuildfabExecutor) {
+    if !isCacheEnabled() {
+        return
+    }
+    stepCache, err := openCache(ctx)
+    if err != nil {
+        if isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Step cache disabled: %v\n", err)
+        }
+        return
+    }
+    executor.SetCache(stepCache)
+}
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+    Use:   "cache",
+    Short: "Manage the step result cache",
+    Long: `Manage the step result cache in .git/pre-push/cache.
+
+Successful steps are cached by the tree hash (or the hash of the action's
+'paths' option), the action's run text, the variables it references and
+the versions of the tools listed in its 'tools' option. Steps with a cached
+result are reported as cached and not executed again.`,
+}
+
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+    Use:   "clear",
+    Short: "Remove all cached step results",
+    RunE:  runCacheClear,
+}
+
+// runCacheClear removes all cached step results
+func runCacheClear(cmd *cobra.Command, args []string) error {
+    stepCache, err := openCache(cmd.Context())
+    if err != nil {
+        return err
+    }
+    
+    count, err := stepCache.Clear()
+    if err != nil {
+        return err
+    }
+    
+    fmt.Printf("Removed %d cached step results\n", count)
+    return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/repo"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+    Use:   "config",
+    Short: "Inspect the configuration",
+    Long: `Inspect the configuration the hook runs.
+
+The project configuration is merged with layers, later layers taking
+precedence: /etc/pre-push/config.yml, ~/.config/pre-push/config.yml and
+.project.local.yml next to the project configuration. The system layer is
+machine-wide policy and overrides the project configuration. Layers add
+secrets but never remove them.`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+    Use:   "show",
+    Short: "Print the configuration",
+    Long: `Print the project configuration with includes merged. With --resolved the
+layers are merged as well and every setting, action, stage and step is
+annotated with the file that set it.`,
+    Args: cobra.NoArgs,
+    RunE: runConfigShow,
+}
+
+// configMigrateCmd represents the config migrate command
+var configMigrateCmd = &cobra.Command{
+    Use:   "migrate",
+    Short: "Rewrite the configuration to the current schema",
+    Long: `Rewrite the project configuration and the files it includes from an older
+schema version to the current one and set its schema field. Comments and the
+order of keys are kept.
+
+The changes are shown as a diff and written after confirmation. Use --yes to
+write them without asking and --dry-run to only show them.`,
+    Args: cobra.NoArgs,
+    RunE: runConfigMigrate,
+}
+
+// userConfigDir returns the directory of the user-level pre-push configuration
+// ($XDG_CONFIG_HOME/pre-push or ~/.config/pre-push)
+func userConfigDir() string {
+    if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+        return filepath.Join(dir, appName)
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(home, ".config", appName)
+}
+
+// getConfigOverride returns the configuration file set by --config or
+// PRE_PUSH_CONFIG, the flag taking precedence
+func getConfigOverride() string {
+    if configFile != "" {
+        return configFile
+    }
+    return os.Getenv("PRE_PUSH_CONFIG")
+}
+
+// findConfig returns the configuration to run: the file set by --config or
+// PRE_PUSH_CONFIG, a project configuration in the repository root, or the
+// user default configuration in repositories without one. The working
+// directory changes to the repository root, so commands started from a
+// subdirectory run the way the hook does. It returns an empty path if no
+// configuration exists.
+func findConfig(ctx context.Context) (string, error) {
+    override := getConfigOverride()
+    if override != "" {
+        abs, err := filepath.Abs(override)
+        if err != nil {
+            return "", fmt.Errorf("failed to resolve configuration path: %w", err)
+        }
+        override = abs
+    }
+    
+    if topLevel, err := repo.TopLevel(ctx); err == nil {
+        if err := os.Chdir(topLevel); err != nil {
+            return "", fmt.Errorf("failed to change to repository root: %w", err)
+        }
+    }
+    
+    configPath, err := config.Discover(".", override)
+    if err != nil || configPath != "" {
+        return configPath, err
+    }
+    
+    if dir := userConfigDir(); dir != "" {
+        defaultConfig := filepath.Join(dir, userDefaultConfigFile)
+        if _, err := os.Stat(defaultConfig); err == nil {
+            return defaultConfig, nil
+        }
+    }
+    return "", nil
+}
+
+// configLayers returns the layers merged over a configuration, lowest
+// precedence first: the system layer, the user layer and the local layer
+// next to the configuration (.project.local.yml). The system layer is
+// machine-wide policy, so it overrides the committed configuration.
+func configLayers(configPath string) []string {
+    layers := []string{systemLayerFile}
+    if dir := userConfigDir(); dir != "" {
+        layers = append(layers, filepath.Join(dir, userLayerFile))
+    }
+    return append(layers, config.LocalConfigPath(configPath))
+}
+
+// loadProject loads a configuration with its layers
+func loadProject(configPath string) (*config.Project, error) {
+    return config.LoadLayered(configPath, configLayers(configPath))
+}
+
+// describeConfig returns the configuration file with the layers merged over it
+func describeConfig(project *config.Project) string {
+    if len(project.Layers) == 0 {
+        return project.Path
+    }
+    return fmt.Sprintf("%s with %s", project.Path, strings.Join(project.Layers, ", "))
+}
+
+// loadConfig finds and loads the configuration for commands that require one
+func loadConfig(ctx context.Context) (*config.Project, error) {
+    configPath, err := findConfig(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if configPath == "" {
+        return nil, fmt.Errorf("no configuration file found (tried %s)", strings.Join(config.ConfigFiles, ", "))
+    }
+    return loadProject(configPath)
+}
+
+// runConfigShow prints the configuration, resolved with its layers and origins
+func runConfigShow(cmd *cobra.Command, args []string) error {
+    ctx := context.Background()
+    configPath, err := findConfig(ctx)
+    if err != nil {
+        return err
+    }
+    if configPath == "" {
+        return fmt.Errorf("no configuration file found (tried %s)", strings.Join(config.ConfigFiles, ", "))
+    }
+    
+    resolved, _ := cmd.Flags().GetBool("resolved")
+    var project *config.Project
+    if resolved {
+        project, err = loadProject(configPath)
+    } else {
+        project, err = config.LoadProject(configPath)
+    }
+    if err != nil {
+        return err
+    }
+    
+    output, err := project.Render(resolved)
+    if err != nil {
+        return fmt.Errorf("failed to render configuration: %w", err)
+    }
+    fmt.Print(string(output))
+    return nil
+}
+
+// runConfigMigrate rewrites the configuration to the current schema after
+// showing the changes
+func runConfigMigrate(cmd *cobra.Command, args []string) error {
+    configPath, err := findConfig(cmd.Context())
+    if err != nil {
+        return err
+    }
+    if configPath == "" {
+        return fmt.Errorf("no configuration file found (tried %s)", strings.Join(config.ConfigFiles, ", "))
+    }
+    
+    cmd.SilenceUsage = true
+    migrations, err := config.MigrateProject(configPath)
+    if err != nil {
+        return err
+    }
+    var changed []*config.Migration
+    for _, migration := range migrations {
+        if migration.Changed() {
+            changed = append(changed, migration)
+        }
+    }
+    if len(changed) == 0 {
+        fmt.Printf("%s is at schema %d, nothing to migrate\n", configPath, prepush.SchemaVersion)
+        return nil
+    }
+    
+    for _, migration := range changed {
+        fmt.Printf("%s: schema %d to %d\n", migration.Path, migration.From, prepush.SchemaVersion)
+        for _, change := range migration.Changes {
+            fmt.Printf("  - %s\n", change)
+        }
+        fmt.Println()
+        fmt.Print(config.UnifiedDiff(migration.Path, migration.Original, migration.Migrated))
+        fmt.Println()
+    }
+    
+    if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+        return nil
+    }
+    if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+        confirmed, err := confirm("Write the changes?")
+        if err != nil {
+            return err
+        }
+        if !confirmed {
+            fmt.Println("Configuration not changed")
+            return nil
+        }
+    }
+    
+    for _, migration := range changed {
+        mode := os.FileMode(0644)
+        if info, err := os.Stat(migration.Path); err == nil {
+            mode = info.Mode().Perm()
+        }
+        if err := os.WriteFile(migration.Path, migration.Migrated, mode); err != nil {
+            return fmt.Errorf("failed to write %s: %w", migration.Path, err)
+        }
+        fmt.Printf("Migrated %s to schema %d\n", migration.Path, prepush.SchemaVersion)
+    }
+    return nil
+}
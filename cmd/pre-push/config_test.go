@@ -0,0 +1,23 @@
+package main
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// TestConfigLayers tests the precedence of the layers, lowest first
+func TestConfigLayers(t *testing.T) {
+    configDir := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", configDir)
+
+    layers := configLayers("/repo/.project.yml")
+    want := []string{
+        "/etc/pre-push/config.yml",
+        filepath.Join(configDir, "pre-push", "config.yml"),
+        "/repo/.project.local.yml",
+    }
+    if !reflect.DeepEqual(layers, want) {
+        t.Errorf("Expected layers %v, got %v", want, layers)
+    }
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/version"
+)
+
+// GitRef represents a Git reference being pushed
+type GitRef struct {
+    LocalRef  string
+    LocalSHA  string
+    RemoteRef string
+    RemoteSHA string
+    IsDelete  bool
+    IsTag     bool
+    IsBranch  bool
+}
+
+// GitPushInfo contains information about the Git push operation
+type GitPushInfo struct {
+    RemoteName string
+    RemoteURL  string
+    Refs       []GitRef
+    Tags       []string
+    Branches   []string
+    IsDelete   bool
+}
+
+// readGitRefs reads Git ref information from stdin and parses it
+func readGitRefs() ([]GitRef, error) {
+    var refs []GitRef
+    scanner := bufio.NewScanner(os.Stdin)
+    
+    for scanner.Scan() {
+        line := scanner.Text()
+        if line != "" {
+            ref, err := parseGitRef(line)
+            if err != nil {
+                return nil, fmt.Errorf("failed to parse Git ref line %q: %w", line, err)
+            }
+            refs = append(refs, ref)
+        }
+    }
+    
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    
+    return refs, nil
+}
+
+// parseGitRef parses a single Git ref line from stdin
+// Format: <local_ref> <local_sha> <remote_ref> <remote_sha>
+// For delete operations: <local_ref> <zero_sha> <remote_ref> <remote_sha>
+func parseGitRef(line string) (GitRef, error) {
+    parts := strings.Fields(line)
+    if len(parts) != 4 {
+        return GitRef{}, fmt.Errorf("invalid Git ref format, expected 4 fields, got %d", len(parts))
+    }
+    
+    return newGitRef(parts[0], parts[1], parts[2], parts[3]), nil
+}
+
+// newGitRef classifies a ref the way Git reports it on stdin: a zero local
+// SHA is a delete, the local ref tells tags from branches
+func newGitRef(localRef, localSHA, remoteRef, remoteSHA string) GitRef {
+    return GitRef{
+        LocalRef:  localRef,
+        LocalSHA:  localSHA,
+        RemoteRef: remoteRef,
+        RemoteSHA: remoteSHA,
+        IsDelete:  localSHA == zeroSHA,
+        IsTag:     strings.HasPrefix(localRef, "refs/tags/"),
+        IsBranch:  strings.HasPrefix(localRef, "refs/heads/"),
+    }
+}
+
+// parseGitPushInfo parses Git push information from refs and the remote Git passes as arguments
+func parseGitPushInfo(refs []GitRef, remoteName, remoteURL string) (*GitPushInfo, error) {
+    if remoteName == "" {
+        return nil, fmt.Errorf("remote name is required")
+    }
+    
+    var tags []string
+    var branches []string
+    isDelete := false
+    
+    for _, ref := range refs {
+        if ref.IsDelete {
+            isDelete = true
+            // Extract tag/branch name from local ref
+            if ref.IsTag {
+                tagName := strings.TrimPrefix(ref.LocalRef, "refs/tags/")
+                tags = append(tags, tagName)
+            } else if ref.IsBranch {
+                branchName := strings.TrimPrefix(ref.LocalRef, "refs/heads/")
+                branches = append(branches, branchName)
+            }
+        } else {
+            // Extract tag/branch name from local ref
+            if ref.IsTag {
+                tagName := strings.TrimPrefix(ref.LocalRef, "refs/tags/")
+                tags = append(tags, tagName)
+            } else if ref.IsBranch {
+                branchName := strings.TrimPrefix(ref.LocalRef, "refs/heads/")
+                branches = append(branches, branchName)
+            }
+        }
+    }
+    
+    return &GitPushInfo{
+        RemoteName: remoteName,
+        RemoteURL:  remoteURL,
+        Refs:       refs,
+        Tags:       tags,
+        Branches:   branches,
+        IsDelete:   isDelete,
+    }, nil
+}
+
+// validateTagSemantics validates that a tag follows semantic versioning
+func validateTagSemantics(tag string) error {
+    // Use version library to validate tag semantics
+    detector := version.New()
+    
+    // Check if tag is a valid semantic version
+    if err := detector.ValidateVersion(tag); err != nil {
+        return fmt.Errorf("tag %s is not a valid semantic version: %w", tag, err)
+    }
+    
+    return nil
+}
+
+// shouldSkipPrePushStage determines if we should skip the pre-push stage
+// Logic:
+// - If pushing the branch we are on → this is our branch (don't skip)
+// - If pushing a tag that is on the current branch → this is our branch (don't skip)
+// - If pushing a tag that is NOT on the current branch → not our branch (skip)
+// - If pushing a branch that is NOT the current branch → not our branch (skip)
+func shouldSkipPrePushStage(pushInfo *GitPushInfo) bool {
+    // Get current branch
+    currentBranch, err := getCurrentBranch()
+    if err != nil {
+        // If we can't get current branch, don't skip (safer to run checks)
+        return false
+    }
+    
+    // If pushing branches, check if any branch matches current branch
+    if len(pushInfo.Branches) > 0 {
+        isOurBranch := false
+        for _, branch := range pushInfo.Branches {
+            if branch == currentBranch {
+                // This is our branch - don't skip
+                isOurBranch = true
+                break
+            }
+        }
+        if !isOurBranch {
+            // None of the pushed branches is our current branch - skip
+            return true
+        }
+        // At least one branch is our current branch - don't skip
+        return false
+    }
+    
+    // If pushing tags, check if any tag exists on current branch
+    if len(pushInfo.Tags) > 0 {
+        isTagOnBranch := false
+        for _, tag := range pushInfo.Tags {
+            if isTagOnCurrentBranch(tag, currentBranch) {
+                // Tag is on our branch - don't skip
+                isTagOnBranch = true
+                break
+            }
+        }
+        if !isTagOnBranch {
+            // None of the tags are on our current branch - skip
+            return true
+        }
+        // At least one tag is on our current branch - don't skip
+        return false
+    }
+    
+    // No branches or tags to push? Shouldn't happen, but don't skip
+    return false
+}
+
+// isTagOnCurrentBranch checks if a tag exists on the current branch
+// A tag is "on a branch" if the tag commit is reachable from HEAD
+// (meaning the tag is part of the branch's commit history)
+func isTagOnCurrentBranch(tag, currentBranch string) bool {
+    // Get the commit SHA of the tag
+    cmd := exec.Command("git", "rev-parse", tag+"^{commit}")
+    tagCommit, err := cmd.Output()
+    if err != nil {
+        // If tag doesn't exist or can't resolve, assume not on branch (safer to skip)
+        return false
+    }
+    tagCommitStr := strings.TrimSpace(string(tagCommit))
+    
+    // Check if tag commit is reachable from current branch HEAD
+    // This checks if tag is an ancestor of HEAD (tag is in branch history)
+    cmd = exec.Command("git", "merge-base", "--is-ancestor", tagCommitStr, "HEAD")
+    err = cmd.Run()
+    return err == nil // Exit code 0 means tag is ancestor of HEAD (tag is on branch)
+}
+
+// getCurrentBranch gets the current Git branch
+func getCurrentBranch() (string, error) {
+    cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+    output, err := cmd.Output()
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// getCurrentTag gets the current Git tag
+func getCurrentTag() (string, error) {
+    cmd := exec.Command("git", "describe", "--tags", "--abbrev=0")
+    output, err := cmd.Output()
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// zeroSHA is the object name Git uses for refs that do not exist
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// simulateGitRefs builds the refs Git would pass on stdin for a push of the
+// given refspecs (<local-ref>[:<remote-ref>], an empty local ref deletes).
+// Without refspecs the current branch is pushed; since overrides the remote
+// SHA of the current branch, which must then be pushed.
+func simulateGitRefs(remoteName string, specs []string, since string) ([]GitRef, error) {
+    branch, err := getCurrentBranch()
+    if err != nil || branch == "HEAD" {
+        if len(specs) == 0 || since != "" {
+            return nil, fmt.Errorf("cannot determine the current branch, use --ref")
+        }
+        branch = ""
+    }
+    if len(specs) == 0 {
+        specs = []string{branch}
+    }
+    
+    sinceSHA := ""
+    if since != "" {
+        sinceSHA, err = gitOutput("rev-parse", "--verify", since+"^{commit}")
+        if err != nil {
+            return nil, fmt.Errorf("unknown commit %s", since)
+        }
+    }
+    
+    current := false
+    
+    var refs []GitRef
+    for _, spec := range specs {
+        localSpec, remoteSpec, hasRemote := strings.Cut(spec, ":")
+        
+        localRef := "(delete)"
+        localSHA := zeroSHA
+        if localSpec != "" {
+            sha, err := gitOutput("rev-parse", "--verify", localSpec)
+            if err != nil {
+                return nil, fmt.Errorf("unknown local ref %s", localSpec)
+            }
+            localSHA = sha
+            localRef = localSpec
+            if full, err := gitOutput("rev-parse", "--symbolic-full-name", localSpec); err == nil && full != "" {
+                localRef = full
+            }
+        } else if !hasRemote || remoteSpec == "" {
+            return nil, fmt.Errorf("invalid ref spec %q", spec)
+        }
+        
+        remoteRef := remoteSpec
+        if !hasRemote || remoteRef == "" {
+            remoteRef = localRef
+        } else if !strings.HasPrefix(remoteRef, "refs/") {
+            if strings.HasPrefix(localRef, "refs/tags/") {
+                remoteRef = "refs/tags/" + remoteRef
+            } else {
+                remoteRef = "refs/heads/" + remoteRef
+            }
+        }
+        
+        // The remote SHA is taken from the remote tracking branch if there is one
+        remoteSHA := zeroSHA
+        if strings.HasPrefix(remoteRef, "refs/heads/") {
+            tracking := "refs/remotes/" + remoteName + "/" + strings.TrimPrefix(remoteRef, "refs/heads/")
+            if sha, err := gitOutput("rev-parse", "--verify", "--quiet", tracking); err == nil && sha != "" {
+                remoteSHA = sha
+            }
+        }
+        if sinceSHA != "" && localRef == "refs/heads/"+branch {
+            remoteSHA = sinceSHA
+            current = true
+        }
+        
+        // Build the refs exactly like the lines Git writes on stdin
+        refs = append(refs, newGitRef(localRef, localSHA, remoteRef, remoteSHA))
+    }
+    
+    if sinceSHA != "" && !current {
+        return nil, fmt.Errorf("--since applies to the current branch %s, which is not pushed", branch)
+    }
+    return refs, nil
+}
+
+// gitOutput runs a git command and returns its trimmed output
+func gitOutput(args ...string) (string, error) {
+    output, err := exec.Command("git", args...).Output()
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// convertGitRefs converts GitRef to preexec.GitRef
+func convertGitRefs(refs []GitRef) []preexec.GitRef {
+    result := make([]preexec.GitRef, len(refs))
+    for i, ref := range refs {
+        result[i] = preexec.GitRef{
+            LocalRef:  ref.LocalRef,
+            LocalSHA:  ref.LocalSHA,
+            RemoteRef: ref.RemoteRef,
+            RemoteSHA: ref.RemoteSHA,
+            IsDelete:  ref.IsDelete,
+            IsTag:     ref.IsTag,
+            IsBranch:  ref.IsBranch,
+        }
+    }
+    return result
+}
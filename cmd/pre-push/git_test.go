@@ -0,0 +1,163 @@
+package main
+
+import (
+    "os"
+    "os/exec"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// initRepo creates a repository on branch main with two commits and changes
+// into it. It returns the SHAs of the commits.
+func initRepo(t *testing.T) (string, string) {
+    tempDir := t.TempDir()
+    oldDir, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get current dir: %v", err)
+    }
+    t.Cleanup(func() { os.Chdir(oldDir) })
+    if err := os.Chdir(tempDir); err != nil {
+        t.Fatalf("Failed to change to temp dir: %v", err)
+    }
+
+    for _, args := range [][]string{
+        {"init", "-q"},
+        {"symbolic-ref", "HEAD", "refs/heads/main"},
+        {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "first"},
+        {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "second"},
+    } {
+        if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+            t.Fatalf("Failed to run git %s: %v: %s", strings.Join(args, " "), err, output)
+        }
+    }
+    first, _ := gitOutput("rev-parse", "HEAD~1")
+    second, _ := gitOutput("rev-parse", "HEAD")
+    return first, second
+}
+
+// TestParseGitRef tests the classification of the ref lines Git writes on stdin
+func TestParseGitRef(t *testing.T) {
+    const sha = "1111111111111111111111111111111111111111"
+    tests := []struct {
+        line string
+        want GitRef
+        err  bool
+    }{
+        {
+            line: "refs/heads/main " + sha + " refs/heads/main " + zeroSHA,
+            want: GitRef{LocalRef: "refs/heads/main", LocalSHA: sha, RemoteRef: "refs/heads/main", RemoteSHA: zeroSHA, IsBranch: true},
+        },
+        {
+            line: "refs/tags/v1.0.0 " + sha + " refs/tags/v1.0.0 " + zeroSHA,
+            want: GitRef{LocalRef: "refs/tags/v1.0.0", LocalSHA: sha, RemoteRef: "refs/tags/v1.0.0", RemoteSHA: zeroSHA, IsTag: true},
+        },
+        {
+            line: "(delete) " + zeroSHA + " refs/heads/old " + sha,
+            want: GitRef{LocalRef: "(delete)", LocalSHA: zeroSHA, RemoteRef: "refs/heads/old", RemoteSHA: sha, IsDelete: true},
+        },
+        {line: "refs/heads/main " + sha, err: true},
+    }
+
+    for _, test := range tests {
+        ref, err := parseGitRef(test.line)
+        if (err != nil) != test.err {
+            t.Errorf("parseGitRef(%q) error = %v", test.line, err)
+            continue
+        }
+        if !reflect.DeepEqual(ref, test.want) {
+            t.Errorf("parseGitRef(%q) = %+v, want %+v", test.line, ref, test.want)
+        }
+    }
+}
+
+// TestSimulateGitRefs tests that simulated refs are the refs the hook reads
+// from stdin for the same push
+func TestSimulateGitRefs(t *testing.T) {
+    first, second := initRepo(t)
+    for _, args := range [][]string{
+        {"branch", "feature", first},
+        {"tag", "v1.0.0", first},
+        {"update-ref", "refs/remotes/origin/main", first},
+    } {
+        if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+            t.Fatalf("Failed to run git %s: %v: %s", strings.Join(args, " "), err, output)
+        }
+    }
+
+    tests := []struct {
+        name  string
+        specs []string
+        since string
+        lines []string // Lines Git writes on stdin for the push
+    }{
+        {
+            name:  "update",
+            lines: []string{"refs/heads/main " + second + " refs/heads/main " + first},
+        },
+        {
+            name:  "update since",
+            since: second,
+            lines: []string{"refs/heads/main " + second + " refs/heads/main " + second},
+        },
+        {
+            name:  "create",
+            specs: []string{"feature"},
+            lines: []string{"refs/heads/feature " + first + " refs/heads/feature " + zeroSHA},
+        },
+        {
+            name:  "create renamed",
+            specs: []string{"feature:topic"},
+            lines: []string{"refs/heads/feature " + first + " refs/heads/topic " + zeroSHA},
+        },
+        {
+            name:  "delete",
+            specs: []string{":main"},
+            lines: []string{"(delete) " + zeroSHA + " refs/heads/main " + first},
+        },
+        {
+            name:  "delete tag",
+            specs: []string{":refs/tags/v1.0.0"},
+            lines: []string{"(delete) " + zeroSHA + " refs/tags/v1.0.0 " + zeroSHA},
+        },
+        {
+            name:  "tag",
+            specs: []string{"v1.0.0"},
+            lines: []string{"refs/tags/v1.0.0 " + first + " refs/tags/v1.0.0 " + zeroSHA},
+        },
+        {
+            name:  "tag renamed",
+            specs: []string{"v1.0.0:v1"},
+            lines: []string{"refs/tags/v1.0.0 " + first + " refs/tags/v1 " + zeroSHA},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            refs, err := simulateGitRefs("origin", test.specs, test.since)
+            if err != nil {
+                t.Fatalf("Failed to simulate refs: %v", err)
+            }
+            var want []GitRef
+            for _, line := range test.lines {
+                ref, err := parseGitRef(line)
+                if err != nil {
+                    t.Fatalf("Failed to parse %q: %v", line, err)
+                }
+                want = append(want, ref)
+            }
+            if !reflect.DeepEqual(refs, want) {
+                t.Errorf("Expected %+v, got %+v", want, refs)
+            }
+        })
+    }
+
+    for _, specs := range [][]string{{"missing"}, {":"}} {
+        if _, err := simulateGitRefs("origin", specs, ""); err == nil {
+            t.Errorf("Expected error for %v", specs)
+        }
+    }
+    if _, err := simulateGitRefs("origin", []string{"feature"}, second); err == nil {
+        t.Error("Expected error for --since without the current branch")
+    }
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/history"
+    "github.com/AlexBurnes/pre-push/internal/repo"
+    "github.com/AlexBurnes/pre-push/internal/ui"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// getHistoryRetention gets the number of runs kept in the run history (0 disables history)
+func getHistoryRetention() int {
+    if envHistory := os.Getenv("PRE_PUSH_HISTORY"); envHistory != "" {
+        if retention, err := strconv.Atoi(envHistory); err == nil && retention >= 0 {
+            return retention
+        }
+    }
+    return history.DefaultRetention
+}
+
+// openHistoryStore opens the run history store in .git/pre-push/runs
+func openHistoryStore(ctx context.Context) (*history.Store, error) {
+    stateDir, err := repo.StateDir(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return history.NewStore(filepath.Join(stateDir, "runs"), getHistoryRetention()), nil
+}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+    Use:   "history",
+    Short: "List recorded runs",
+    Long: `List the runs recorded in .git/pre-push/runs, newest first. Each entry shows
+the run ID, time, stage, result and the refs that were pushed.
+
+The number of runs kept is set by the PRE_PUSH_HISTORY environment variable
+(default 20, 0 disables recording).`,
+    RunE: runHistory,
+}
+
+// lastCmd represents the last command
+var lastCmd = &cobra.Command{
+    Use:   "last",
+    Short: "Show the report of the most recent run",
+    Long: `Re-render the report of the most recent recorded run without re-running
+any checks. Use --step to print the full output log of a step and --run to
+show a specific run from 'pre-push history'.`,
+    RunE: runLast,
+}
+
+// lastStageResults returns the step results of the most recent recorded run of a stage
+func lastStageResults(ctx context.Context, stageName string) ([]preexec.StepResult, error) {
+    store, err := openHistoryStore(ctx)
+    if err != nil {
+        return nil, err
+    }
+    
+    runs, err := store.List()
+    if err != nil {
+        return nil, err
+    }
+    
+    for _, run := range runs {
+        if run.Stage != stageName {
+            continue
+        }
+        
+        var results []preexec.StepResult
+        for _, step := range run.Steps {
+            status, err := prepush.ParseStatus(step.Status)
+            if err != nil {
+                return nil, fmt.Errorf("run %s: %w", run.ID, err)
+            }
+            results = append(results, preexec.StepResult{
+                Name:     step.Name,
+                Status:   status,
+                Message:  step.Message,
+                Duration: step.Duration,
+                LogPath:  run.LogPath(step),
+            })
+        }
+        return results, nil
+    }
+    
+    return nil, fmt.Errorf("no recorded runs of stage %s found", stageName)
+}
+
+// hasFailedStep checks if any of the step results is an error
+func hasFailedStep(results []preexec.StepResult) bool {
+    for _, result := range results {
+        if result.Status == prepush.StatusError {
+            return true
+        }
+    }
+    return false
+}
+
+// runHistory lists the recorded runs
+func runHistory(cmd *cobra.Command, args []string) error {
+    store, err := openHistoryStore(cmd.Context())
+    if err != nil {
+        return err
+    }
+    
+    runs, err := store.List()
+    if err != nil {
+        return err
+    }
+    
+    if len(runs) == 0 {
+        fmt.Println("No recorded runs found")
+        return nil
+    }
+    
+    fmt.Printf("  %-28s %-19s %-12s %-7s %s\n", "ID", "TIME", "STAGE", "RESULT", "REFS")
+    for _, run := range runs {
+        result := "OK"
+        if !run.Success {
+            result = "FAILED"
+        }
+        
+        var refs []string
+        for _, ref := range run.Refs {
+            refs = append(refs, ref.LocalRef)
+        }
+        refsStr := strings.Join(refs, ",")
+        if !run.Hook {
+            refsStr = "(test)"
+        }
+        
+        fmt.Printf("  %-28s %-19s %-12s %-7s %s\n", run.ID, run.Timestamp.Format("2006-01-02 15:04:05"), run.Stage, result, refsStr)
+    }
+    
+    return nil
+}
+
+// runLast re-renders the report of the most recent run
+func runLast(cmd *cobra.Command, args []string) error {
+    store, err := openHistoryStore(cmd.Context())
+    if err != nil {
+        return err
+    }
+    
+    var run *history.Run
+    if runID, _ := cmd.Flags().GetString("run"); runID != "" {
+        run, err = store.Load(runID)
+    } else {
+        run, err = store.Last()
+    }
+    if err != nil {
+        return err
+    }
+    
+    // Print the full log of a single step
+    if stepName, _ := cmd.Flags().GetString("step"); stepName != "" {
+        step, exists := run.GetStep(stepName)
+        if !exists {
+            return fmt.Errorf("step not found in run %s: %s", run.ID, stepName)
+        }
+        logPath := run.LogPath(step)
+        if logPath == "" {
+            return fmt.Errorf("no output log recorded for step %s", stepName)
+        }
+        file, err := os.Open(logPath)
+        if err != nil {
+            return fmt.Errorf("failed to open log of step %s: %w", stepName, err)
+        }
+        defer file.Close()
+        _, err = io.Copy(os.Stdout, file)
+        return err
+    }
+    
+    ui := ui.NewWithVerboseLevel(getVerboseLevel(), isDebugEnabled())
+    ui.Printf("Run %s at %s\n", run.ID, run.Timestamp.Format("2006-01-02 15:04:05"))
+    for _, ref := range run.Refs {
+        ui.Printf("  %s -> %s %s\n", ref.LocalRef, run.Remote, ref.RemoteRef)
+    }
+    
+    ui.PrintStageHeader(run.Stage)
+    tailLines := getOutputOptions().TailLines
+    var results []prepush.Result
+    for _, step := range run.Steps {
+        status, err := prepush.ParseStatus(step.Status)
+        if err != nil {
+            status = prepush.StatusPending
+        }
+        ui.PrintStepStatus(step.Name, status, step.Message)
+        
+        if logPath := run.LogPath(step); logPath != "" {
+            lines, omitted, err := history.TailFile(logPath, tailLines)
+            if err == nil {
+                ui.PrintOutputTail(step.Name, lines, omitted, logPath)
+            }
+        }
+        
+        results = append(results, prepush.Result{
+            Name:    step.Name,
+            Status:  status,
+            Message: step.Message,
+        })
+    }
+    ui.PrintSummary(results)
+    ui.PrintStageResult(run.Stage, run.Success, run.Duration.Round(time.Millisecond))
+    
+    return nil
+}
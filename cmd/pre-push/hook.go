@@ -0,0 +1,489 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "syscall"
+
+    "github.com/spf13/cobra"
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/install"
+    "github.com/AlexBurnes/pre-push/internal/repo"
+    "github.com/AlexBurnes/pre-push/internal/ui"
+)
+
+// hookCmd represents the hook command
+var hookCmd = &cobra.Command{
+    Use:   "hook [--name <hook>] [args...]",
+    Short: "Run as Git hook",
+    Long: `Run a Git hook explicitly, pre-push unless --name selects another hook.
+Git passes pre-push the remote name and URL as arguments and the refs being
+pushed on stdin, commit-msg the message file, and post-merge the squash flag;
+pre-commit takes no arguments. The hook runs the stage of the same name. Use
+this command from hook managers or wrapper scripts; --print-script prints a
+wrapper hook script that runs this binary.`,
+    Args: func(cmd *cobra.Command, args []string) error {
+        if printScript, _ := cmd.Flags().GetBool("print-script"); printScript {
+            return cobra.NoArgs(cmd, args)
+        }
+        hookName, _ := cmd.Flags().GetString("name")
+        switch hookName {
+        case "pre-push":
+            return cobra.RangeArgs(1, 2)(cmd, args)
+        case "pre-commit":
+            return cobra.NoArgs(cmd, args)
+        case "commit-msg":
+            return cobra.ExactArgs(1)(cmd, args)
+        case "post-merge":
+            return cobra.MaximumNArgs(1)(cmd, args)
+        default:
+            _, err := install.ParseHooks([]string{hookName})
+            return err
+        }
+    },
+    RunE: runHook,
+}
+
+// detectHookMode determines if pre-push is running as a Git hook and returns
+// the hook name and arguments. Hook mode is detected when the binary is
+// installed under a supported hook name inside a hooks directory. The explicit
+// 'pre-push hook' subcommand is handled by hookCmd. As a fallback for pre-push
+// hooks installed under other names, piped stdin together with exactly two
+// arguments that are not a subcommand or flag is treated as a pre-push call.
+func detectHookMode() (string, []string) {
+    args := os.Args[1:]
+    
+    // Git never passes an argument starting with '-', so flags like -V still
+    // work on a binary installed in the hooks directory. Git always passes
+    // the remote to pre-push, so pre-push without arguments shows the CLI.
+    if hookName := hooksDirHook(os.Args[0]); hookName != "" {
+        if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+            return "", nil
+        }
+        if hookName != prePushHook || len(args) > 0 {
+            return hookName, args
+        }
+    }
+    
+    // Fallback heuristic: Git passes the remote name and URL
+    if len(args) != 2 || strings.HasPrefix(args[0], "-") || isSubcommand(args[0]) {
+        return "", nil
+    }
+    
+    // When Git calls the hook, it passes ref info via stdin
+    stat, err := os.Stdin.Stat()
+    if err != nil {
+        return "", nil
+    }
+    
+    // If stdin is not a character device, it's likely a pipe or file
+    if (stat.Mode() & os.ModeCharDevice) == 0 {
+        return prePushHook, args
+    }
+    return "", nil
+}
+
+// hooksDirHook returns the hook name the binary was started as from a Git
+// hooks directory, or an empty string if it was not started as a hook
+func hooksDirHook(argv0 string) string {
+    // Started by name from PATH, not by Git
+    if !strings.ContainsRune(argv0, '/') && !strings.ContainsRune(argv0, filepath.Separator) {
+        return ""
+    }
+    
+    hookName := strings.TrimSuffix(filepath.Base(argv0), ".exe")
+    if !install.IsHook(hookName) {
+        return ""
+    }
+    
+    dir, err := filepath.Abs(filepath.Dir(argv0))
+    if err != nil {
+        return ""
+    }
+    if filepath.Base(dir) == "hooks" {
+        return hookName
+    }
+    
+    // A custom core.hooksPath can have any name
+    if hooksDir, err := repo.HooksDir(context.Background()); err == nil && hooksDir == dir {
+        return hookName
+    }
+    return ""
+}
+
+// isSubcommand checks if a name is a pre-push subcommand
+func isSubcommand(name string) bool {
+    if name == "help" || name == "completion" {
+        return true
+    }
+    for _, cmd := range rootCmd.Commands() {
+        if cmd.Name() == name || cmd.HasAlias(name) {
+            return true
+        }
+    }
+    return false
+}
+
+// runGitHook runs a Git hook with the arguments Git passed
+func runGitHook(hookName string, args []string) error {
+    // Create context with cancellation
+    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer cancel()
+    
+    if hookName != prePushHook {
+        return runStageHook(ctx, hookName, args)
+    }
+    
+    // Read and parse ref information from stdin
+    refs, err := readGitRefs()
+    if err != nil {
+        return fmt.Errorf("failed to read Git refs: %w", err)
+    }
+    
+    // If no refs to push, exit successfully
+    if len(refs) == 0 {
+        return nil
+    }
+    
+    // Git passes the remote name and URL as arguments
+    if len(args) < 1 {
+        return fmt.Errorf("failed to parse Git push info: insufficient arguments for Git hook")
+    }
+    remoteName := args[0]
+    remoteURL := ""
+    if len(args) > 1 {
+        remoteURL = args[1]
+    }
+    
+    return runHookFlow(ctx, refs, remoteName, remoteURL, nil)
+}
+
+// runStageHook runs a Git hook other than pre-push: the stage of the same
+// name with the hook input as variables, and a chained <hook>.local hook.
+// Repositories without the stage only run the chained hook.
+func runStageHook(ctx context.Context, hookName string, args []string) error {
+    localHook := findLocalHook(hookName)
+    
+    configPath, err := findConfig(ctx)
+    if err != nil {
+        return err
+    }
+    if configPath == "" {
+        return runLocalHook(ctx, localHook, args, "")
+    }
+    
+    project, err := loadProject(configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+    
+    // An outdated hook warns, refuses or hands the hook to a refreshed binary
+    refreshed, err := checkHookVersion(hookName, project)
+    if err != nil {
+        return err
+    }
+    if refreshed != "" {
+        return runHookBinary(ctx, refreshed, hookName, args, "")
+    }
+    
+    if _, exists := project.Config.GetStage(hookName); !exists {
+        if isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: No %s stage in %s, skipping checks\n", hookName, configPath)
+        }
+        return runLocalHook(ctx, localHook, args, "")
+    }
+    
+    variables, err := hookVariables(ctx, hookName, args)
+    if err != nil {
+        return err
+    }
+    
+    ui := ui.NewWithVerboseLevel(getVerboseLevel(), isDebugEnabled())
+    executor := preexec.BuildfabExecutorWithCLIVersion(project.Config, ui, getVersion())
+    executor.SetOutputOptions(getOutputOptions())
+    executor.SetConfigPath(describeConfig(project))
+    executor.SetMasker(project.Settings.Masker())
+    executor.SetHookVariables(variables)
+    executor.SetStagedOnly(hookName == "pre-commit" && project.Settings.IsStashUnstaged())
+    enableCache(ctx, executor)
+    
+    if localHook != "" {
+        executor.SetChainedHook(&preexec.ChainedHook{
+            Name:  filepath.Base(localHook),
+            Path:  localHook,
+            Args:  args,
+            After: isChainAfter(),
+        })
+    }
+    
+    return runStageRecorded(ctx, executor, hookName, nil, true)
+}
+
+// hookVariables returns the variables describing the input of a Git hook:
+//   - all hooks: hook (the hook name) and hook.args
+//   - pre-commit: staged_files, the files added, copied, modified or renamed in the index
+//   - commit-msg: commit_msg_file and commit_msg, the path and text of the message
+//   - post-merge: merge_squash, merge_head and changed_files between ORIG_HEAD and HEAD
+func hookVariables(ctx context.Context, hookName string, args []string) (map[string]string, error) {
+    variables := map[string]string{
+        "hook":      hookName,
+        "hook.args": strings.Join(args, " "),
+    }
+    
+    switch hookName {
+    case "pre-commit":
+        files, err := preexec.StagedFiles(ctx)
+        if err != nil {
+            return nil, fmt.Errorf("failed to list staged files: %w", err)
+        }
+        variables["staged_files"] = strings.Join(files, " ")
+    case "commit-msg":
+        if len(args) < 1 {
+            return nil, fmt.Errorf("commit-msg hook requires the message file")
+        }
+        message, err := os.ReadFile(args[0])
+        if err != nil {
+            return nil, fmt.Errorf("failed to read commit message: %w", err)
+        }
+        variables["commit_msg_file"] = args[0]
+        variables["commit_msg"] = strings.TrimSpace(string(message))
+    case "post-merge":
+        variables["merge_squash"] = "false"
+        if len(args) > 0 && args[0] == "1" {
+            variables["merge_squash"] = "true"
+        }
+        if head, err := gitOutput("rev-parse", "HEAD"); err == nil {
+            variables["merge_head"] = head
+        }
+        if files, err := gitOutput("diff", "--name-only", "ORIG_HEAD", "HEAD"); err == nil {
+            variables["changed_files"] = strings.Join(strings.Fields(files), " ")
+        }
+    }
+    
+    return variables, nil
+}
+
+// runHookFlow runs the pre-push hook flow for the refs being pushed: delete
+// handling, tag validation, skip logic and the pre-push stage. With asHook set
+// (a real push, not a simulation) a hook backed up to pre-push.local runs with
+// the same arguments and input, and the hook version is checked.
+func runHookFlow(ctx context.Context, refs []GitRef, remoteName, remoteURL string, flags *stageFlags) error {
+    asHook := flags == nil
+    // Parse Git push information
+    pushInfo, err := parseGitPushInfo(refs, remoteName, remoteURL)
+    if err != nil {
+        return fmt.Errorf("failed to parse Git push info: %w", err)
+    }
+    
+    localHook := ""
+    if asHook {
+        localHook = findLocalHook(prePushHook)
+    }
+    hookArgs := []string{remoteName, remoteURL}
+    
+    // 1. Check if this is a delete operation - if so, skip all checks
+    if pushInfo.IsDelete {
+        fmt.Fprintf(os.Stderr, "Delete operation detected, skipping pre-push checks\n")
+        return runLocalHook(ctx, localHook, hookArgs, formatGitRefs(refs))
+    }
+    
+    // 2. Repositories without a configuration run the user default or
+    // nothing, a globally installed hook must not get in their way
+    configPath, err := findConfig(ctx)
+    if err != nil {
+        return err
+    }
+    if configPath == "" {
+        if isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: No project (%s) or user default configuration found, skipping pre-push stage\n", strings.Join(config.ConfigFiles, ", "))
+        }
+        return runLocalHook(ctx, localHook, hookArgs, formatGitRefs(refs))
+    }
+    
+    // 3. Validate pushed tags for semantic versioning
+    if len(pushInfo.Tags) > 0 {
+        for _, tag := range pushInfo.Tags {
+            if err := validateTagSemantics(tag); err != nil {
+                return fmt.Errorf("invalid tag semantics for %s: %w", tag, err)
+            }
+        }
+    }
+    
+    // 4. Check if pushing tag/branch that is not current - if so, skip pre-push stage
+    if shouldSkipPrePushStage(pushInfo) {
+        fmt.Fprintf(os.Stderr, "Pushing tag/branch that is not current, skipping pre-push stage\n")
+        return runLocalHook(ctx, localHook, hookArgs, formatGitRefs(refs))
+    }
+    
+    // Load configuration using buildfab (supports includes)
+    project, err := loadProject(configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+    buildfabConfig := project.Config
+    
+    // An outdated hook warns, refuses or hands the push to a refreshed hook
+    if asHook {
+        refreshed, err := checkHookVersion(prePushHook, project)
+        if err != nil {
+            return err
+        }
+        if refreshed != "" {
+            return runHookBinary(ctx, refreshed, prePushHook, hookArgs, formatGitRefs(refs))
+        }
+    }
+    
+    // Determine verbose and debug modes for Git hooks
+    hookVerboseLevel := getVerboseLevel()
+    hookDebug := isDebugEnabled()
+    
+    // Debug output (only when debug mode is enabled)
+    if hookDebug {
+        fmt.Fprintf(os.Stderr, "DEBUG: hookVerboseLevel=%d, hookDebug=%v\n", hookVerboseLevel, hookDebug)
+        fmt.Fprintf(os.Stderr, "DEBUG: env PRE_PUSH_VERBOSE=%s\n", os.Getenv("PRE_PUSH_VERBOSE"))
+        fmt.Fprintf(os.Stderr, "DEBUG: getVerboseLevel()=%d\n", getVerboseLevel())
+        fmt.Fprintf(os.Stderr, "DEBUG: pushInfo=%+v\n", pushInfo)
+    }
+    
+    // Create UI with Git hook specific settings
+    ui := ui.NewWithVerboseLevel(hookVerboseLevel, hookDebug)
+    
+    // Create buildfab executor with CLI version and enhanced Git variables
+    executor := preexec.BuildfabExecutorWithCLIVersion(buildfabConfig, ui, getVersion())
+    executor.SetOutputOptions(getOutputOptions())
+    executor.SetConfigPath(describeConfig(project))
+    executor.SetMasker(project.Settings.Masker())
+    if asHook {
+        enableCache(ctx, executor)
+    } else if done, err := flags.apply(ctx, executor, prePushHook); done || err != nil {
+        return err
+    }
+    
+    // Enhance executor with Git push information for variable interpolation
+    executor.SetGitPushInfo(&preexec.GitPushInfo{
+        RemoteName: pushInfo.RemoteName,
+        RemoteURL:  pushInfo.RemoteURL,
+        Refs:       convertGitRefs(pushInfo.Refs),
+        Tags:       pushInfo.Tags,
+        Branches:   pushInfo.Branches,
+        IsDelete:   pushInfo.IsDelete,
+    })
+    
+    // Run the chained hook as a step of the stage
+    if localHook != "" {
+        stdinPath, err := writeHookInput(refs)
+        if err != nil {
+            return err
+        }
+        defer os.Remove(stdinPath)
+        executor.SetChainedHook(&preexec.ChainedHook{
+            Name:  filepath.Base(localHook),
+            Path:  localHook,
+            Args:  hookArgs,
+            Stdin: stdinPath,
+            After: isChainAfter(),
+        })
+    }
+    
+    // Run pre-push stage and record the results
+    return runStageRecorded(ctx, executor, "pre-push", pushInfo, asHook)
+}
+
+// findLocalHook returns the path of an executable hook backed up to
+// <hook>.local, or an empty string if there is none
+func findLocalHook(hookName string) string {
+    hookPath, err := getGitHookPath(hookName)
+    if err != nil || !install.HasLocalHook(hookPath) {
+        return ""
+    }
+    return install.LocalHookPath(hookPath)
+}
+
+// isChainAfter checks if the chained hook runs after the pre-push steps.
+// PRE_PUSH_CHAIN=after selects it, by default the chained hook runs first.
+func isChainAfter() bool {
+    return strings.ToLower(os.Getenv("PRE_PUSH_CHAIN")) == "after"
+}
+
+// formatGitRefs formats refs the way Git passes them to the hook on stdin
+func formatGitRefs(refs []GitRef) string {
+    var b strings.Builder
+    for _, ref := range refs {
+        fmt.Fprintf(&b, "%s %s %s %s\n", ref.LocalRef, ref.LocalSHA, ref.RemoteRef, ref.RemoteSHA)
+    }
+    return b.String()
+}
+
+// writeHookInput writes the hook input to a temporary file for the chained hook
+func writeHookInput(refs []GitRef) (string, error) {
+    file, err := os.CreateTemp("", "pre-push-input-")
+    if err != nil {
+        return "", fmt.Errorf("failed to create hook input file: %w", err)
+    }
+    defer file.Close()
+    
+    if _, err := file.WriteString(formatGitRefs(refs)); err != nil {
+        os.Remove(file.Name())
+        return "", fmt.Errorf("failed to write hook input file: %w", err)
+    }
+    return file.Name(), nil
+}
+
+// runLocalHook runs the chained hook directly when the stage is skipped
+func runLocalHook(ctx context.Context, path string, args []string, input string) error {
+    if path == "" {
+        return nil
+    }
+    
+    cmd := exec.CommandContext(ctx, path, args...)
+    cmd.Stdin = strings.NewReader(input)
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("%s failed: %w", filepath.Base(path), err)
+    }
+    return nil
+}
+
+// runHook runs the hook command
+func runHook(cmd *cobra.Command, args []string) error {
+    hookName, _ := cmd.Flags().GetString("name")
+    if printScript, _ := cmd.Flags().GetBool("print-script"); printScript {
+        binaryPath, err := getCurrentBinaryPath()
+        if err != nil {
+            return fmt.Errorf("failed to get current binary path: %w", err)
+        }
+        fmt.Print(hookWrapperScript(binaryPath, hookName))
+        return nil
+    }
+    
+    minVersion, _ := cmd.Flags().GetString("min-version")
+    if err := checkMinVersion(minVersion); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    
+    if err := runGitHook(hookName, args); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    return nil
+}
+
+// hookWrapperScript returns a POSIX shell hook script that runs the binary in hook mode
+func hookWrapperScript(binaryPath, hookName string) string {
+    nameArg := ""
+    if hookName != prePushHook {
+        nameArg = " --name " + hookName
+    }
+    return fmt.Sprintf(`#!/bin/sh
+# Git %s hook generated by pre-push %s
+exec '%s' hook%s "$@"
+`, hookName, getVersion(), strings.ReplaceAll(binaryPath, "'", `'\''`), nameArg)
+}
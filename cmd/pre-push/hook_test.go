@@ -0,0 +1,151 @@
+package main
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestMain(m *testing.M) {
+    setupCommands()
+    os.Exit(m.Run())
+}
+
+// TestHooksDirHook tests which start paths of the binary are Git hooks
+func TestHooksDirHook(t *testing.T) {
+    initRepo(t)
+    dir, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get current dir: %v", err)
+    }
+
+    tests := []struct {
+        name  string
+        argv0 string
+        want  string
+    }{
+        {name: "started from PATH", argv0: "pre-push"},
+        {name: "hooks dir", argv0: filepath.Join(dir, ".git", "hooks", "pre-push"), want: "pre-push"},
+        {name: "relative hooks dir", argv0: ".git/hooks/pre-commit", want: "pre-commit"},
+        {name: "windows binary", argv0: ".git/hooks/commit-msg.exe", want: "commit-msg"},
+        {name: "other hooks dir", argv0: "/opt/hooks/post-merge", want: "post-merge"},
+        {name: "unsupported hook", argv0: ".git/hooks/post-checkout"},
+        {name: "installed binary", argv0: "/usr/local/bin/pre-push"},
+        {name: "hooks path not configured", argv0: filepath.Join(dir, ".githooks", "pre-commit")},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if hookName := hooksDirHook(test.argv0); hookName != test.want {
+                t.Errorf("hooksDirHook(%q) = %q, want %q", test.argv0, hookName, test.want)
+            }
+        })
+    }
+
+    // A core.hooksPath directory can have any name
+    if output, err := exec.Command("git", "config", "core.hooksPath", ".githooks").CombinedOutput(); err != nil {
+        t.Fatalf("Failed to set core.hooksPath: %v: %s", err, output)
+    }
+    for argv0, want := range map[string]string{
+        filepath.Join(dir, ".githooks", "pre-commit"): "pre-commit",
+        ".githooks/pre-push":                          "pre-push",
+        filepath.Join(dir, "githooks", "pre-commit"):  "",
+    } {
+        if hookName := hooksDirHook(argv0); hookName != want {
+            t.Errorf("hooksDirHook(%q) with core.hooksPath = %q, want %q", argv0, hookName, want)
+        }
+    }
+}
+
+// TestDetectHookMode tests how the arguments and stdin of a start select
+// hook mode or the CLI
+func TestDetectHookMode(t *testing.T) {
+    initRepo(t)
+    const hook = ".git/hooks/pre-push"
+    const binary = "/usr/local/bin/pre-push"
+
+    tests := []struct {
+        name     string
+        args     []string // os.Args
+        piped    bool     // stdin is a pipe instead of /dev/null
+        wantName string
+        wantArgs []string
+    }{
+        {
+            name:     "hooks dir",
+            args:     []string{hook, "origin", "git@example.com:repo.git"},
+            piped:    true,
+            wantName: "pre-push",
+            wantArgs: []string{"origin", "git@example.com:repo.git"},
+        },
+        {
+            name:     "hooks dir without stdin",
+            args:     []string{hook, "origin"},
+            wantName: "pre-push",
+            wantArgs: []string{"origin"},
+        },
+        {name: "hooks dir flag", args: []string{hook, "-V"}, piped: true},
+        {name: "hooks dir without arguments", args: []string{hook}},
+        {
+            name:     "hooks dir pre-commit",
+            args:     []string{".git/hooks/pre-commit"},
+            wantName: "pre-commit",
+            wantArgs: []string{},
+        },
+        {
+            name:  "shim",
+            args:  []string{binary, "hook", "origin", "git@example.com:repo.git"},
+            piped: true,
+        },
+        {
+            name:  "shim with hook name",
+            args:  []string{binary, "hook", "--name", "pre-commit"},
+            piped: true,
+        },
+        {name: "subcommand", args: []string{binary, "status"}, piped: true},
+        {name: "subcommand with two arguments", args: []string{binary, "run", "lint"}, piped: true},
+        {name: "remote without stdin", args: []string{binary, "origin", "git@example.com:repo.git"}},
+        {
+            name:     "remote with piped stdin",
+            args:     []string{binary, "origin", "git@example.com:repo.git"},
+            piped:    true,
+            wantName: "pre-push",
+            wantArgs: []string{"origin", "git@example.com:repo.git"},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            oldArgs, oldStdin := os.Args, os.Stdin
+            defer func() { os.Args, os.Stdin = oldArgs, oldStdin }()
+
+            os.Args = test.args
+            if test.piped {
+                r, w, err := os.Pipe()
+                if err != nil {
+                    t.Fatalf("Failed to create pipe: %v", err)
+                }
+                defer r.Close()
+                w.Close()
+                os.Stdin = r
+            } else {
+                devNull, err := os.Open(os.DevNull)
+                if err != nil {
+                    t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+                }
+                defer devNull.Close()
+                os.Stdin = devNull
+            }
+
+            hookName, args := detectHookMode()
+            if hookName != test.wantName {
+                t.Errorf("Expected hook %q, got %q", test.wantName, hookName)
+            }
+            if test.wantName != "" && !reflect.DeepEqual(args, test.wantArgs) {
+                t.Errorf("Expected arguments %v, got %v", test.wantArgs, args)
+            }
+        })
+    }
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/repo"
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+    Use:   "init",
+    Short: "Create a starter configuration for the repository",
+    Long: `Inspect the repository for go.mod, CMakeLists.txt, conanfile.py,
+package.json and Dockerfile and write a starter .project.yml to the
+repository root. The configuration has the Git built-in checks and the build
+and test actions of the detected project types in a pre-push stage, with
+require edges between them and only: [release] on heavy steps.
+
+Use --type to choose the project types instead of detecting them. Afterwards
+the Git pre-push hook is installed with --install, or after confirmation.`,
+    Args: cobra.NoArgs,
+    RunE: runInit,
+}
+
+// runInit writes a starter configuration for the project types of the
+// repository and offers to install the hook
+func runInit(cmd *cobra.Command, args []string) error {
+    cmd.SilenceUsage = true
+    dir := "."
+    if topLevel, err := repo.TopLevel(cmd.Context()); err == nil {
+        dir = topLevel
+    }
+    
+    configPath := filepath.Join(dir, config.ConfigFiles[0])
+    force, _ := cmd.Flags().GetBool("force")
+    if existing := config.FindInDir(dir); existing != "" {
+        if !force {
+            return fmt.Errorf("%s already exists, use --force to replace it", existing)
+        }
+        configPath = existing
+    }
+    
+    var types []config.ProjectType
+    if cmd.Flags().Changed("type") {
+        names, _ := cmd.Flags().GetStringSlice("type")
+        parsed, err := config.ParseProjectTypes(names)
+        if err != nil {
+            return err
+        }
+        types = parsed
+    } else {
+        types = config.DetectProjectTypes(dir)
+    }
+    
+    content, err := config.Scaffold(dir, types)
+    if err != nil {
+        return fmt.Errorf("failed to generate configuration: %w", err)
+    }
+    if err := os.WriteFile(configPath, content, 0644); err != nil {
+        return fmt.Errorf("failed to write %s: %w", configPath, err)
+    }
+    
+    if len(types) == 0 {
+        fmt.Println("No project type detected, the configuration has the Git checks only")
+    } else {
+        fmt.Printf("Detected project types: %s\n", strings.Join(projectTypeNames(types), ", "))
+    }
+    fmt.Printf("Created %s, check it with 'pre-push validate' and try it with 'pre-push test'\n", configPath)
+    
+    installHook, _ := cmd.Flags().GetBool("install")
+    if !installHook {
+        confirmed, err := confirm("Install the Git pre-push hook?")
+        if err != nil {
+            fmt.Println("Run 'pre-push install' to install the Git pre-push hook")
+            return nil
+        }
+        installHook = confirmed
+    }
+    if !installHook {
+        return nil
+    }
+    return runInstall(installCmd, nil)
+}
+
+// projectTypeNames returns the names of project types
+func projectTypeNames(types []config.ProjectType) []string {
+    names := make([]string, len(types))
+    for i, typ := range types {
+        names[i] = string(typ)
+    }
+    return names
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// TestInitNonInteractive tests that init without a terminal writes the
+// configuration and leaves the hook uninstalled
+func TestInitNonInteractive(t *testing.T) {
+    initRepo(t)
+    redirectStdin(t, os.DevNull)
+
+    initCmd.SetContext(context.Background())
+    if err := runInit(initCmd, nil); err != nil {
+        t.Fatalf("Failed to run init: %v", err)
+    }
+
+    if _, err := os.Stat(".project.yml"); err != nil {
+        t.Errorf("Expected configuration to be written, got %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(".git", "hooks", "pre-push")); !os.IsNotExist(err) {
+        t.Errorf("Expected no hook to be installed, got %v", err)
+    }
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "github.com/spf13/cobra"
+    "github.com/AlexBurnes/pre-push/internal/install"
+    "github.com/AlexBurnes/pre-push/internal/version"
+)
+
+// getCurrentBinaryPath returns the path to the current running binary
+func getCurrentBinaryPath() (string, error) {
+    return os.Executable()
+}
+
+// getGitHookPath returns the path to a Git hook in the directory Git runs
+// hooks from (core.hooksPath, worktrees and submodules included)
+func getGitHookPath(hookName string) (string, error) {
+    return install.HookPath(context.Background(), hookName)
+}
+
+// newInstaller creates the installer of a Git hook
+func newInstaller(hookName string) (*install.Installer, error) {
+    return install.New(context.Background(), hookName)
+}
+
+// isBinaryDifferent checks if the Git hook binary is different from the current binary
+func isBinaryDifferent(hookName string) (bool, error) {
+    installer, err := newInstaller(hookName)
+    if err != nil {
+        return false, err
+    }
+    
+    upToDate, err := installer.IsUpToDate()
+    if err != nil {
+        return false, err
+    }
+    return !upToDate, nil
+}
+
+// updateGitHook copies the current binary to the Git hook location. An
+// installed hook of a newer version is only replaced with force.
+func updateGitHook(hookName string, force bool) error {
+    installer, err := newInstaller(hookName)
+    if err != nil {
+        return fmt.Errorf("failed to get git hook path: %w", err)
+    }
+    
+    // Keep a hook installed by another tool so it can be chained
+    if err := backupForeignHook(installer); err != nil {
+        return err
+    }
+    
+    if _, err := installer.Install(getVersion(), force); err != nil {
+        return err
+    }
+    return nil
+}
+
+// backupForeignHook moves a hook installed by another tool to <hook>.local,
+// the hook runs it as part of its stage
+func backupForeignHook(installer *install.Installer) error {
+    moved, err := installer.BackupForeignHook()
+    if err != nil {
+        return err
+    }
+    if moved {
+        localPath := install.LocalHookPath(installer.HookPath())
+        fmt.Printf("Existing %s hook moved to %s, it will run as step %s\n", filepath.Base(installer.HookPath()), localPath, filepath.Base(localPath))
+    }
+    return nil
+}
+
+// checkAndUpdateGitHook checks if the Git hook needs updating and updates it if necessary
+func checkAndUpdateGitHook(hookName string, force bool) (bool, error) {
+    different, err := isBinaryDifferent(hookName)
+    if err != nil {
+        return false, fmt.Errorf("failed to check if binary is different: %w", err)
+    }
+    
+    if different {
+        if err := updateGitHook(hookName, force); err != nil {
+            return false, fmt.Errorf("failed to update git hook: %w", err)
+        }
+        fmt.Printf("Updated Git %s hook with current binary\n", hookName)
+        return true, nil
+    } else {
+        fmt.Printf("Git %s hook is already up to date (version %s)\n", hookName, getVersion())
+        return false, nil
+    }
+}
+
+// installCmd represents the install command
+var installCmd = &cobra.Command{
+    Use:   "install",
+    Short: "Install or update Git pre-push hook",
+    Long: `Install or update the Git pre-push hook with the current binary.
+This command checks if the Git hook needs updating and updates it if necessary.
+The hook is installed as pre-push in the directory Git runs hooks from
+(.git/hooks, core.hooksPath, or the common Git directory of a worktree) and
+will be called automatically by Git before pushing changes.
+
+With --mode=shim a small shell script is installed instead of a copy of the
+binary. The shim runs the pinned binary (or pre-push from PATH) and refuses
+binaries older than --min-version, so upgrading pre-push upgrades every
+repository using the shim.`,
+    RunE: runInstall,
+}
+
+// uninstallCmd represents the uninstall command
+var uninstallCmd = &cobra.Command{
+    Use:   "uninstall",
+    Short: "Remove the Git pre-push hook",
+    Long: `Remove the Git pre-push hook installed by pre-push. A hook of another tool
+that was moved to .git/hooks/pre-push.local on install is restored.`,
+    RunE: runUninstall,
+}
+
+// runInstall handles the install command (check and update Git hooks)
+func runInstall(cmd *cobra.Command, args []string) error {
+    modeFlag, _ := cmd.Flags().GetString("mode")
+    mode, err := install.ParseMode(modeFlag)
+    if err != nil {
+        return err
+    }
+    hooks, err := getHooksFlag(cmd)
+    if err != nil {
+        return err
+    }
+    if global, _ := cmd.Flags().GetBool("global"); global {
+        return installGlobal(cmd, hooks)
+    }
+    
+    force, _ := cmd.Flags().GetBool("force")
+    for _, hookName := range hooks {
+        if mode == install.ModeShim {
+            if err := installShim(cmd, hookName); err != nil {
+                return err
+            }
+            continue
+        }
+        
+        // Check and update Git hook if needed
+        updated, err := checkAndUpdateGitHook(hookName, force)
+        if err != nil {
+            return installError(err)
+        }
+        
+        // Only print success message if we actually updated the hook
+        if updated {
+            fmt.Printf("Git %s hook installed successfully (version %s)\n", hookName, getVersion())
+        }
+    }
+    
+    return nil
+}
+
+// getHooksFlag returns the hooks selected by the --hooks flag
+func getHooksFlag(cmd *cobra.Command) ([]string, error) {
+    names, _ := cmd.Flags().GetStringSlice("hooks")
+    return install.ParseHooks(names)
+}
+
+// runUninstall removes the Git hooks and restores chained hooks. Without
+// --hooks every hook installed by pre-push is removed.
+func runUninstall(cmd *cobra.Command, args []string) error {
+    hooks, err := getHooksFlag(cmd)
+    if err != nil {
+        return err
+    }
+    selected := cmd.Flags().Changed("hooks")
+    
+    if global, _ := cmd.Flags().GetBool("global"); global {
+        return uninstallGlobal(hooks)
+    }
+    
+    removed := false
+    for _, hookName := range hooks {
+        installer, err := newInstaller(hookName)
+        if err != nil {
+            return fmt.Errorf("failed to get git hook path: %w", err)
+        }
+        
+        status, err := installer.Status()
+        if err != nil {
+            return err
+        }
+        if status.Kind == install.HookNone && status.LocalHook == "" {
+            if selected {
+                fmt.Printf("Git %s hook is not installed\n", hookName)
+            }
+            continue
+        }
+        // Hooks of other tools are only refused when asked for explicitly
+        if status.Kind == install.HookForeign && !selected {
+            continue
+        }
+        
+        restored, err := installer.Uninstall()
+        if err != nil {
+            return err
+        }
+        removed = true
+        
+        if restored {
+            fmt.Printf("Git %s hook removed, restored previous hook from %s\n", hookName, install.LocalHookPath(installer.HookPath()))
+        } else {
+            fmt.Printf("Git %s hook removed\n", hookName)
+        }
+    }
+    
+    if !removed && !selected {
+        fmt.Printf("Git pre-push hook is not installed\n")
+    }
+    return nil
+}
+
+// installError explains how to replace a newer installed hook
+func installError(err error) error {
+    var downgrade *install.DowngradeError
+    if errors.As(err, &downgrade) {
+        return fmt.Errorf("%w, use --force to downgrade", downgrade)
+    }
+    return fmt.Errorf("failed to update Git hook: %w", err)
+}
+
+// installShim installs a Git hook as a shell script that runs an installed pre-push binary
+func installShim(cmd *cobra.Command, hookName string) error {
+    installer, err := newInstaller(hookName)
+    if err != nil {
+        return fmt.Errorf("failed to get git hook path: %w", err)
+    }
+    
+    shim, err := newShim(cmd, installer, hookName)
+    if err != nil {
+        return err
+    }
+    
+    if err := backupForeignHook(installer); err != nil {
+        return err
+    }
+    
+    force, _ := cmd.Flags().GetBool("force")
+    written, err := installer.InstallShim(shim, force)
+    if err != nil {
+        return installError(err)
+    }
+    
+    if written {
+        fmt.Printf("Git %s hook shim installed successfully (version %s)\n", hookName, getVersion())
+    } else {
+        fmt.Printf("Git %s hook shim is already up to date (version %s)\n", hookName, getVersion())
+    }
+    return nil
+}
+
+// newShim creates the shim of a hook for an installer from the install flags
+func newShim(cmd *cobra.Command, installer *install.Installer, hookName string) (install.Shim, error) {
+    shim := install.Shim{Version: getVersion(), Hook: hookName}
+    
+    // The shim refuses binaries older than the one installing it by default
+    shim.MinVersion, _ = cmd.Flags().GetString("min-version")
+    if !cmd.Flags().Changed("min-version") {
+        if _, err := version.New().CompareVersions(getVersion(), getVersion()); err == nil {
+            shim.MinVersion = getVersion()
+        }
+    }
+    
+    if pin, _ := cmd.Flags().GetBool("pin"); pin {
+        binaryPath := installer.BinaryPath()
+        if binaryPath == installer.HookPath() {
+            return shim, fmt.Errorf("cannot pin the hook to itself, run install from an installed pre-push binary")
+        }
+        
+        shim.BinaryPath = binaryPath
+        digest, err := install.FileSHA256(binaryPath)
+        if err != nil {
+            return shim, fmt.Errorf("failed to calculate binary digest: %w", err)
+        }
+        shim.BinarySHA256 = digest
+    }
+    
+    return shim, nil
+}
+
+// globalHooksDirs are the default directories of global installs below the user configuration directory
+var globalHooksDirs = map[install.GlobalTarget]string{
+    install.GlobalTemplate:  "git-template",
+    install.GlobalHooksPath: "hooks",
+}
+
+// newGlobal resolves the global install of a target
+func newGlobal(ctx context.Context, target install.GlobalTarget) (*install.Global, error) {
+    configDir := userConfigDir()
+    if configDir == "" {
+        return nil, fmt.Errorf("failed to locate the user configuration directory")
+    }
+    return install.NewGlobal(ctx, target, filepath.Join(configDir, globalHooksDirs[target]))
+}
+
+// installGlobal installs the hook shims for all repositories of the user
+func installGlobal(cmd *cobra.Command, hooks []string) error {
+    ctx := context.Background()
+    
+    targetFlag, _ := cmd.Flags().GetString("target")
+    target, err := install.ParseGlobalTarget(targetFlag)
+    if err != nil {
+        return err
+    }
+    
+    global, err := newGlobal(ctx, target)
+    if err != nil {
+        return err
+    }
+    
+    force, _ := cmd.Flags().GetBool("force")
+    for _, hookName := range hooks {
+        installer, err := global.Installer(hookName)
+        if err != nil {
+            return err
+        }
+        
+        shim, err := newShim(cmd, installer, hookName)
+        if err != nil {
+            return err
+        }
+        
+        if err := backupForeignHook(installer); err != nil {
+            return err
+        }
+        
+        if _, err := installer.InstallShim(shim, force); err != nil {
+            return installError(err)
+        }
+        fmt.Printf("Global %s hook shim installed in %s (version %s)\n", hookName, installer.HookPath(), getVersion())
+    }
+    if err := global.Configure(ctx); err != nil {
+        return err
+    }
+    
+    if target == install.GlobalTemplate {
+        fmt.Printf("New clones get the hooks, run 'git init' or 'pre-push install' in existing repositories\n")
+    }
+    return nil
+}
+
+// uninstallGlobal removes the hooks of global installs of both targets and
+// the Git configuration pre-push set for them
+func uninstallGlobal(hooks []string) error {
+    ctx := context.Background()
+    
+    removed := false
+    for _, target := range []install.GlobalTarget{install.GlobalTemplate, install.GlobalHooksPath} {
+        global, err := newGlobal(ctx, target)
+        if err != nil {
+            return err
+        }
+        if !global.Configured {
+            continue
+        }
+        
+        for _, hookName := range hooks {
+            installer, err := global.Installer(hookName)
+            if err != nil {
+                return err
+            }
+            status, err := installer.Status()
+            if err != nil {
+                return err
+            }
+            if status.Kind != install.HookCopy && status.Kind != install.HookShim {
+                continue
+            }
+            
+            restored, err := installer.Uninstall()
+            if err != nil {
+                return err
+            }
+            fmt.Printf("Global %s hook removed from %s\n", hookName, installer.HookPath())
+            if restored {
+                fmt.Printf("Restored previous hook from %s\n", install.LocalHookPath(installer.HookPath()))
+            }
+            removed = true
+        }
+        
+        // The configuration is kept while hooks of pre-push remain in the directory
+        if global.Owned && !hasGlobalHooks(global) {
+            if err := global.Unconfigure(ctx); err != nil {
+                return err
+            }
+            fmt.Printf("Removed %s from the global Git configuration\n", global.Dir)
+            removed = true
+        }
+    }
+    
+    if !removed {
+        fmt.Printf("Global pre-push hook is not installed\n")
+    }
+    return nil
+}
+
+// hasGlobalHooks checks if any hook of a global install is a pre-push hook
+func hasGlobalHooks(global *install.Global) bool {
+    for _, hookName := range install.Hooks {
+        installer, err := global.Installer(hookName)
+        if err != nil {
+            continue
+        }
+        if status, err := installer.Status(); err == nil && (status.Kind == install.HookCopy || status.Kind == install.HookShim) {
+            return true
+        }
+    }
+    return false
+}
@@ -5,38 +5,81 @@ package main
 
 import (
     "bufio"
-    "context"
-    "crypto/md5"
+    "encoding/json"
     "fmt"
-    "io"
     "os"
-    "os/exec"
-    "os/signal"
-    "path/filepath"
+    "runtime"
+    rtdebug "runtime/debug"
     "strconv"
     "strings"
-    "syscall"
 
     "github.com/spf13/cobra"
-    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "golang.org/x/term"
     preexec "github.com/AlexBurnes/pre-push/internal/exec"
-    "github.com/AlexBurnes/pre-push/internal/ui"
-    "github.com/AlexBurnes/pre-push/internal/version"
-    "github.com/AlexBurnes/pre-push/pkg/prepush"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/install"
 )
 
 const (
     appName = "pre-push"
+    
+    // prePushHook is the name of the Git pre-push hook
+    prePushHook = "pre-push"
+    
+    // userDefaultConfigFile is the configuration used in repositories without a project configuration
+    userDefaultConfigFile = "default.yml"
+    
+    // userLayerFile is the layer in the user configuration directory merged over every configuration
+    userLayerFile = "config.yml"
+    
+    // systemLayerFile is the system-wide layer merged over every configuration
+    systemLayerFile = "/etc/pre-push/config.yml"
 )
 
-// appVersion is set at build time via ldflags
-var appVersion = "unknown"
+// Build metadata is set at build time via ldflags
+var (
+    appVersion = "unknown"
+    appCommit  = ""
+    appDate    = ""
+)
 
 // getVersion returns the compiled-in version
 func getVersion() string {
     return appVersion
 }
 
+// getBuildInfo returns the compiled-in build metadata. Builds without ldflags
+// take the commit and date from the VCS information Go embeds.
+func getBuildInfo() install.BuildInfo {
+    info := install.BuildInfo{
+        Name:      appName,
+        Version:   getVersion(),
+        Commit:    appCommit,
+        Date:      appDate,
+        GoVersion: runtime.Version(),
+        Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+    }
+    
+    if build, ok := rtdebug.ReadBuildInfo(); ok {
+        for _, setting := range build.Settings {
+            switch {
+            case setting.Key == "vcs.revision" && info.Commit == "":
+                info.Commit = setting.Value
+            case setting.Key == "vcs.time" && info.Date == "":
+                info.Date = setting.Value
+            }
+        }
+    }
+    
+    if info.Commit == "" {
+        info.Commit = "unknown"
+    }
+    if info.Date == "" {
+        info.Date = "unknown"
+    }
+    return info
+}
+
 // isVerboseEnabled checks if verbose mode should be enabled for Git hooks (for backward compatibility)
 func isVerboseEnabled() bool {
     return getVerboseLevel() > 0
@@ -73,145 +116,30 @@ func isDebugEnabled() bool {
     return debug
 }
 
-
-// getCurrentBinaryPath returns the path to the current running binary
-func getCurrentBinaryPath() (string, error) {
-    return os.Executable()
-}
-
-// getGitHookPath returns the path to the Git pre-push hook
-func getGitHookPath() (string, error) {
-    // Find .git directory
-    cwd, err := os.Getwd()
-    if err != nil {
-        return "", fmt.Errorf("failed to get current directory: %w", err)
-    }
+// getOutputOptions gets the step output capture options from environment variables
+func getOutputOptions() preexec.OutputOptions {
+    opts := preexec.DefaultOutputOptions()
     
-    // Walk up the directory tree to find .git
-    for {
-        gitDir := filepath.Join(cwd, ".git")
-        if _, err := os.Stat(gitDir); err == nil {
-            return filepath.Join(gitDir, "hooks", "pre-push"), nil
-        }
-        
-        parent := filepath.Dir(cwd)
-        if parent == cwd {
-            break // Reached root directory
+    // Number of trailing output lines shown for a failed step
+    if envTail := os.Getenv("PRE_PUSH_TAIL_LINES"); envTail != "" {
+        if lines, err := strconv.Atoi(envTail); err == nil && lines >= 0 {
+            opts.TailLines = lines
         }
-        cwd = parent
-    }
-    
-    return "", fmt.Errorf("not in a git repository")
-}
-
-// calculateFileMD5 calculates the MD5 hash of a file
-func calculateFileMD5(filePath string) (string, error) {
-    file, err := os.Open(filePath)
-    if err != nil {
-        return "", err
-    }
-    defer file.Close()
-    
-    hash := md5.New()
-    if _, err := io.Copy(hash, file); err != nil {
-        return "", err
-    }
-    
-    return fmt.Sprintf("%x", hash.Sum(nil)), nil
-}
-
-// isBinaryDifferent checks if the Git hook binary is different from the current binary
-func isBinaryDifferent() (bool, error) {
-    currentBinary, err := getCurrentBinaryPath()
-    if err != nil {
-        return false, fmt.Errorf("failed to get current binary path: %w", err)
-    }
-    
-    hookPath, err := getGitHookPath()
-    if err != nil {
-        return false, fmt.Errorf("failed to get git hook path: %w", err)
-    }
-    
-    // Check if hook file exists
-    if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-        return true, nil // Hook doesn't exist, so it's different
-    }
-    
-    // Calculate MD5 hashes
-    currentHash, err := calculateFileMD5(currentBinary)
-    if err != nil {
-        return false, fmt.Errorf("failed to calculate current binary hash: %w", err)
-    }
-    
-    hookHash, err := calculateFileMD5(hookPath)
-    if err != nil {
-        return false, fmt.Errorf("failed to calculate hook binary hash: %w", err)
-    }
-    
-    return currentHash != hookHash, nil
-}
-
-// updateGitHook copies the current binary to the Git hook location
-func updateGitHook() error {
-    currentBinary, err := getCurrentBinaryPath()
-    if err != nil {
-        return fmt.Errorf("failed to get current binary path: %w", err)
-    }
-    
-    hookPath, err := getGitHookPath()
-    if err != nil {
-        return fmt.Errorf("failed to get git hook path: %w", err)
-    }
-    
-    // Ensure hooks directory exists
-    hooksDir := filepath.Dir(hookPath)
-    if err := os.MkdirAll(hooksDir, 0755); err != nil {
-        return fmt.Errorf("failed to create hooks directory: %w", err)
-    }
-    
-    // Copy current binary to hook location
-    sourceFile, err := os.Open(currentBinary)
-    if err != nil {
-        return fmt.Errorf("failed to open current binary: %w", err)
-    }
-    defer sourceFile.Close()
-    
-    destFile, err := os.OpenFile(hookPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
-    if err != nil {
-        return fmt.Errorf("failed to create hook file: %w", err)
-    }
-    defer destFile.Close()
-    
-    if _, err := io.Copy(destFile, sourceFile); err != nil {
-        return fmt.Errorf("failed to copy binary to hook: %w", err)
     }
     
-    return nil
-}
-
-// checkAndUpdateGitHook checks if the Git hook needs updating and updates it if necessary
-func checkAndUpdateGitHook() (bool, error) {
-    different, err := isBinaryDifferent()
-    if err != nil {
-        return false, fmt.Errorf("failed to check if binary is different: %w", err)
+    // Directory where logs of failed steps are written
+    if envLogDir := os.Getenv("PRE_PUSH_LOG_DIR"); envLogDir != "" {
+        opts.LogDir = envLogDir
     }
     
-    if different {
-        if err := updateGitHook(); err != nil {
-            return false, fmt.Errorf("failed to update git hook: %w", err)
-        }
-        fmt.Printf("Updated Git pre-push hook with current binary\n")
-        return true, nil
-    } else {
-        fmt.Printf("Git pre-push hook is already up to date (version %s)\n", getVersion())
-        return false, nil
-    }
+    return opts
 }
 
 // Global flags
 var (
-    verbose bool
-    debug   bool
+    verbose    bool
+    debug      bool
+    configFile string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -222,431 +150,115 @@ var rootCmd = &cobra.Command{
 provides built-in checks and supports custom actions via YAML configuration.
 
 When invoked without arguments, it checks and installs or updates itself as a Git
-pre-push hook with MD5 verification. When invoked by Git as a hook, it reads the 
+pre-push hook with SHA-256 verification. When invoked by Git as a hook, it reads the 
 standard pre-push input and runs configured checks.
 
 Configuration is provided via .project.yml file in the repository root.`,
     RunE: runRoot,
+    // Errors are printed once, by main
+    SilenceErrors: true,
 }
 
-// testCmd represents the test command
-var testCmd = &cobra.Command{
-    Use:   "test",
-    Short: "Run all checks in dry-run mode",
-    Long: `Run all configured checks in dry-run mode without installing or updating
-the pre-push hook. This is useful for testing your configuration before
-pushing changes.`,
-    RunE: runTest,
-}
-
-// listUsesCmd represents the list-uses command
-var listUsesCmd = &cobra.Command{
-    Use:   "list-uses",
-    Short: "List available built-in actions",
-    Long: `List all available built-in actions that can be used in the 'uses' field
-of your configuration. Each action includes a brief description of what it does.`,
-    RunE: runListUses,
-}
-
-// installCmd represents the install command
-var installCmd = &cobra.Command{
-    Use:   "install",
-    Short: "Install or update Git pre-push hook",
-    Long: `Install or update the Git pre-push hook with the current binary.
-This command checks if the Git hook needs updating and updates it if necessary.
-The hook is installed in .git/hooks/pre-push and will be called automatically
-by Git before pushing changes.`,
-    RunE: runInstall,
-}
-
-
 func main() {
+    setupCommands()
+    
     // Check if we're being called by Git as a hook
-    if isGitHook() {
+    if hookName, hookArgs := detectHookMode(); hookName != "" {
         // When called by Git, run the hook directly (no update checks)
-        if err := runGitHook(); err != nil {
+        if err := runGitHook(hookName, hookArgs); err != nil {
             fmt.Fprintf(os.Stderr, "Error: %v\n", err)
             os.Exit(1)
         }
         return
     }
     
+    // Execute the root command
+    if err := rootCmd.Execute(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+}
+
+// setupCommands registers subcommands and flags
+func setupCommands() {
     // Add global flags
     rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
     rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable debug output")
+    rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "configuration file (default: "+strings.Join(config.ConfigFiles, ", ")+" in the repository root)")
     
     // Add version flags
     rootCmd.Flags().BoolP("version", "", false, "print version and module name")
     rootCmd.Flags().BoolP("version-only", "V", false, "print version only")
+    rootCmd.Flags().Bool("build-info", false, "print build metadata (version, commit, date) as JSON")
     
     // Add subcommands
     rootCmd.AddCommand(testCmd)
     rootCmd.AddCommand(listUsesCmd)
     rootCmd.AddCommand(installCmd)
-    
-    // Execute the root command
-    if err := rootCmd.Execute(); err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-        os.Exit(1)
-    }
-}
-
-// isGitHook determines if we're being called by Git as a hook
-func isGitHook() bool {
-    // Git calls hooks with arguments (like "origin master --tags") and passes ref info via stdin
-    // We detect this by checking if stdin is not a terminal (pipe or file)
-    // and we're not being called with known pre-push subcommands
-    if len(os.Args) > 1 {
-        // Check if the first argument is a known pre-push subcommand
-        firstArg := os.Args[1]
-        if firstArg == "test" || firstArg == "list-uses" || firstArg == "install" ||
-           firstArg == "-h" || firstArg == "--help" ||
-           firstArg == "-v" || firstArg == "--version" ||
-           firstArg == "-d" || firstArg == "--debug" {
-            return false
-        }
-    }
-    
-    // When Git calls the hook, it passes ref info via stdin
-    // Check if stdin is not a terminal (pipe or file)
-    stat, err := os.Stdin.Stat()
-    if err != nil {
-        return false
-    }
-    
-    // If stdin is not a character device, it's likely a pipe or file
-    return (stat.Mode() & os.ModeCharDevice) == 0
-}
-
-// runGitHook runs the pre-push hook when called by Git
-func runGitHook() error {
-    // Create context with cancellation
-    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-    defer cancel()
-    
-    // Read and parse ref information from stdin
-    refs, err := readGitRefs()
-    if err != nil {
-        return fmt.Errorf("failed to read Git refs: %w", err)
-    }
-    
-    // If no refs to push, exit successfully
-    if len(refs) == 0 {
-        return nil
-    }
-    
-    // Parse Git push information
-    pushInfo, err := parseGitPushInfo(refs)
-    if err != nil {
-        return fmt.Errorf("failed to parse Git push info: %w", err)
-    }
-    
-    // 1. Check if this is a delete operation - if so, skip all checks
-    if pushInfo.IsDelete {
-        fmt.Fprintf(os.Stderr, "Delete operation detected, skipping pre-push checks\n")
-        return nil
-    }
-    
-    // 2. Validate pushed tags for semantic versioning
-    if len(pushInfo.Tags) > 0 {
-        for _, tag := range pushInfo.Tags {
-            if err := validateTagSemantics(tag); err != nil {
-                return fmt.Errorf("invalid tag semantics for %s: %w", tag, err)
-            }
-        }
-    }
-    
-    // 3. Check if pushing tag/branch that is not current - if so, skip pre-push stage
-    if shouldSkipPrePushStage(pushInfo) {
-        fmt.Fprintf(os.Stderr, "Pushing tag/branch that is not current, skipping pre-push stage\n")
-        return nil
-    }
-    
-    // Load configuration using buildfab (supports includes)
-    buildfabConfig, err := buildfab.LoadConfig(".project.yml")
-    if err != nil {
-        return fmt.Errorf("failed to load configuration: %w", err)
-    }
-    
-    // Determine verbose and debug modes for Git hooks
-    hookVerboseLevel := getVerboseLevel()
-    hookDebug := isDebugEnabled()
-    
-    // Debug output (only when debug mode is enabled)
-    if hookDebug {
-        fmt.Fprintf(os.Stderr, "DEBUG: hookVerboseLevel=%d, hookDebug=%v\n", hookVerboseLevel, hookDebug)
-        fmt.Fprintf(os.Stderr, "DEBUG: env PRE_PUSH_VERBOSE=%s\n", os.Getenv("PRE_PUSH_VERBOSE"))
-        fmt.Fprintf(os.Stderr, "DEBUG: getVerboseLevel()=%d\n", getVerboseLevel())
-        fmt.Fprintf(os.Stderr, "DEBUG: pushInfo=%+v\n", pushInfo)
-    }
-    
-    // Create UI with Git hook specific settings
-    ui := ui.NewWithVerboseLevel(hookVerboseLevel, hookDebug)
-    
-    // Create buildfab executor with CLI version and enhanced Git variables
-    executor := preexec.BuildfabExecutorWithCLIVersion(buildfabConfig, ui, getVersion())
-    
-    // Enhance executor with Git push information for variable interpolation
-    executor.SetGitPushInfo(&preexec.GitPushInfo{
-        RemoteName: pushInfo.RemoteName,
-        RemoteURL:  pushInfo.RemoteURL,
-        Refs:       convertGitRefs(pushInfo.Refs),
-        Tags:       pushInfo.Tags,
-        Branches:   pushInfo.Branches,
-        IsDelete:   pushInfo.IsDelete,
-    })
-    
-    // Run pre-push stage
-    return executor.RunStage(ctx, "pre-push")
-}
-
-// GitRef represents a Git reference being pushed
-type GitRef struct {
-    LocalRef  string
-    LocalSHA  string
-    RemoteRef string
-    RemoteSHA string
-    IsDelete  bool
-    IsTag     bool
-    IsBranch  bool
-}
-
-// GitPushInfo contains information about the Git push operation
-type GitPushInfo struct {
-    RemoteName string
-    RemoteURL  string
-    Refs       []GitRef
-    Tags       []string
-    Branches   []string
-    IsDelete   bool
-}
-
-// readGitRefs reads Git ref information from stdin and parses it
-func readGitRefs() ([]GitRef, error) {
-    var refs []GitRef
-    scanner := bufio.NewScanner(os.Stdin)
-    
-    for scanner.Scan() {
-        line := scanner.Text()
-        if line != "" {
-            ref, err := parseGitRef(line)
-            if err != nil {
-                return nil, fmt.Errorf("failed to parse Git ref line %q: %w", line, err)
-            }
-            refs = append(refs, ref)
-        }
-    }
-    
-    if err := scanner.Err(); err != nil {
-        return nil, err
-    }
-    
-    return refs, nil
-}
-
-// parseGitRef parses a single Git ref line from stdin
-// Format: <local_ref> <local_sha> <remote_ref> <remote_sha>
-// For delete operations: <local_ref> <zero_sha> <remote_ref> <remote_sha>
-func parseGitRef(line string) (GitRef, error) {
-    parts := strings.Fields(line)
-    if len(parts) != 4 {
-        return GitRef{}, fmt.Errorf("invalid Git ref format, expected 4 fields, got %d", len(parts))
-    }
-    
-    localRef := parts[0]
-    localSHA := parts[1]
-    remoteRef := parts[2]
-    remoteSHA := parts[3]
-    
-    // Detect delete operation (local SHA is all zeros)
-    isDelete := localSHA == "0000000000000000000000000000000000000000"
-    
-    // Detect if it's a tag or branch
-    isTag := strings.HasPrefix(localRef, "refs/tags/")
-    isBranch := strings.HasPrefix(localRef, "refs/heads/")
-    
-    return GitRef{
-        LocalRef:  localRef,
-        LocalSHA:  localSHA,
-        RemoteRef: remoteRef,
-        RemoteSHA: remoteSHA,
-        IsDelete:  isDelete,
-        IsTag:     isTag,
-        IsBranch:  isBranch,
-    }, nil
-}
-
-// parseGitPushInfo parses Git push information from refs and command line arguments
-func parseGitPushInfo(refs []GitRef) (*GitPushInfo, error) {
-    if len(os.Args) < 2 {
-        return nil, fmt.Errorf("insufficient arguments for Git hook")
-    }
-    
-    remoteName := os.Args[1]
-    remoteURL := ""
-    if len(os.Args) > 2 {
-        remoteURL = os.Args[2]
-    }
-    
-    var tags []string
-    var branches []string
-    isDelete := false
-    
-    for _, ref := range refs {
-        if ref.IsDelete {
-            isDelete = true
-            // Extract tag/branch name from local ref
-            if ref.IsTag {
-                tagName := strings.TrimPrefix(ref.LocalRef, "refs/tags/")
-                tags = append(tags, tagName)
-            } else if ref.IsBranch {
-                branchName := strings.TrimPrefix(ref.LocalRef, "refs/heads/")
-                branches = append(branches, branchName)
-            }
-        } else {
-            // Extract tag/branch name from local ref
-            if ref.IsTag {
-                tagName := strings.TrimPrefix(ref.LocalRef, "refs/tags/")
-                tags = append(tags, tagName)
-            } else if ref.IsBranch {
-                branchName := strings.TrimPrefix(ref.LocalRef, "refs/heads/")
-                branches = append(branches, branchName)
-            }
-        }
-    }
-    
-    return &GitPushInfo{
-        RemoteName: remoteName,
-        RemoteURL:  remoteURL,
-        Refs:       refs,
-        Tags:       tags,
-        Branches:   branches,
-        IsDelete:   isDelete,
-    }, nil
-}
-
-// validateTagSemantics validates that a tag follows semantic versioning
-func validateTagSemantics(tag string) error {
-    // Use version library to validate tag semantics
-    detector := version.New()
-    
-    // Check if tag is a valid semantic version
-    if err := detector.ValidateVersion(tag); err != nil {
-        return fmt.Errorf("tag %s is not a valid semantic version: %w", tag, err)
-    }
-    
-    return nil
-}
-
-// shouldSkipPrePushStage determines if we should skip the pre-push stage
-// Logic:
-// - If pushing the branch we are on → this is our branch (don't skip)
-// - If pushing a tag that is on the current branch → this is our branch (don't skip)
-// - If pushing a tag that is NOT on the current branch → not our branch (skip)
-// - If pushing a branch that is NOT the current branch → not our branch (skip)
-func shouldSkipPrePushStage(pushInfo *GitPushInfo) bool {
-    // Get current branch
-    currentBranch, err := getCurrentBranch()
-    if err != nil {
-        // If we can't get current branch, don't skip (safer to run checks)
-        return false
-    }
-    
-    // If pushing branches, check if any branch matches current branch
-    if len(pushInfo.Branches) > 0 {
-        isOurBranch := false
-        for _, branch := range pushInfo.Branches {
-            if branch == currentBranch {
-                // This is our branch - don't skip
-                isOurBranch = true
-                break
-            }
-        }
-        if !isOurBranch {
-            // None of the pushed branches is our current branch - skip
-            return true
-        }
-        // At least one branch is our current branch - don't skip
-        return false
-    }
-    
-    // If pushing tags, check if any tag exists on current branch
-    if len(pushInfo.Tags) > 0 {
-        isTagOnBranch := false
-        for _, tag := range pushInfo.Tags {
-            if isTagOnCurrentBranch(tag, currentBranch) {
-                // Tag is on our branch - don't skip
-                isTagOnBranch = true
-                break
-            }
-        }
-        if !isTagOnBranch {
-            // None of the tags are on our current branch - skip
-            return true
-        }
-        // At least one tag is on our current branch - don't skip
-        return false
-    }
-    
-    // No branches or tags to push? Shouldn't happen, but don't skip
-    return false
-}
-
-// isTagOnCurrentBranch checks if a tag exists on the current branch
-// A tag is "on a branch" if the tag commit is reachable from HEAD
-// (meaning the tag is part of the branch's commit history)
-func isTagOnCurrentBranch(tag, currentBranch string) bool {
-    // Get the commit SHA of the tag
-    cmd := exec.Command("git", "rev-parse", tag+"^{commit}")
-    tagCommit, err := cmd.Output()
-    if err != nil {
-        // If tag doesn't exist or can't resolve, assume not on branch (safer to skip)
-        return false
-    }
-    tagCommitStr := strings.TrimSpace(string(tagCommit))
-    
-    // Check if tag commit is reachable from current branch HEAD
-    // This checks if tag is an ancestor of HEAD (tag is in branch history)
-    cmd = exec.Command("git", "merge-base", "--is-ancestor", tagCommitStr, "HEAD")
-    err = cmd.Run()
-    return err == nil // Exit code 0 means tag is ancestor of HEAD (tag is on branch)
-}
-
-// getCurrentBranch gets the current Git branch
-func getCurrentBranch() (string, error) {
-    cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
-    }
-    return strings.TrimSpace(string(output)), nil
-}
-
-// getCurrentTag gets the current Git tag
-func getCurrentTag() (string, error) {
-    cmd := exec.Command("git", "describe", "--tags", "--abbrev=0")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
-    }
-    return strings.TrimSpace(string(output)), nil
-}
-
-// convertGitRefs converts GitRef to preexec.GitRef
-func convertGitRefs(refs []GitRef) []preexec.GitRef {
-    result := make([]preexec.GitRef, len(refs))
-    for i, ref := range refs {
-        result[i] = preexec.GitRef{
-            LocalRef:  ref.LocalRef,
-            LocalSHA:  ref.LocalSHA,
-            RemoteRef: ref.RemoteRef,
-            RemoteSHA: ref.RemoteSHA,
-            IsDelete:  ref.IsDelete,
-            IsTag:     ref.IsTag,
-            IsBranch:  ref.IsBranch,
-        }
-    }
-    return result
+    rootCmd.AddCommand(uninstallCmd)
+    rootCmd.AddCommand(statusCmd)
+    rootCmd.AddCommand(historyCmd)
+    rootCmd.AddCommand(lastCmd)
+    rootCmd.AddCommand(cacheCmd)
+    cacheCmd.AddCommand(cacheClearCmd)
+    rootCmd.AddCommand(configCmd)
+    configCmd.AddCommand(configShowCmd)
+    configShowCmd.Flags().Bool("resolved", false, "merge the layers and annotate each key with the file that set it")
+    configCmd.AddCommand(configMigrateCmd)
+    configMigrateCmd.Flags().BoolP("yes", "y", false, "write the changes without asking")
+    configMigrateCmd.Flags().Bool("dry-run", false, "show the changes without writing them")
+    rootCmd.AddCommand(validateCmd)
+    rootCmd.AddCommand(initCmd)
+    initCmd.Flags().Bool("force", false, "replace an existing configuration")
+    initCmd.Flags().StringSlice("type", nil, "project types to generate actions for: "+strings.Join(projectTypeNames(config.ProjectTypes), ", ")+" (default: detected)")
+    initCmd.Flags().Bool("install", false, "install the Git pre-push hook without asking")
+    validateCmd.Flags().Bool("schema", false, "print the JSON Schema of the configuration")
+    
+    rootCmd.AddCommand(varsCmd)
+    varsCmd.Flags().Bool("json", false, "print the variables as JSON")
+    varsCmd.Flags().String("filter", "", "list only the variables whose names start with this prefix")
+    varsCmd.Flags().String("remote", "origin", "simulate a push to this remote")
+    varsCmd.Flags().StringArray("ref", nil, "simulate pushing <local-ref>[:<remote-ref>] (repeatable, empty local ref deletes)")
+    varsCmd.Flags().String("since", "", "simulate a push of the current branch to a remote that is at this commit")
+    
+    rootCmd.AddCommand(runCmd)
+    rootCmd.AddCommand(actionCmd)
+    
+    // Add stage command flags
+    for _, cmd := range []*cobra.Command{testCmd, runCmd} {
+        cmd.Flags().Bool("no-cache", false, "run all steps even if a cached result exists")
+        cmd.Flags().Bool("failed", false, "re-run only the steps that failed in the last run and the steps that require them")
+        cmd.Flags().StringSlice("only", nil, "run only these steps and the steps they require")
+        cmd.Flags().StringSlice("skip", nil, "skip these steps and the steps that require them")
+        cmd.Flags().Bool("staged", false, "run on the staged content, unstaged changes are stashed and restored afterwards")
+    }
+    
+    // Add push simulation flags
+    testCmd.Flags().String("remote", "origin", "simulate a push to this remote")
+    testCmd.Flags().String("remote-url", "", "URL of the simulated remote (default: the URL of --remote)")
+    testCmd.Flags().StringArray("ref", nil, "simulate pushing <local-ref>[:<remote-ref>] (repeatable, empty local ref deletes)")
+    testCmd.Flags().String("since", "", "simulate a push of the current branch to a remote that is at this commit")
+    
+    // Add last command flags
+    lastCmd.Flags().String("step", "", "print the full output log of a step")
+    lastCmd.Flags().String("run", "", "show the run with this ID instead of the most recent one")
+    
+    // Add hook command
+    rootCmd.AddCommand(hookCmd)
+    hookCmd.Flags().Bool("print-script", false, "print a wrapper hook script that runs this binary")
+    hookCmd.Flags().String("min-version", "", "fail if this binary is older than the version")
+    hookCmd.Flags().String("name", prePushHook, "Git hook to run: "+strings.Join(install.Hooks, ", "))
+    
+    // Add install command flags
+    installCmd.Flags().String("mode", string(install.ModeCopy), "install mode: 'copy' copies the binary, 'shim' writes a script that runs an installed binary")
+    installCmd.Flags().String("min-version", "", "minimum pre-push version the shim accepts (default: the current version)")
+    installCmd.Flags().Bool("pin", true, "pin the shim to the current binary, falling back to pre-push on PATH")
+    installCmd.Flags().Bool("force", false, "replace an installed hook of a newer pre-push version")
+    installCmd.Flags().Bool("global", false, "install the hook shim for all repositories of the user")
+    installCmd.Flags().String("target", string(install.GlobalTemplate), "global install target: 'template' uses init.templateDir, 'hooks-path' uses core.hooksPath")
+    installCmd.Flags().StringSlice("hooks", []string{prePushHook}, "Git hooks to install: "+strings.Join(install.Hooks, ", "))
+    uninstallCmd.Flags().Bool("global", false, "remove the global hooks and the Git configuration set by install --global")
+    uninstallCmd.Flags().StringSlice("hooks", install.Hooks, "Git hooks to remove")
 }
 
 // runRoot handles the root command (print usage)
@@ -660,78 +272,31 @@ func runRoot(cmd *cobra.Command, args []string) error {
         fmt.Printf("%s\n", getVersion())
         return nil
     }
+    if buildInfoFlag, _ := cmd.Flags().GetBool("build-info"); buildInfoFlag {
+        output, err := json.MarshalIndent(getBuildInfo(), "", "  ")
+        if err != nil {
+            return err
+        }
+        fmt.Printf("%s\n", output)
+        return nil
+    }
     
     // Print usage when no command is provided
     return cmd.Usage()
 }
 
-
-// runTest runs all checks in dry-run mode
-func runTest(cmd *cobra.Command, args []string) error {
-    // Create context with cancellation
-    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-    defer cancel()
-
-    // Load configuration using buildfab (supports includes)
-    buildfabConfig, err := buildfab.LoadConfig(".project.yml")
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-        os.Exit(1)
+// confirm asks a yes/no question on the terminal, it fails if stdin is not
+// a terminal
+func confirm(question string) (bool, error) {
+    if !term.IsTerminal(int(os.Stdin.Fd())) {
+        return false, fmt.Errorf("cannot ask for confirmation, stdin is not a terminal (use --yes)")
     }
     
-    // Determine verbose and debug modes for Git hooks
-    hookVerboseLevel := getVerboseLevel()
-    hookDebug := isDebugEnabled()
-    
-    // Debug output (remove in production)
-    if hookDebug {
-        fmt.Fprintf(os.Stderr, "DEBUG: hookVerboseLevel=%d, hookDebug=%v\n", hookVerboseLevel, hookDebug)
-        fmt.Fprintf(os.Stderr, "DEBUG: env PRE_PUSH_VERBOSE=%s\n", os.Getenv("PRE_PUSH_VERBOSE"))
-        fmt.Fprintf(os.Stderr, "DEBUG: getVerboseLevel()=%d\n", getVerboseLevel())
-    }
-    
-    // Variables will be resolved by buildfab automatically
-    
-    // Create UI with detected verbose and debug modes
-    ui := ui.NewWithVerboseLevel(hookVerboseLevel, hookDebug)
-    
-    // Create buildfab executor with CLI version
-    executor := preexec.BuildfabExecutorWithCLIVersion(buildfabConfig, ui, getVersion())
-    
-    // Run pre-push stage
-    if err := executor.RunStage(ctx, "pre-push"); err != nil {
-        os.Exit(1)
-    }
-    
-    return nil
-}
-
-// runListUses lists all available built-in actions
-func runListUses(cmd *cobra.Command, args []string) error {
-    uses := prepush.ListBuiltInActions()
-    
-    fmt.Println("Available built-in actions:")
-    fmt.Println()
-    
-    for name, description := range uses {
-        fmt.Printf("  %-20s %s\n", name, description)
+    fmt.Printf("%s [y/N] ", question)
+    answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && answer == "" {
+        return false, nil
     }
-    
-    return nil
+    answer = strings.ToLower(strings.TrimSpace(answer))
+    return answer == "y" || answer == "yes", nil
 }
-
-// runInstall handles the install command (check and update Git hook)
-func runInstall(cmd *cobra.Command, args []string) error {
-    // Check and update Git hook if needed
-    updated, err := checkAndUpdateGitHook()
-    if err != nil {
-        return fmt.Errorf("failed to update Git hook: %w", err)
-    }
-    
-    // Only print success message if we actually updated the hook
-    if updated {
-        fmt.Printf("Git pre-push hook installed successfully (version %s)\n", getVersion())
-    }
-    
-    return nil
-}
\ No newline at end of file
@@ -0,0 +1,31 @@
+package main
+
+import (
+    "os"
+    "testing"
+)
+
+// redirectStdin replaces stdin with a file for the rest of the test
+func redirectStdin(t *testing.T, path string) {
+    file, err := os.Open(path)
+    if err != nil {
+        t.Fatalf("Failed to open %s: %v", path, err)
+    }
+    oldStdin := os.Stdin
+    os.Stdin = file
+    t.Cleanup(func() {
+        os.Stdin = oldStdin
+        file.Close()
+    })
+}
+
+// TestConfirmNotTerminal tests that confirmation fails without a terminal,
+// including /dev/null, which is a character device
+func TestConfirmNotTerminal(t *testing.T) {
+    for _, path := range []string{os.DevNull, os.Args[0]} {
+        redirectStdin(t, path)
+        if confirmed, err := confirm("Continue?"); err == nil || confirmed {
+            t.Errorf("Expected confirmation to fail with stdin from %s, got %v (%v)", path, confirmed, err)
+        }
+    }
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/spf13/cobra"
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/history"
+    "github.com/AlexBurnes/pre-push/internal/ui"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+    Use:   "test",
+    Short: "Run all checks in dry-run mode",
+    Long: `Run all configured checks in dry-run mode without installing or updating
+the pre-push hook. This is useful for testing your configuration before
+pushing changes.`,
+    RunE: runTest,
+}
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+    Use:   "run <stage>",
+    Short: "Run a stage from the configuration",
+    Long: `Run any stage defined in .project.yml with the same variables the hook uses.
+Use --only to run selected steps together with the steps they require, and
+--skip to leave out steps together with the steps that require them.`,
+    Args: cobra.ExactArgs(1),
+    RunE: runRun,
+}
+
+// actionCmd represents the action command
+var actionCmd = &cobra.Command{
+    Use:   "action <name>",
+    Short: "Run a single action from the configuration",
+    Long: `Run a single action defined in .project.yml with the same variables the hook
+uses. This is useful to reproduce one failing step.`,
+    Args: cobra.ExactArgs(1),
+    RunE: runActionCommand,
+}
+
+// listUsesCmd represents the list-uses command
+var listUsesCmd = &cobra.Command{
+    Use:   "list-uses",
+    Short: "List available built-in actions",
+    Long: `List all available built-in actions that can be used in the 'uses' field
+of your configuration. Each action includes a brief description of what it does.`,
+    RunE: runListUses,
+}
+
+// runTest runs all checks in dry-run mode
+func runTest(cmd *cobra.Command, args []string) error {
+    remoteName, _ := cmd.Flags().GetString("remote")
+    remoteURL, _ := cmd.Flags().GetString("remote-url")
+    specs, _ := cmd.Flags().GetStringArray("ref")
+    since, _ := cmd.Flags().GetString("since")
+    
+    // Without push simulation flags only the stage is run
+    if !cmd.Flags().Changed("remote") && remoteURL == "" && len(specs) == 0 && since == "" {
+        return runStageCommand(cmd, "pre-push")
+    }
+    
+    return runSimulatedPush(cmd, remoteName, remoteURL, specs, since)
+}
+
+// runSimulatedPush runs the full hook flow for a simulated push
+func runSimulatedPush(cmd *cobra.Command, remoteName, remoteURL string, specs []string, since string) error {
+    cmd.SilenceUsage = true
+    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer cancel()
+    
+    if remoteURL == "" {
+        remoteURL, _ = gitOutput("remote", "get-url", remoteName)
+    }
+    
+    refs, err := simulateGitRefs(remoteName, specs, since)
+    if err != nil {
+        return err
+    }
+    
+    if isDebugEnabled() {
+        for _, ref := range refs {
+            fmt.Fprintf(os.Stderr, "DEBUG: simulated ref: %s %s %s %s\n", ref.LocalRef, ref.LocalSHA, ref.RemoteRef, ref.RemoteSHA)
+        }
+    }
+    
+    return runHookFlow(ctx, refs, remoteName, remoteURL, getStageFlags(cmd))
+}
+
+// runRun runs the stage given as argument
+func runRun(cmd *cobra.Command, args []string) error {
+    return runStageCommand(cmd, args[0])
+}
+
+// stageFlags are the step selection flags of the test and run commands
+type stageFlags struct {
+    only    []string
+    skip    []string
+    noCache bool
+    staged  bool
+    failed  bool
+}
+
+// getStageFlags gets the step selection flags of a command
+func getStageFlags(cmd *cobra.Command) *stageFlags {
+    flags := &stageFlags{}
+    flags.only, _ = cmd.Flags().GetStringSlice("only")
+    flags.skip, _ = cmd.Flags().GetStringSlice("skip")
+    flags.noCache, _ = cmd.Flags().GetBool("no-cache")
+    flags.staged, _ = cmd.Flags().GetBool("staged")
+    flags.failed, _ = cmd.Flags().GetBool("failed")
+    return flags
+}
+
+// apply configures the executor for the flags. It returns true when there is
+// nothing to run: --failed without failed steps in the last run.
+func (f *stageFlags) apply(ctx context.Context, executor *preexec.BuildfabExecutor, stageName string) (bool, error) {
+    executor.SetStepFilter(f.only, f.skip)
+    if err := executor.ValidateStepFilter(stageName); err != nil {
+        return false, err
+    }
+    if !f.noCache {
+        enableCache(ctx, executor)
+    }
+    if f.staged {
+        executor.SetStagedOnly(true)
+    }
+    
+    // Re-run only the failed steps of the last run
+    if f.failed {
+        previous, err := lastStageResults(ctx, stageName)
+        if err != nil {
+            return false, err
+        }
+        if !hasFailedStep(previous) {
+            fmt.Println("No failed steps in the last run")
+            return true, nil
+        }
+        executor.SetPreviousResults(previous)
+    }
+    return false, nil
+}
+
+// runStageCommand runs a stage with the flags of the test and run commands
+func runStageCommand(cmd *cobra.Command, stageName string) error {
+    cmd.SilenceUsage = true
+    // Create context with cancellation
+    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer cancel()
+
+    // Load configuration using buildfab (supports includes)
+    project, err := loadConfig(ctx)
+    if err != nil {
+        return err
+    }
+    buildfabConfig := project.Config
+    
+    // Determine verbose and debug modes for Git hooks
+    hookVerboseLevel := getVerboseLevel()
+    hookDebug := isDebugEnabled()
+    
+    // Debug output (remove in production)
+    if hookDebug {
+        fmt.Fprintf(os.Stderr, "DEBUG: hookVerboseLevel=%d, hookDebug=%v\n", hookVerboseLevel, hookDebug)
+        fmt.Fprintf(os.Stderr, "DEBUG: env PRE_PUSH_VERBOSE=%s\n", os.Getenv("PRE_PUSH_VERBOSE"))
+        fmt.Fprintf(os.Stderr, "DEBUG: getVerboseLevel()=%d\n", getVerboseLevel())
+    }
+    
+    // Variables will be resolved by buildfab automatically
+    
+    if _, exists := buildfabConfig.GetStage(stageName); !exists {
+        return fmt.Errorf("stage not found: %s", stageName)
+    }
+    
+    // Create UI with detected verbose and debug modes
+    ui := ui.NewWithVerboseLevel(hookVerboseLevel, hookDebug)
+    
+    // Create buildfab executor with CLI version
+    executor := preexec.BuildfabExecutorWithCLIVersion(buildfabConfig, ui, getVersion())
+    executor.SetOutputOptions(getOutputOptions())
+    executor.SetConfigPath(describeConfig(project))
+    executor.SetMasker(project.Settings.Masker())
+    if done, err := getStageFlags(cmd).apply(ctx, executor, stageName); done || err != nil {
+        return err
+    }
+    
+    // Run the stage and record the results
+    return runStageRecorded(ctx, executor, stageName, nil, false)
+}
+
+// runActionCommand runs a single action
+func runActionCommand(cmd *cobra.Command, args []string) error {
+    cmd.SilenceUsage = true
+    ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer cancel()
+    
+    project, err := loadConfig(ctx)
+    if err != nil {
+        return err
+    }
+    buildfabConfig := project.Config
+    
+    actionName := args[0]
+    if _, exists := buildfabConfig.GetAction(actionName); !exists {
+        return fmt.Errorf("action not found: %s", actionName)
+    }
+    
+    ui := ui.NewWithVerboseLevel(getVerboseLevel(), isDebugEnabled())
+    executor := preexec.BuildfabExecutorWithCLIVersion(buildfabConfig, ui, getVersion())
+    executor.SetOutputOptions(getOutputOptions())
+    executor.SetConfigPath(describeConfig(project))
+    executor.SetMasker(project.Settings.Masker())
+    
+    return executor.RunAction(ctx, actionName)
+}
+
+// runListUses lists all available built-in actions
+func runListUses(cmd *cobra.Command, args []string) error {
+    uses := prepush.ListBuiltInActions()
+    
+    fmt.Println("Available built-in actions:")
+    fmt.Println()
+    
+    for name, description := range uses {
+        fmt.Printf("  %-20s %s\n", name, description)
+    }
+    
+    return nil
+}
+
+// runStageRecorded runs a stage and records its results in the run history.
+// Logs of failed steps are written into the run directory. Runs of the Git
+// hook are recorded as such, simulated pushes record their refs as tests.
+func runStageRecorded(ctx context.Context, executor *preexec.BuildfabExecutor, stageName string, pushInfo *GitPushInfo, hook bool) error {
+    store, err := openHistoryStore(ctx)
+    if err != nil || getHistoryRetention() == 0 {
+        if err != nil && isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Run history disabled: %v\n", err)
+        }
+        return executor.RunStage(ctx, stageName)
+    }
+    
+    run, err := store.NewRun(stageName)
+    if err != nil {
+        if isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Could not create run record: %v\n", err)
+        }
+        return executor.RunStage(ctx, stageName)
+    }
+    
+    // Keep logs of failed steps with the run unless a log directory is set explicitly
+    opts := getOutputOptions()
+    if opts.LogDir == "" {
+        opts.LogDir = run.Dir()
+    }
+    executor.SetOutputOptions(opts)
+    
+    start := time.Now()
+    runErr := executor.RunStage(ctx, stageName)
+    run.Duration = time.Since(start)
+    
+    // A stage that failed before its steps ran, on a template or a stash
+    // error, is not a run
+    if runErr != nil && len(executor.Results()) == 0 {
+        if err := store.Discard(run); err != nil && isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Could not discard run record: %v\n", err)
+        }
+        return runErr
+    }
+    run.Success = runErr == nil
+    
+    run.Hook = hook
+    if pushInfo != nil {
+        run.Remote = pushInfo.RemoteName
+        run.RemoteURL = pushInfo.RemoteURL
+        for _, ref := range pushInfo.Refs {
+            run.Refs = append(run.Refs, history.Ref{
+                LocalRef:  ref.LocalRef,
+                LocalSHA:  ref.LocalSHA,
+                RemoteRef: ref.RemoteRef,
+                RemoteSHA: ref.RemoteSHA,
+            })
+        }
+    }
+    
+    for _, result := range executor.Results() {
+        if result.Status == prepush.StatusError {
+            run.Success = false
+        }
+        run.Steps = append(run.Steps, history.Step{
+            Name:     result.Name,
+            Status:   result.Status.String(),
+            Message:  result.Message,
+            Duration: result.Duration,
+            Log:      result.LogPath,
+        })
+    }
+    
+    if err := store.Save(run); err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: failed to record run: %v\n", err)
+    } else if !run.Success {
+        fmt.Fprintf(os.Stderr, "Run 'pre-push last' to review this run (%s)\n", run.ID)
+    }
+    
+    return runErr
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/install"
+    "github.com/AlexBurnes/pre-push/internal/version"
+)
+
+// checkMinVersion checks that this binary is not older than the minimum version a hook requires
+func checkMinVersion(minVersion string) error {
+    if minVersion == "" {
+        return nil
+    }
+    
+    result, err := version.New().CompareVersions(getVersion(), minVersion)
+    if err != nil {
+        // Development builds have no semantic version
+        if isDebugEnabled() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Skipping minimum version check: %v\n", err)
+        }
+        return nil
+    }
+    if result < 0 {
+        return fmt.Errorf("pre-push %s is older than version %s required by the hook, please upgrade pre-push", getVersion(), minVersion)
+    }
+    return nil
+}
+
+// Self-update policies of an outdated hook
+const (
+    selfUpdateWarn   = "warn"
+    selfUpdateRefuse = "refuse"
+    selfUpdateUpdate = "update"
+)
+
+// installedBinary is a pre-push binary found on PATH or in scripts/
+type installedBinary struct {
+    Path    string
+    Version string
+}
+
+// getSelfUpdatePolicy returns what an outdated hook does. PRE_PUSH_SELF_UPDATE
+// overrides self_update of the project settings; the default is to warn.
+func getSelfUpdatePolicy(settings config.Settings) string {
+    policy := settings.SelfUpdate
+    if env := os.Getenv("PRE_PUSH_SELF_UPDATE"); env != "" {
+        policy = env
+    }
+    
+    switch policy = strings.ToLower(policy); policy {
+    case selfUpdateWarn, selfUpdateRefuse, selfUpdateUpdate:
+        return policy
+    case "":
+        return selfUpdateWarn
+    default:
+        fmt.Fprintf(os.Stderr, "Warning: unknown self-update policy %q, using %q\n", policy, selfUpdateWarn)
+        return selfUpdateWarn
+    }
+}
+
+// checkHookVersion compares the running hook with min_version of the project
+// settings, or without it with the newest pre-push binary on PATH or in
+// scripts/. An outdated hook warns, refuses to run or is refreshed according
+// to the self-update policy. It returns the binary that refreshed the hook and
+// should run this hook, or an empty string to continue.
+func checkHookVersion(hookName string, project *config.Project) (string, error) {
+    settings := project.Settings
+    detector := version.New()
+    current := getVersion()
+    
+    // Development builds have no semantic version
+    if _, err := detector.CompareVersions(current, current); err != nil {
+        return "", nil
+    }
+    
+    // Other binaries are run with --build-info, they are only looked for
+    // when min_version is not set or a newer binary is needed to update
+    var newest installedBinary
+    required, source := settings.MinVersion, project.Path
+    if required == "" {
+        newest = findNewestBinary()
+        required, source = newest.Version, newest.Path
+    }
+    if required == "" {
+        return "", nil
+    }
+    
+    if result, err := detector.CompareVersions(current, required); err != nil || result >= 0 {
+        return "", nil
+    }
+    
+    message := fmt.Sprintf("pre-push %s is older than %s required by %s", current, required, source)
+    switch getSelfUpdatePolicy(settings) {
+    case selfUpdateRefuse:
+        return "", fmt.Errorf("%s, run 'pre-push install' with a newer binary", message)
+    case selfUpdateUpdate:
+        if newest.Path == "" {
+            newest = findNewestBinary()
+        }
+        if result, err := detector.CompareVersions(newest.Version, required); newest.Path == "" || err != nil || result < 0 {
+            fmt.Fprintf(os.Stderr, "Warning: %s, no newer pre-push binary found to update the hook\n", message)
+            return "", nil
+        }
+        if err := refreshHook(hookName, newest.Path); err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: %s, failed to update the hook: %v\n", message, err)
+            return "", nil
+        }
+        fmt.Fprintf(os.Stderr, "%s, hook updated to %s from %s\n", message, newest.Version, newest.Path)
+        return newest.Path, nil
+    default:
+        fmt.Fprintf(os.Stderr, "Warning: %s, run 'pre-push install' with a newer binary\n", message)
+        return "", nil
+    }
+}
+
+// findNewestBinary returns the newest pre-push binary on PATH or in scripts/
+// other than the running one
+func findNewestBinary() installedBinary {
+    self, _ := getCurrentBinaryPath()
+    if resolved, err := filepath.EvalSymlinks(self); err == nil {
+        self = resolved
+    }
+    
+    var candidates []string
+    if path, err := exec.LookPath(appName); err == nil {
+        candidates = append(candidates, path)
+    }
+    candidates = append(candidates, filepath.Join("scripts", appName))
+    
+    detector := version.New()
+    var newest installedBinary
+    for _, candidate := range candidates {
+        path, err := filepath.Abs(candidate)
+        if err != nil {
+            continue
+        }
+        if resolved, err := filepath.EvalSymlinks(path); err != nil || resolved == self {
+            continue
+        }
+        
+        info, err := install.ReadBuildInfo(path)
+        if err != nil {
+            continue
+        }
+        if newest.Path != "" {
+            if result, err := detector.CompareVersions(info.Version, newest.Version); err != nil || result <= 0 {
+                continue
+            }
+        } else if _, err := detector.CompareVersions(info.Version, info.Version); err != nil {
+            continue
+        }
+        newest = installedBinary{Path: path, Version: info.Version}
+    }
+    return newest
+}
+
+// refreshHook reinstalls the hook from a newer binary in the same install
+// mode, so the newer binary's updateGitHook replaces this one
+func refreshHook(hookName, binaryPath string) error {
+    args := []string{"install"}
+    if hookName != prePushHook {
+        args = append(args, "--hooks", hookName)
+    }
+    if installer, err := newInstaller(hookName); err == nil {
+        if status, err := installer.Status(); err == nil && status.Kind == install.HookShim {
+            args = append(args, "--mode", string(install.ModeShim))
+        }
+    }
+    
+    output, err := exec.Command(binaryPath, args...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// runHookBinary runs a hook through another pre-push binary in hook mode
+func runHookBinary(ctx context.Context, binaryPath, hookName string, args []string, input string) error {
+    hookArgs := []string{"hook"}
+    if hookName != prePushHook {
+        hookArgs = append(hookArgs, "--name", hookName)
+    }
+    cmd := exec.CommandContext(ctx, binaryPath, append(hookArgs, args...)...)
+    cmd.Stdin = strings.NewReader(input)
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("%s checks failed: %w", hookName, err)
+    }
+    return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/spf13/cobra"
+    preexec "github.com/AlexBurnes/pre-push/internal/exec"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/install"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+    Use:   "status",
+    Short: "Show the state of the Git pre-push hook",
+    Long: `Show where the Git pre-push hook is installed, how (copy, shim or another
+tool's hook), the version of the binary it runs and whether that binary
+matches the current one, a chained pre-push.local hook, the configuration
+file with its stages, and the buildfab binary used for container actions.`,
+    RunE: runStatus,
+}
+
+// runStatus reports the state of the Git hook, the configuration and buildfab
+func runStatus(cmd *cobra.Command, args []string) error {
+    // The pre-push hook is always reported, other hooks when they are present
+    for _, hookName := range install.Hooks {
+        installer, err := newInstaller(hookName)
+        if err != nil {
+            return fmt.Errorf("failed to get git hook path: %w", err)
+        }
+        
+        status, err := installer.Status()
+        if err != nil {
+            return err
+        }
+        if hookName != prePushHook && status.Kind == install.HookNone && status.LocalHook == "" {
+            continue
+        }
+        
+        fmt.Printf("Hook:      %s (%s)\n", status.Path, status.Kind)
+        switch status.Kind {
+        case install.HookCopy, install.HookShim:
+            printHookBinaryStatus(installer, status)
+        case install.HookForeign:
+            fmt.Printf("           installed by another tool, run 'pre-push install --hooks %s' to chain it\n", hookName)
+        }
+        if status.LocalHook != "" {
+            fmt.Printf("Chained:   %s\n", status.LocalHook)
+        }
+    }
+    
+    // Configuration
+    if configPath, err := findConfig(context.Background()); err != nil {
+        fmt.Printf("Config:    %v\n", err)
+    } else if configPath == "" {
+        fmt.Printf("Config:    not found (tried %s)\n", strings.Join(config.ConfigFiles, ", "))
+    } else if project, err := loadProject(configPath); err != nil {
+        fmt.Printf("Config:    %s (invalid: %v)\n", configPath, err)
+    } else {
+        fmt.Printf("Config:    %s\n", configPath)
+        for _, layer := range project.Layers {
+            fmt.Printf("Layer:     %s\n", layer)
+        }
+        stages := make([]string, 0, len(project.Config.Stages))
+        for name := range project.Config.Stages {
+            stages = append(stages, name)
+        }
+        sort.Strings(stages)
+        fmt.Printf("Stages:    %s\n", strings.Join(stages, ", "))
+    }
+    
+    if buildfabPath, err := preexec.FindBuildfabBinary(); err != nil {
+        fmt.Printf("Buildfab:  not found (container actions using run_action will not work)\n")
+    } else {
+        fmt.Printf("Buildfab:  %s\n", buildfabPath)
+    }
+    
+    return nil
+}
+
+// printHookBinaryStatus reports the binary a pre-push hook runs, its version
+// and whether it matches the current binary
+func printHookBinaryStatus(installer *install.Installer, status install.HookStatus) {
+    if status.Kind == install.HookShim {
+        if status.Shim.BinaryPath != "" {
+            pinned := "changed since install"
+            if digest, err := install.FileSHA256(status.Shim.BinaryPath); err != nil {
+                pinned = "missing"
+            } else if digest == status.Shim.BinarySHA256 {
+                pinned = "sha256 matches install"
+            }
+            fmt.Printf("Pinned:    %s (%s)\n", status.Shim.BinaryPath, pinned)
+        }
+        if status.Shim.MinVersion != "" {
+            fmt.Printf("Requires:  %s or newer\n", status.Shim.MinVersion)
+        }
+    }
+    
+    if status.Binary == "" {
+        fmt.Printf("Binary:    not found\n")
+        return
+    }
+    fmt.Printf("Binary:    %s\n", status.Binary)
+    if info, err := install.ReadBuildInfo(status.Binary); err != nil {
+        fmt.Printf("Version:   unknown\n")
+    } else if info.Commit != "" {
+        fmt.Printf("Version:   %s (commit %s, built %s)\n", info.Version, shortDigest(info.Commit), info.Date)
+    } else {
+        fmt.Printf("Version:   %s\n", info.Version)
+    }
+    
+    current := "no, run 'pre-push install' to update"
+    hookDigest, err := install.FileSHA256(status.Binary)
+    if err == nil {
+        if currentDigest, err := install.FileSHA256(installer.BinaryPath()); err == nil && hookDigest == currentDigest {
+            current = "yes"
+        }
+    }
+    fmt.Printf("Current:   %s (sha256 %s)\n", current, shortDigest(hookDigest))
+}
+
+// shortDigest shortens a digest or commit for display
+func shortDigest(digest string) string {
+    if len(digest) > 12 {
+        return digest[:12]
+    }
+    return digest
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/AlexBurnes/pre-push/internal/config"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+    Use:   "validate [file]",
+    Short: "Check the configuration for problems",
+    Long: `Check the project configuration and the files it includes against the
+configuration schema and report every problem at once as file:line:col.
+Besides unknown keys and invalid values it reports references to undefined
+actions and stages, require targets that are not steps of the stage and
+require cycles.
+
+Without a file the configuration of the repository is checked. Use --schema
+to print the JSON Schema, for example for editor completion.`,
+    Args: cobra.MaximumNArgs(1),
+    RunE: runValidate,
+}
+
+// runValidate reports the problems of the configuration
+func runValidate(cmd *cobra.Command, args []string) error {
+    if printSchema, _ := cmd.Flags().GetBool("schema"); printSchema {
+        fmt.Print(string(config.Schema))
+        return nil
+    }
+    
+    var configPath string
+    if len(args) > 0 {
+        configPath = args[0]
+    } else {
+        var err error
+        configPath, err = findConfig(cmd.Context())
+        if err != nil {
+            return err
+        }
+        if configPath == "" {
+            return fmt.Errorf("no configuration file found (tried %s)", strings.Join(config.ConfigFiles, ", "))
+        }
+    }
+    
+    diagnostics, err := config.Validate(configPath)
+    if err != nil {
+        return err
+    }
+    if len(diagnostics) == 0 {
+        fmt.Printf("%s is valid\n", configPath)
+        return nil
+    }
+    
+    for _, diagnostic := range diagnostics {
+        fmt.Println(diagnostic)
+    }
+    cmd.SilenceUsage = true
+    return fmt.Errorf("%d problem(s) found in %s", len(diagnostics), configPath)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+
+    "github.com/spf13/cobra"
+    "github.com/AlexBurnes/pre-push/internal/config"
+    "github.com/AlexBurnes/pre-push/internal/vars"
+)
+
+// varsCmd represents the vars command
+var varsCmd = &cobra.Command{
+    Use:   "vars",
+    Short: "List the variables available to the configuration",
+    Long: `List every variable available to ${{ }} references and step conditions
+with its value and source: platform, version, git, push, hook or env.
+Values of secrets (env.*_TOKEN, env.*_PASSWORD, env.*SECRET*) are masked.
+
+Use --filter to list only the variables whose names start with a prefix and
+--json for machine readable output. --ref and --since simulate a push like
+'pre-push test' does, so the push variables (tag, tags, branch, branches)
+are those of that push.`,
+    Args: cobra.NoArgs,
+    RunE: runVars,
+}
+
+// runVars lists the variables with their values and sources
+func runVars(cmd *cobra.Command, args []string) error {
+    cmd.SilenceUsage = true
+    asJSON, _ := cmd.Flags().GetBool("json")
+    prefix, _ := cmd.Flags().GetString("filter")
+    remoteName, _ := cmd.Flags().GetString("remote")
+    specs, _ := cmd.Flags().GetStringArray("ref")
+    since, _ := cmd.Flags().GetString("since")
+    
+    // Secrets declared in the configuration, if there is one
+    settings := config.Settings{}
+    configPath, err := findConfig(cmd.Context())
+    if err != nil {
+        return err
+    }
+    if configPath != "" {
+        project, err := loadProject(configPath)
+        if err != nil {
+            return err
+        }
+        settings = project.Settings
+    }
+    masker := settings.Masker()
+    
+    provider := &vars.Provider{Mask: masker}
+    if len(specs) > 0 || since != "" {
+        refs, err := simulateGitRefs(remoteName, specs, since)
+        if err != nil {
+            return err
+        }
+        pushInfo, err := parseGitPushInfo(refs, remoteName, "")
+        if err != nil {
+            return err
+        }
+        provider.Push = &vars.Push{Tags: pushInfo.Tags, Branches: pushInfo.Branches}
+    }
+    
+    collected := provider.Collect(cmd.Context())
+    masker.Collect(vars.Map(collected))
+    variables := vars.Filter(collected, prefix)
+    for i := range variables {
+        variables[i].Value = masker.Value(variables[i].Name, variables[i].Value)
+    }
+    
+    if asJSON {
+        if variables == nil {
+            variables = []vars.Variable{}
+        }
+        output, err := json.MarshalIndent(variables, "", "  ")
+        if err != nil {
+            return fmt.Errorf("failed to encode variables: %w", err)
+        }
+        fmt.Println(string(output))
+        return nil
+    }
+    
+    if len(variables) == 0 {
+        fmt.Println("No variables found")
+        return nil
+    }
+    width := len("NAME")
+    for _, v := range variables {
+        width = max(width, len(v.Name))
+    }
+    fmt.Printf("  %-*s %-8s %s\n", width, "NAME", "SOURCE", "VALUE")
+    for _, v := range variables {
+        fmt.Printf("  %-*s %-8s %s\n", width, v.Name, v.Source, strconv.Quote(v.Value))
+    }
+    return nil
+}
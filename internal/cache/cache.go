@@ -0,0 +1,178 @@
+// Package cache provides a step result cache for pre-push runs.
+package cache
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Entry represents a cached successful step result
+type Entry struct {
+    Key       string        `json:"key"`
+    Step      string        `json:"step"`
+    Timestamp time.Time     `json:"timestamp"`
+    Duration  time.Duration `json:"duration"`
+}
+
+// Cache stores step results in a directory (usually .git/pre-push/cache)
+type Cache struct {
+    dir string
+}
+
+// New creates a new cache in the specified directory
+func New(dir string) *Cache {
+    return &Cache{
+        dir: dir,
+    }
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+    return c.dir
+}
+
+// Get returns the cached entry for a key
+func (c *Cache) Get(key string) (*Entry, bool) {
+    data, err := os.ReadFile(c.path(key))
+    if err != nil {
+        return nil, false
+    }
+
+    var entry Entry
+    if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+        return nil, false
+    }
+
+    return &entry, true
+}
+
+// Put stores an entry in the cache
+func (c *Cache) Put(entry Entry) error {
+    if err := os.MkdirAll(c.dir, 0755); err != nil {
+        return fmt.Errorf("failed to create cache directory: %w", err)
+    }
+
+    data, err := json.MarshalIndent(entry, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode cache entry: %w", err)
+    }
+
+    // Write through a temp file so concurrent runs never read partial entries
+    tmp, err := os.CreateTemp(c.dir, ".entry-*")
+    if err != nil {
+        return fmt.Errorf("failed to write cache entry: %w", err)
+    }
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        os.Remove(tmp.Name())
+        return fmt.Errorf("failed to write cache entry: %w", err)
+    }
+    tmp.Close()
+
+    if err := os.Rename(tmp.Name(), c.path(entry.Key)); err != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("failed to write cache entry: %w", err)
+    }
+
+    return nil
+}
+
+// Clear removes all cached entries and returns the number of entries removed
+func (c *Cache) Clear() (int, error) {
+    entries, err := os.ReadDir(c.dir)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return 0, nil
+        }
+        return 0, fmt.Errorf("failed to read cache directory: %w", err)
+    }
+
+    count := 0
+    for _, entry := range entries {
+        if entry.IsDir() {
+            continue
+        }
+        if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+            return count, fmt.Errorf("failed to remove cache entry %s: %w", entry.Name(), err)
+        }
+        if strings.HasSuffix(entry.Name(), ".json") {
+            count++
+        }
+    }
+
+    return count, nil
+}
+
+// path returns the file path of a cache entry
+func (c *Cache) path(key string) string {
+    return filepath.Join(c.dir, key+".json")
+}
+
+// Key computes a cache key from its parts
+func Key(parts ...string) string {
+    hash := sha256.New()
+    for _, part := range parts {
+        hash.Write([]byte(part))
+        hash.Write([]byte{0})
+    }
+    return hex.EncodeToString(hash.Sum(nil))
+}
+
+// TreeHash returns a hash of the committed content a step depends on. Without
+// paths it is the tree hash of HEAD, otherwise a hash of the entries matching
+// the paths, which are directories, files or globs (* within a directory,
+// ** across directories). The second result is false if the working tree has
+// uncommitted changes in those paths, or if the paths match no file, the
+// content is then not represented by the hash and the step must not be cached.
+func TreeHash(ctx context.Context, paths []string) (string, bool, error) {
+    pathspecs := make([]string, len(paths))
+    for i, path := range paths {
+        pathspecs[i] = ":(glob)" + path
+    }
+
+    var hash string
+    if len(paths) == 0 {
+        output, err := git(ctx, "rev-parse", "HEAD^{tree}")
+        if err != nil {
+            return "", false, err
+        }
+        hash = output
+    } else {
+        // The index is HEAD when the paths have no uncommitted changes
+        args := append([]string{"ls-files", "--stage", "--"}, pathspecs...)
+        output, err := git(ctx, args...)
+        if err != nil {
+            return "", false, err
+        }
+        if output == "" {
+            return "", false, nil
+        }
+        hash = Key(output)
+    }
+
+    args := append([]string{"status", "--porcelain", "--untracked-files=all", "--"}, pathspecs...)
+    status, err := git(ctx, args...)
+    if err != nil {
+        return "", false, err
+    }
+
+    return hash, status == "", nil
+}
+
+// git runs a git command and returns its trimmed output
+func git(ctx context.Context, args ...string) (string, error) {
+    cmd := exec.CommandContext(ctx, "git", args...)
+    output, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("git %s failed: %w", args[0], err)
+    }
+    return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestCachePutAndGet(t *testing.T) {
+    c := New(filepath.Join(t.TempDir(), "cache"))
+
+    key := Key("tree", "abc", "action", "go test ./...")
+    if _, hit := c.Get(key); hit {
+        t.Fatal("Expected empty cache")
+    }
+
+    entry := Entry{Key: key, Step: "test", Timestamp: time.Now(), Duration: time.Second}
+    if err := c.Put(entry); err != nil {
+        t.Fatalf("Failed to put entry: %v", err)
+    }
+
+    cached, hit := c.Get(key)
+    if !hit {
+        t.Fatal("Expected cache hit")
+    }
+    if cached.Step != "test" {
+        t.Errorf("Expected step 'test', got '%s'", cached.Step)
+    }
+
+    count, err := c.Clear()
+    if err != nil {
+        t.Fatalf("Failed to clear cache: %v", err)
+    }
+    if count != 1 {
+        t.Errorf("Expected 1 removed entry, got %d", count)
+    }
+    if _, hit := c.Get(key); hit {
+        t.Error("Expected cache miss after clear")
+    }
+}
+
+func TestKey(t *testing.T) {
+    if Key("a", "b") == Key("ab") {
+        t.Error("Expected parts to be separated in the key")
+    }
+    if Key("a", "b") != Key("a", "b") {
+        t.Error("Expected key to be deterministic")
+    }
+}
+
+// TestTreeHashPaths tests that globs select the hashed files and that paths
+// matching nothing are not cacheable
+func TestTreeHashPaths(t *testing.T) {
+    tempDir := t.TempDir()
+    oldDir, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get current dir: %v", err)
+    }
+    t.Cleanup(func() { os.Chdir(oldDir) })
+    if err := os.Chdir(tempDir); err != nil {
+        t.Fatalf("Failed to change to temp dir: %v", err)
+    }
+
+    run := func(args ...string) {
+        if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+            t.Fatalf("Failed to run %v: %v: %s", args, err, output)
+        }
+    }
+    commit := func(path, content string) {
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatalf("Failed to create dir: %v", err)
+        }
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write file: %v", err)
+        }
+        run("git", "add", path)
+        run("git", "commit", "-q", "-m", "update "+path)
+    }
+    run("git", "init", "-q")
+    run("git", "config", "user.email", "test@example.com")
+    run("git", "config", "user.name", "Test User")
+    commit("main.go", "package main\n")
+    commit("internal/cache/cache.go", "package cache\n")
+    commit("docs/README.md", "docs\n")
+
+    ctx := context.Background()
+    hashes := map[string]string{}
+    for _, path := range []string{"*.go", "internal/cache/*", "**/*.go", "internal"} {
+        hash, ok, err := TreeHash(ctx, []string{path})
+        if err != nil || !ok || hash == "" {
+            t.Fatalf("Expected %s to be cacheable, got %q %v %v", path, hash, ok, err)
+        }
+        hashes[path] = hash
+    }
+
+    // A committed change invalidates the paths that match the file only
+    commit("internal/cache/cache.go", "package cache\n\nconst x = 1\n")
+    for path, before := range hashes {
+        hash, _, err := TreeHash(ctx, []string{path})
+        if err != nil {
+            t.Fatalf("Failed to hash %s: %v", path, err)
+        }
+        if changed := hash != before; changed != (path != "*.go") {
+            t.Errorf("Expected hash of %s to change: %v, got %v", path, path != "*.go", changed)
+        }
+    }
+
+    if _, ok, err := TreeHash(ctx, []string{"missing/*.go"}); err != nil || ok {
+        t.Errorf("Expected paths matching nothing not to be cacheable, got %v %v", ok, err)
+    }
+
+    if err := os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+    if _, ok, err := TreeHash(ctx, []string{"*.go"}); err != nil || ok {
+        t.Errorf("Expected uncommitted changes not to be cacheable, got %v %v", ok, err)
+    }
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+    "fmt"
+    "sort"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/expr"
+)
+
+// hiddenOnly holds the only lists with expressions of the steps of each
+// stage by step index. buildfab accepts only version type keywords in only,
+// so these lists are removed before buildfab reads a configuration and put
+// back afterwards.
+type hiddenOnly map[string]map[int][]string
+
+// hideOnly removes the only lists that contain expressions from the steps
+// of a configuration document. Documents without such lists are returned
+// unchanged.
+func hideOnly(data []byte) ([]byte, hiddenOnly, error) {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return nil, nil, err
+    }
+    if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+        return data, nil, nil
+    }
+    stages := mappingValue(doc.Content[0], "stages")
+    if stages == nil || stages.Kind != yaml.MappingNode {
+        return data, nil, nil
+    }
+
+    hidden := hiddenOnly{}
+    for i := 0; i+1 < len(stages.Content); i += 2 {
+        name := stages.Content[i].Value
+        for j, step := range stageSteps(stages.Content[i+1]) {
+            only := mappingValue(step, "only")
+            if only == nil || only.Kind != yaml.SequenceNode {
+                continue
+            }
+            var entries []string
+            if err := only.Decode(&entries); err != nil {
+                return nil, nil, fmt.Errorf("stages.%s.steps[%d].only: %w", name, j, err)
+            }
+            keywords := true
+            for _, entry := range entries {
+                keywords = keywords && expr.IsOnlyKeyword(entry)
+            }
+            if keywords {
+                continue
+            }
+            if hidden[name] == nil {
+                hidden[name] = map[int][]string{}
+            }
+            hidden[name][j] = entries
+            removeKey(step, "only")
+        }
+    }
+    if len(hidden) == 0 {
+        return data, nil, nil
+    }
+
+    data, err := yaml.Marshal(&doc)
+    if err != nil {
+        return nil, nil, err
+    }
+    return data, hidden, nil
+}
+
+// restore puts the hidden only lists back on the steps of a configuration
+func (h hiddenOnly) restore(config *buildfab.Config) {
+    for name, steps := range h {
+        stage, exists := config.Stages[name]
+        if !exists {
+            continue
+        }
+        stage.Steps = append([]buildfab.Step(nil), stage.Steps...)
+        for index, only := range steps {
+            if index < len(stage.Steps) {
+                stage.Steps[index].Only = only
+            }
+        }
+        config.Stages[name] = stage
+    }
+}
+
+// validateConfig validates a configuration with buildfab and type checks
+// the conditions of its steps. buildfab sees only the version type keywords
+// of only lists, the expressions are checked here.
+func validateConfig(config *buildfab.Config) error {
+    keywords := *config
+    keywords.Stages = make(map[string]buildfab.Stage, len(config.Stages))
+    for name, stage := range config.Stages {
+        stage.Steps = append([]buildfab.Step(nil), stage.Steps...)
+        for i := range stage.Steps {
+            var only []string
+            for _, entry := range stage.Steps[i].Only {
+                if expr.IsOnlyKeyword(entry) {
+                    only = append(only, entry)
+                }
+            }
+            stage.Steps[i].Only = only
+        }
+        keywords.Stages[name] = stage
+    }
+    if err := keywords.Validate(); err != nil {
+        return err
+    }
+    return checkConditions(config)
+}
+
+// checkConditions type checks the if and only of every step
+func checkConditions(config *buildfab.Config) error {
+    names := make([]string, 0, len(config.Stages))
+    for name := range config.Stages {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        for _, step := range config.Stages[name].Steps {
+            if _, err := expr.CompileCondition(step.If, step.Only, expr.StepVariables(step.Variables)); err != nil {
+                return fmt.Errorf("stage %s, step %s: %w", name, step.GetStepName(), err)
+            }
+        }
+    }
+    return nil
+}
@@ -6,14 +6,11 @@ import (
     "fmt"
     "io"
     "os"
-    "os/exec"
-    "path/filepath"
-    "runtime"
-    "strings"
 
     "gopkg.in/yaml.v3"
     "github.com/AlexBurnes/buildfab/pkg/buildfab"
-    "github.com/AlexBurnes/pre-push/internal/version"
+    "github.com/AlexBurnes/pre-push/internal/template"
+    "github.com/AlexBurnes/pre-push/internal/vars"
     "github.com/AlexBurnes/pre-push/pkg/prepush"
 )
 
@@ -123,21 +120,8 @@ func (l *Loader) Load() (*prepush.Config, error) {
 
 // LoadFromDir searches for configuration files in the specified directory
 func LoadFromDir(dir string) (*prepush.Config, error) {
-    // Common configuration file names
-    configFiles := []string{
-        ".project.yml",
-        ".project.yaml",
-        "project.yml",
-        "project.yaml",
-        "pre-push.yml",
-        "pre-push.yaml",
-    }
-
-    for _, filename := range configFiles {
-        configPath := filepath.Join(dir, filename)
-        if _, err := os.Stat(configPath); err == nil {
-            return Load(configPath)
-        }
+    if configPath := FindInDir(dir); configPath != "" {
+        return Load(configPath)
     }
 
     return nil, fmt.Errorf("no configuration file found in directory: %s", dir)
@@ -153,51 +137,69 @@ func LoadFromString(content string) (*prepush.Config, error) {
     return &config, nil
 }
 
-// ResolveVariables resolves variable interpolation in configuration
+// ResolveVariables renders the ${{ }} references of every string field of
+// the configuration. Undefined variables are an error.
 func ResolveVariables(config *prepush.Config, variables map[string]string) error {
-    // Resolve variables in action run commands
-    for i := range config.Actions {
-        if config.Actions[i].Run != "" {
-            resolved, err := resolveString(config.Actions[i].Run, variables)
-            if err != nil {
-                return fmt.Errorf("failed to resolve variables in action %s: %w", config.Actions[i].Name, err)
-            }
-            config.Actions[i].Run = resolved
+    return ResolveVariablesMode(config, variables, template.Strict)
+}
+
+// ResolveVariablesMode renders the ${{ }} references of every string field
+// of the configuration, with undefined variables treated as the mode says.
+// The if and only of steps are expressions and are left as they are. Action
+// names and the actions steps reference stay literal, they identify steps in
+// requirements, the cache and the run history.
+func ResolveVariablesMode(config *prepush.Config, variables map[string]string, mode template.Mode) error {
+    resolve := func(field string, s *string) error {
+        resolved, err := template.Render(*s, variables, mode)
+        if err != nil {
+            return fmt.Errorf("failed to resolve variables in %s: %w", field, err)
         }
+        *s = resolved
+        return nil
     }
 
-    return nil
-}
+    if err := resolve("project.name", &config.Project.Name); err != nil {
+        return err
+    }
+    for i := range config.Project.Modules {
+        if err := resolve(fmt.Sprintf("project.modules[%d]", i), &config.Project.Modules[i]); err != nil {
+            return err
+        }
+    }
+    if err := resolve("project.bin", &config.Project.BinDir); err != nil {
+        return err
+    }
 
-// resolveString resolves variable interpolation in a string
-func resolveString(s string, variables map[string]string) (string, error) {
-    result := s
-    
-    // Find all variable references in the format ${{ variable }}
-    for {
-        start := strings.Index(result, "${{")
-        if start == -1 {
-            break
+    for i := range config.Actions {
+        action := &config.Actions[i]
+        field := "action " + action.Name
+        if err := resolve(field+" run", &action.Run); err != nil {
+            return err
         }
-        
-        end := strings.Index(result[start:], "}}")
-        if end == -1 {
-            return "", fmt.Errorf("unclosed variable reference: %s", result[start:])
+        if err := resolve(field+" uses", &action.Uses); err != nil {
+            return err
         }
-        
-        end += start + 2 // Adjust for the start position
-        
-        variableName := strings.TrimSpace(result[start+3 : end-2])
-        
-        value, exists := variables[variableName]
-        if !exists {
-            return "", fmt.Errorf("undefined variable: %s", variableName)
+    }
+
+    for name, stage := range config.Stages {
+        stage.Steps = append([]prepush.Step(nil), stage.Steps...)
+        for i := range stage.Steps {
+            step := &stage.Steps[i]
+            field := fmt.Sprintf("stage %s step %d", name, i)
+            if err := resolve(field+" onerror", &step.OnError); err != nil {
+                return err
+            }
+            step.Require = append([]string(nil), step.Require...)
+            for j := range step.Require {
+                if err := resolve(fmt.Sprintf("%s require[%d]", field, j), &step.Require[j]); err != nil {
+                    return err
+                }
+            }
         }
-        
-        result = result[:start] + value + result[end:]
+        config.Stages[name] = stage
     }
-    
-    return result, nil
+
+    return nil
 }
 
 // GetDefaultVariables returns default variables available for interpolation
@@ -221,201 +223,21 @@ func GetDefaultVariables() map[string]string {
 
 // DetectAllVariables detects all available variables (Git, version, platform, environment)
 func DetectAllVariables(ctx context.Context) (map[string]string, error) {
-    variables := make(map[string]string)
-    
-    // Detect Git variables
-    gitVars, err := DetectGitVariables(ctx)
-    if err != nil {
-        return nil, fmt.Errorf("failed to detect Git variables: %w", err)
-    }
-    for k, v := range gitVars {
-        variables[k] = v
-    }
-    
-    // Detect platform variables
-    platformVars, err := DetectPlatformVariables(ctx)
-    if err != nil {
-        return nil, fmt.Errorf("failed to detect platform variables: %w", err)
-    }
-    for k, v := range platformVars {
-        variables[k] = v
-    }
-    
-    // Detect environment variables
-    envVars := DetectEnvironmentVariables()
-    for k, v := range envVars {
-        variables[k] = v
-    }
-    
-    return variables, nil
+    return vars.Map((&vars.Provider{}).Collect(ctx)), nil
 }
 
 // DetectGitVariables detects Git-related variables from the current repository
 func DetectGitVariables(ctx context.Context) (map[string]string, error) {
-    variables := make(map[string]string)
-    
-    // Detect current tag
-    if tag, err := detectGitTag(ctx); err == nil {
-        variables["tag"] = tag
-    }
-    
-    // Detect current branch
-    if branch, err := detectGitBranch(ctx); err == nil {
-        variables["branch"] = branch
-    }
-    
-    // Detect version library variables
-    if versionInfo, err := version.GetVersionInfo(ctx); err == nil {
-        if versionInfo.Version != "" {
-            variables["version.version"] = versionInfo.Version
-        }
-        if versionInfo.Project != "" {
-            variables["version.project"] = versionInfo.Project
-        }
-        if versionInfo.Module != "" {
-            variables["version.module"] = versionInfo.Module
-        }
-        if len(versionInfo.Modules) > 0 {
-            variables["version.modules"] = strings.Join(versionInfo.Modules, " ")
-        }
-    }
-    
-    return variables, nil
+    return vars.Map(vars.FromSource((&vars.Provider{}).Collect(ctx), vars.SourceGit, vars.SourceVersion)), nil
 }
 
 // DetectPlatformVariables detects platform-specific variables
 func DetectPlatformVariables(ctx context.Context) (map[string]string, error) {
-    variables := make(map[string]string)
-    
-    // Basic platform information
-    variables["platform.os"] = runtime.GOOS
-    variables["platform.arch"] = runtime.GOARCH
-    variables["platform.goos"] = runtime.GOOS
-    variables["platform.goarch"] = runtime.GOARCH
-    variables["platform.go"] = runtime.Version()
-    
-    // Detect operating system details
-    if osName, err := detectOSName(); err == nil {
-        variables["platform.os.name"] = osName
-    }
-    
-    // Detect architecture details
-    if archName, err := detectArchName(); err == nil {
-        variables["platform.arch.name"] = archName
-    }
-    
-    // Detect shell information
-    if shell, err := detectShell(); err == nil {
-        variables["platform.shell"] = shell
-    }
-    
-    return variables, nil
+    return vars.Map(vars.Platform()), nil
 }
 
-// DetectEnvironmentVariables detects environment variables for substitution
+// DetectEnvironmentVariables detects environment variables for substitution.
+// Keys keep their case, HOME is env.HOME.
 func DetectEnvironmentVariables() map[string]string {
-    variables := make(map[string]string)
-    
-    // Common environment variables
-    envVars := []string{
-        "HOME", "USER", "USERNAME", "PATH", "PWD", "SHELL",
-        "GOPATH", "GOROOT", "GOBIN", "GOCACHE", "GOMODCACHE",
-        "CI", "GITHUB_ACTIONS", "GITLAB_CI", "JENKINS_URL",
-        "BUILD_NUMBER", "BUILD_ID", "JOB_NAME", "WORKSPACE",
-    }
-    
-    for _, envVar := range envVars {
-        if value := os.Getenv(envVar); value != "" {
-            variables["env."+strings.ToLower(envVar)] = value
-        }
-    }
-    
-    // Add all environment variables with env. prefix
-    for _, env := range os.Environ() {
-        if parts := strings.SplitN(env, "=", 2); len(parts) == 2 {
-            key := strings.ToLower(parts[0])
-            value := parts[1]
-            variables["env."+key] = value
-        }
-    }
-    
-    return variables
-}
-
-// detectGitTag detects the current Git tag
-func detectGitTag(ctx context.Context) (string, error) {
-    cmd := exec.CommandContext(ctx, "git", "describe", "--tags", "--abbrev=0")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
-    }
-    return strings.TrimSpace(string(output)), nil
-}
-
-// detectGitBranch detects the current Git branch
-func detectGitBranch(ctx context.Context) (string, error) {
-    cmd := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
-    }
-    return strings.TrimSpace(string(output)), nil
-}
-
-// detectOSName detects the operating system name
-func detectOSName() (string, error) {
-    switch runtime.GOOS {
-    case "linux":
-        // Try to detect Linux distribution
-        if data, err := os.ReadFile("/etc/os-release"); err == nil {
-            lines := strings.Split(string(data), "\n")
-            for _, line := range lines {
-                if strings.HasPrefix(line, "PRETTY_NAME=") {
-                    name := strings.TrimPrefix(line, "PRETTY_NAME=")
-                    name = strings.Trim(name, "\"")
-                    return name, nil
-                }
-            }
-        }
-        return "Linux", nil
-    case "darwin":
-        return "macOS", nil
-    case "windows":
-        return "Windows", nil
-    default:
-        return runtime.GOOS, nil
-    }
-}
-
-// detectArchName detects the architecture name
-func detectArchName() (string, error) {
-    switch runtime.GOARCH {
-    case "amd64":
-        return "x86_64", nil
-    case "arm64":
-        return "aarch64", nil
-    case "386":
-        return "i386", nil
-    case "arm":
-        return "arm", nil
-    default:
-        return runtime.GOARCH, nil
-    }
+    return vars.Map(vars.Environment(nil))
 }
-
-// detectShell detects the current shell
-func detectShell() (string, error) {
-    if shell := os.Getenv("SHELL"); shell != "" {
-        return filepath.Base(shell), nil
-    }
-    
-    // Fallback detection
-    switch runtime.GOOS {
-    case "windows":
-        return "cmd", nil
-    case "darwin":
-        return "zsh", nil
-    default:
-        return "bash", nil
-    }
-}
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
     "path/filepath"
     "testing"
 
+    "github.com/AlexBurnes/pre-push/internal/template"
     "github.com/AlexBurnes/pre-push/pkg/prepush"
 )
 
@@ -146,6 +147,53 @@ func TestResolveVariablesError(t *testing.T) {
     }
 }
 
+func TestResolveVariablesFields(t *testing.T) {
+    config := &prepush.Config{
+        Actions: []prepush.Action{
+            {Name: "build-${{ platform }}", Run: "make ${{ target | default(\"all\") | shellquote }}"},
+        },
+        Stages: map[string]prepush.Stage{
+            "pre-push": {
+                Steps: []prepush.Step{
+                    {Action: "build-${{ platform }}", OnError: "${{ onerror | default('stop') }}", If: "${{ branch == 'main' }}"},
+                },
+            },
+        },
+    }
+    config.Project.Name = "${{ project | upper }}"
+    
+    variables := map[string]string{"platform": "linux", "project": "demo", "onerror": ""}
+    if err := ResolveVariables(config, variables); err != nil {
+        t.Fatalf("Failed to resolve variables: %v", err)
+    }
+    
+    step := config.Stages["pre-push"].Steps[0]
+    if config.Project.Name != "DEMO" || config.Actions[0].Run != "make all" {
+        t.Errorf("Expected project and action fields to be resolved, got %+v %+v", config.Project, config.Actions[0])
+    }
+    if config.Actions[0].Name != "build-${{ platform }}" || step.Action != "build-${{ platform }}" {
+        t.Errorf("Expected action names to be left as they are, got %s and %s", config.Actions[0].Name, step.Action)
+    }
+    if step.OnError != "stop" {
+        t.Errorf("Expected step fields to be resolved, got %+v", step)
+    }
+    if step.If != "${{ branch == 'main' }}" {
+        t.Errorf("Expected the condition to be left as it is, got %s", step.If)
+    }
+}
+
+func TestResolveVariablesLenient(t *testing.T) {
+    config := &prepush.Config{
+        Actions: []prepush.Action{{Name: "test-action", Run: "echo [${{ undefined }}]"}},
+    }
+    if err := ResolveVariablesMode(config, nil, template.Lenient); err != nil {
+        t.Fatalf("Failed to resolve variables: %v", err)
+    }
+    if config.Actions[0].Run != "echo []" {
+        t.Errorf("Expected undefined variable to be empty, got %s", config.Actions[0].Run)
+    }
+}
+
 func TestDetectGitVariables(t *testing.T) {
     // Create a temporary git repository
     tempDir, err := os.MkdirTemp("", "pre-push-git-test")
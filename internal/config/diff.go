@@ -0,0 +1,129 @@
+package config
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+)
+
+// diffContext is the number of unchanged lines shown around a change
+const diffContext = 3
+
+// lineOp is a step of a line diff: a line kept ('='), removed ('-') or
+// added ('+'), with its index in the old and the new lines
+type lineOp struct {
+    kind     byte
+    old, new int
+}
+
+// diffLines returns the operations that turn the old lines into the new
+// lines, keeping the longest common subsequence
+func diffLines(old, new []string) []lineOp {
+    // common[i][j] is the length of the common subsequence of old[i:] and new[j:]
+    common := make([][]int, len(old)+1)
+    for i := range common {
+        common[i] = make([]int, len(new)+1)
+    }
+    for i := len(old) - 1; i >= 0; i-- {
+        for j := len(new) - 1; j >= 0; j-- {
+            if old[i] == new[j] {
+                common[i][j] = common[i+1][j+1] + 1
+            } else {
+                common[i][j] = max(common[i+1][j], common[i][j+1])
+            }
+        }
+    }
+
+    var ops []lineOp
+    i, j := 0, 0
+    for i < len(old) || j < len(new) {
+        switch {
+        case i < len(old) && j < len(new) && old[i] == new[j]:
+            ops = append(ops, lineOp{'=', i, j})
+            i++
+            j++
+        case i < len(old) && (j == len(new) || common[i+1][j] >= common[i][j+1]):
+            ops = append(ops, lineOp{'-', i, j})
+            i++
+        default:
+            ops = append(ops, lineOp{'+', i, j})
+            j++
+        }
+    }
+    return ops
+}
+
+// splitLines splits text into lines without their line breaks
+func splitLines(text string) []string {
+    if text == "" {
+        return nil
+    }
+    return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// UnifiedDiff returns the changes between two versions of a file in unified
+// diff format, or an empty string if they are equal
+func UnifiedDiff(name string, old, new []byte) string {
+    oldLines, newLines := splitLines(string(old)), splitLines(string(new))
+    ops := diffLines(oldLines, newLines)
+
+    var b strings.Builder
+    for start := 0; start < len(ops); {
+        if ops[start].kind == '=' {
+            start++
+            continue
+        }
+
+        // A hunk runs until the changes are more than two contexts apart
+        end := start
+        for next := start; next < len(ops); next++ {
+            if ops[next].kind != '=' {
+                end = next + 1
+            } else if next-end >= 2*diffContext {
+                break
+            }
+        }
+        from, to := max(start-diffContext, 0), min(end+diffContext, len(ops))
+
+        if b.Len() == 0 {
+            if filepath.IsAbs(name) {
+                fmt.Fprintf(&b, "--- %s\n+++ %s\n", name, name)
+            } else {
+                fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+            }
+        }
+        oldCount, newCount := 0, 0
+        for _, op := range ops[from:to] {
+            if op.kind != '+' {
+                oldCount++
+            }
+            if op.kind != '-' {
+                newCount++
+            }
+        }
+        fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(ops[from].old, oldCount), hunkRange(ops[from].new, newCount))
+        for _, op := range ops[from:to] {
+            switch op.kind {
+            case '=':
+                fmt.Fprintf(&b, " %s\n", oldLines[op.old])
+            case '-':
+                fmt.Fprintf(&b, "-%s\n", oldLines[op.old])
+            case '+':
+                fmt.Fprintf(&b, "+%s\n", newLines[op.new])
+            }
+        }
+        start = to
+    }
+    return b.String()
+}
+
+// hunkRange formats the start line and line count of a hunk
+func hunkRange(start, count int) string {
+    if count == 0 {
+        return fmt.Sprintf("%d,0", start)
+    }
+    if count == 1 {
+        return fmt.Sprintf("%d", start+1)
+    }
+    return fmt.Sprintf("%d,%d", start+1, count)
+}
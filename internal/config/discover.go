@@ -0,0 +1,51 @@
+package config
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// ConfigFiles are the names of project configuration files, in the order
+// they are searched
+var ConfigFiles = []string{
+    ".project.yml",
+    ".project.yaml",
+    "project.yml",
+    "project.yaml",
+    "pre-push.yml",
+    "pre-push.yaml",
+}
+
+// FindInDir returns the path of the first configuration file in dir, or an
+// empty string if dir has none
+func FindInDir(dir string) string {
+    for _, filename := range ConfigFiles {
+        configPath := filepath.Join(dir, filename)
+        if info, err := os.Stat(configPath); err == nil && !info.IsDir() {
+            return configPath
+        }
+    }
+    return ""
+}
+
+// Discover returns the project configuration file. An explicit path (from
+// --config or PRE_PUSH_CONFIG) must exist; otherwise the configuration files
+// are searched in dir. It returns an empty path if dir has none.
+func Discover(dir, explicit string) (string, error) {
+    if explicit != "" {
+        info, err := os.Stat(explicit)
+        if err != nil {
+            return "", fmt.Errorf("configuration file not found: %s", explicit)
+        }
+        if info.IsDir() {
+            if path := FindInDir(explicit); path != "" {
+                return path, nil
+            }
+            return "", fmt.Errorf("no configuration file found in directory: %s (tried %s)", explicit, strings.Join(ConfigFiles, ", "))
+        }
+        return explicit, nil
+    }
+    return FindInDir(dir), nil
+}
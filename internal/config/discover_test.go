@@ -0,0 +1,38 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestDiscover(t *testing.T) {
+    tempDir := t.TempDir()
+
+    if path, err := Discover(tempDir, ""); err != nil || path != "" {
+        t.Errorf("Expected no configuration, got %q, %v", path, err)
+    }
+
+    // Earlier candidates take precedence
+    for _, name := range []string{"pre-push.yml", ".project.yaml"} {
+        if err := os.WriteFile(filepath.Join(tempDir, name), []byte("project: {}\n"), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+    path, err := Discover(tempDir, "")
+    if err != nil {
+        t.Fatalf("Failed to discover configuration: %v", err)
+    }
+    if expected := filepath.Join(tempDir, ".project.yaml"); path != expected {
+        t.Errorf("Expected %s, got %s", expected, path)
+    }
+
+    // An explicit path overrides the search
+    explicit := filepath.Join(tempDir, "pre-push.yml")
+    if path, err := Discover(tempDir, explicit); err != nil || path != explicit {
+        t.Errorf("Expected %s, got %q, %v", explicit, path, err)
+    }
+    if _, err := Discover(tempDir, filepath.Join(tempDir, "missing.yml")); err == nil {
+        t.Error("Expected error for missing explicit configuration")
+    }
+}
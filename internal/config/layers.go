@@ -0,0 +1,460 @@
+package config
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// skipKey marks a step of a layer that removes the step from the stage
+const skipKey = "skip"
+
+// LocalConfigPath returns the path of the local layer of a configuration
+// file, .project.local.yml for .project.yml. The local layer holds personal
+// overrides and is not committed.
+func LocalConfigPath(path string) string {
+    ext := filepath.Ext(path)
+    return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+// LoadLayered loads a project configuration and merges the layers over it in
+// order, so later layers take precedence. Layer files that do not exist are
+// skipped. A layer may contain:
+//   - pre-push: settings that replace the settings of the same key, secrets
+//     are added to those of the files below
+//   - actions: actions that replace the action of the same name or are added
+//   - stages: steps that override the keys they set on the step of the same
+//     name, remove it with skip: true, or are added to the stage
+func LoadLayered(path string, layers []string) (*Project, error) {
+    project, err := LoadProject(path)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, layer := range layers {
+        data, err := os.ReadFile(layer)
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to read configuration layer: %w", err)
+        }
+        if err := project.applyLayer(layer, data); err != nil {
+            return nil, fmt.Errorf("%s: %w", layer, err)
+        }
+        project.Layers = append(project.Layers, layer)
+    }
+
+    if len(project.Layers) > 0 {
+        if err := validateConfig(project.Config); err != nil {
+            return nil, fmt.Errorf("configuration with layers %s: %w", strings.Join(project.Layers, ", "), err)
+        }
+    }
+    return project, nil
+}
+
+// applyLayer merges a layer document into the project
+func (p *Project) applyLayer(path string, data []byte) error {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return err
+    }
+    if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+        return nil
+    }
+    root := doc.Content[0]
+    if root.Kind != yaml.MappingNode {
+        return fmt.Errorf("line %d: expected a mapping", root.Line)
+    }
+
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        key, value := root.Content[i], root.Content[i+1]
+        var err error
+        switch key.Value {
+        case settingsKey:
+            err = p.applySettings(path, value)
+        case "actions":
+            err = p.applyActions(path, value)
+        case "stages":
+            err = p.applyStages(path, value)
+        default:
+            err = fmt.Errorf("line %d: %s cannot be set in a configuration layer", key.Line, key.Value)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// applySettings replaces the settings the layer sets. Secrets are only
+// added: the secrets list extends the list of the files below and a variable
+// declared secret stays secret, so no layer can turn masking off.
+func (p *Project) applySettings(path string, node *yaml.Node) error {
+    secrets := p.Settings.Secrets
+    variables := make(map[string]VariableSettings, len(p.Settings.Variables))
+    for name, variable := range p.Settings.Variables {
+        variables[name] = variable
+    }
+
+    if err := decodeStrict(node, &p.Settings); err != nil {
+        return fmt.Errorf("%s: %w", settingsKey, err)
+    }
+
+    added := p.Settings.Secrets
+    p.Settings.Secrets = append([]string(nil), secrets...)
+    for _, secret := range added {
+        if !contains(p.Settings.Secrets, secret) {
+            p.Settings.Secrets = append(p.Settings.Secrets, secret)
+        }
+    }
+    for name, variable := range variables {
+        if variable.Secret {
+            p.Settings.Variables[name] = variable
+        }
+    }
+    for _, key := range mappingKeys(node) {
+        p.setOrigin(path, settingsKey, key)
+    }
+    return nil
+}
+
+// applyActions replaces actions of the same name and adds new ones
+func (p *Project) applyActions(path string, node *yaml.Node) error {
+    var actions []buildfab.Action
+    if err := decodeStrict(node, &actions); err != nil {
+        return fmt.Errorf("actions: %w", err)
+    }
+
+    for _, action := range actions {
+        found := false
+        for i, existing := range p.Config.Actions {
+            if existing.Name == action.Name {
+                p.Config.Actions[i] = action
+                found = true
+                break
+            }
+        }
+        if !found {
+            p.Config.Actions = append(p.Config.Actions, action)
+        }
+        p.setOrigin(path, "actions", action.Name)
+    }
+    return nil
+}
+
+// applyStages merges the steps of each stage of the layer into the stage of
+// the same name
+func (p *Project) applyStages(path string, node *yaml.Node) error {
+    if node.Kind != yaml.MappingNode {
+        return fmt.Errorf("line %d: stages must be a mapping", node.Line)
+    }
+    if p.Config.Stages == nil {
+        p.Config.Stages = make(map[string]buildfab.Stage)
+    }
+
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        stageName, stageNode := node.Content[i].Value, node.Content[i+1]
+
+        stage, exists := p.Config.Stages[stageName]
+        if !exists {
+            p.setOrigin(path, "stages", stageName)
+        }
+
+        for _, stepNode := range stageSteps(stageNode) {
+            var err error
+            stage, err = p.applyStep(path, stageName, stage, stepNode)
+            if err != nil {
+                return fmt.Errorf("stages.%s: %w", stageName, err)
+            }
+        }
+        p.Config.Stages[stageName] = stage
+    }
+    return nil
+}
+
+// applyStep overrides, removes or adds a step of a stage
+func (p *Project) applyStep(path, stageName string, stage buildfab.Stage, node *yaml.Node) (buildfab.Stage, error) {
+    if node.Kind != yaml.MappingNode {
+        return stage, fmt.Errorf("line %d: a step must be a mapping", node.Line)
+    }
+
+    // Separate skip from the step keys
+    skip := false
+    step := &yaml.Node{Kind: yaml.MappingNode, Tag: node.Tag, Line: node.Line, Column: node.Column}
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value == skipKey {
+            if err := node.Content[i+1].Decode(&skip); err != nil {
+                return stage, fmt.Errorf("line %d: skip must be true or false", node.Content[i+1].Line)
+            }
+            continue
+        }
+        step.Content = append(step.Content, node.Content[i], node.Content[i+1])
+    }
+
+    var identity buildfab.Step
+    if err := decodeStrict(step, &identity); err != nil {
+        return stage, err
+    }
+    name := identity.GetStepName()
+    if name == "" {
+        return stage, fmt.Errorf("line %d: a step must have a name, action or stage", node.Line)
+    }
+
+    index := -1
+    for i := range stage.Steps {
+        if stage.Steps[i].GetStepName() == name {
+            index = i
+            break
+        }
+    }
+
+    switch {
+    case skip:
+        if index >= 0 {
+            stage.Steps = removeStep(stage.Steps, index)
+            p.Skipped = append(p.Skipped, SkippedStep{Stage: stageName, Step: name, Origin: path})
+        }
+    case index >= 0:
+        // Decoding over the existing step replaces only the keys the layer sets
+        steps := append([]buildfab.Step(nil), stage.Steps...)
+        if err := decodeStrict(step, &steps[index]); err != nil {
+            return stage, err
+        }
+        stage.Steps = steps
+        for _, key := range mappingKeys(step) {
+            if key != stepIdentityKey(identity) {
+                p.setOrigin(path, "stages", stageName, name, key)
+            }
+        }
+    default:
+        stage.Steps = append(append([]buildfab.Step(nil), stage.Steps...), identity)
+        p.setOrigin(path, "stages", stageName, name)
+    }
+    return stage, nil
+}
+
+// stepIdentityKey returns the key a step is identified by, see GetStepName
+func stepIdentityKey(step buildfab.Step) string {
+    switch {
+    case step.Name != "":
+        return "name"
+    case step.Action != "":
+        return "action"
+    default:
+        return "stage"
+    }
+}
+
+// removeStep removes a step and the requirements of other steps on it
+func removeStep(steps []buildfab.Step, index int) []buildfab.Step {
+    name := steps[index].GetStepName()
+
+    var result []buildfab.Step
+    for i, step := range steps {
+        if i == index {
+            continue
+        }
+        step.Require = withoutName(step.Require, name)
+        step.DependsOn = withoutName(step.DependsOn, name)
+        result = append(result, step)
+    }
+    return result
+}
+
+// withoutName returns the names without name
+func withoutName(names []string, name string) []string {
+    var result []string
+    for _, n := range names {
+        if n != name {
+            result = append(result, n)
+        }
+    }
+    return result
+}
+
+// stageSteps returns the step nodes of a stage node
+func stageSteps(node *yaml.Node) []*yaml.Node {
+    if node.Kind != yaml.MappingNode {
+        return nil
+    }
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value == "steps" && node.Content[i+1].Kind == yaml.SequenceNode {
+            return node.Content[i+1].Content
+        }
+    }
+    return nil
+}
+
+// mappingKeys returns the keys of a mapping node
+func mappingKeys(node *yaml.Node) []string {
+    var keys []string
+    if node.Kind != yaml.MappingNode {
+        return keys
+    }
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        keys = append(keys, node.Content[i].Value)
+    }
+    return keys
+}
+
+// SkippedStep is a step a layer removed from a stage
+type SkippedStep struct {
+    Stage  string // Stage the step was removed from
+    Step   string // Name of the step
+    Origin string // Layer that removed the step
+}
+
+// setOrigin records the file that set a key. Keys are dotted paths such as
+// actions.lint or stages.pre-push.lint.onerror.
+func (p *Project) setOrigin(path string, key ...string) {
+    if p.Origins == nil {
+        p.Origins = make(map[string]string)
+    }
+    p.Origins[strings.Join(key, ".")] = path
+}
+
+// Origin returns the file that set a key, falling back to the origin of the
+// enclosing key and finally to the project configuration
+func (p *Project) Origin(key ...string) string {
+    for n := len(key); n > 0; n-- {
+        if origin, ok := p.Origins[strings.Join(key[:n], ".")]; ok {
+            return origin
+        }
+    }
+    return p.Path
+}
+
+// resolvedDocument is the layout of a rendered configuration
+type resolvedDocument struct {
+    Schema   int                       `yaml:"schema,omitempty"`
+    Settings *Settings                 `yaml:"pre-push,omitempty"`
+    Project  buildfab.Project          `yaml:"project"`
+    Actions  []buildfab.Action         `yaml:"actions"`
+    Stages   map[string]buildfab.Stage `yaml:"stages"`
+}
+
+// Render returns the configuration as YAML with includes and layers merged.
+// With origins set every setting, action, stage and step is annotated with
+// the file that set it, and step keys set by a layer with that layer.
+func (p *Project) Render(origins bool) ([]byte, error) {
+    doc := resolvedDocument{Schema: p.Schema, Project: p.Config.Project, Actions: p.Config.Actions, Stages: p.Config.Stages}
+    if !p.Settings.IsZero() {
+        settings := p.Settings
+        doc.Settings = &settings
+    }
+
+    var node yaml.Node
+    if err := node.Encode(doc); err != nil {
+        return nil, err
+    }
+    if origins {
+        p.annotate(&node)
+    }
+
+    var buf bytes.Buffer
+    encoder := yaml.NewEncoder(&buf)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(&node); err != nil {
+        return nil, err
+    }
+    if err := encoder.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// annotate adds origin comments to a rendered configuration
+func (p *Project) annotate(root *yaml.Node) {
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        key, value := root.Content[i], root.Content[i+1]
+        switch key.Value {
+        case settingsKey:
+            for j := 0; j+1 < len(value.Content); j += 2 {
+                value.Content[j+1].LineComment = displayPath(p.Origin(settingsKey, value.Content[j].Value))
+            }
+        case "project":
+            key.LineComment = displayPath(p.Path)
+        case "actions":
+            for _, action := range value.Content {
+                if nameNode := mappingValue(action, "name"); nameNode != nil {
+                    nameNode.LineComment = displayPath(p.Origin("actions", nameNode.Value))
+                }
+            }
+        case "stages":
+            for j := 0; j+1 < len(value.Content); j += 2 {
+                p.annotateStage(value.Content[j], value.Content[j+1])
+            }
+        }
+    }
+}
+
+// annotateStage adds origin comments to a rendered stage
+func (p *Project) annotateStage(key, stage *yaml.Node) {
+    stageName := key.Value
+    comment := displayPath(p.Origin("stages", stageName))
+
+    var skipped []string
+    for _, s := range p.Skipped {
+        if s.Stage == stageName {
+            skipped = append(skipped, fmt.Sprintf("%s skipped by %s", s.Step, displayPath(s.Origin)))
+        }
+    }
+    sort.Strings(skipped)
+    if len(skipped) > 0 {
+        comment += "; " + strings.Join(skipped, "; ")
+    }
+    key.LineComment = comment
+
+    for _, step := range stageSteps(stage) {
+        var decoded buildfab.Step
+        if err := step.Decode(&decoded); err != nil || len(step.Content) < 2 {
+            continue
+        }
+        name := decoded.GetStepName()
+        stepOrigin := p.Origin("stages", stageName, name)
+        step.Content[1].LineComment = displayPath(stepOrigin)
+
+        for j := 2; j+1 < len(step.Content); j += 2 {
+            if origin := p.Origin("stages", stageName, name, step.Content[j].Value); origin != stepOrigin {
+                step.Content[j+1].LineComment = displayPath(origin)
+            }
+        }
+    }
+}
+
+// mappingValue returns the value node of a key of a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+    if node.Kind != yaml.MappingNode {
+        return nil
+    }
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value == key {
+            return node.Content[i+1]
+        }
+    }
+    return nil
+}
+
+// displayPath shortens a path for display: relative to the working directory
+// below it, with ~ for the home directory otherwise
+func displayPath(path string) string {
+    if !filepath.IsAbs(path) {
+        return path
+    }
+    if wd, err := os.Getwd(); err == nil {
+        if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+            return rel
+        }
+    }
+    if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, home+string(filepath.Separator)) {
+        return "~" + strings.TrimPrefix(path, home)
+    }
+    return path
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestLoadLayered(t *testing.T) {
+    tempDir := t.TempDir()
+
+    configContent := `
+project:
+  name: "test-project"
+actions:
+  - name: build
+    run: "echo build"
+  - name: slow
+    run: "sleep 10"
+  - name: lint
+    run: "echo lint"
+stages:
+  pre-push:
+    steps:
+      - action: build
+      - action: slow
+        require: [build]
+      - action: lint
+        require: [slow]
+`
+    userContent := `
+pre-push:
+  self_update: refuse
+actions:
+  - name: build
+    run: "echo user build"
+`
+    localContent := `
+stages:
+  pre-push:
+    steps:
+      - action: slow
+        skip: true
+      - action: lint
+        onerror: warn
+      - action: mine
+actions:
+  - name: mine
+    run: "echo mine"
+`
+    configPath := filepath.Join(tempDir, ".project.yml")
+    userPath := filepath.Join(tempDir, "user.yml")
+    localPath := LocalConfigPath(configPath)
+    for path, content := range map[string]string{configPath: configContent, userPath: userContent, localPath: localContent} {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+    if localPath != filepath.Join(tempDir, ".project.local.yml") {
+        t.Errorf("Expected local layer .project.local.yml, got %s", localPath)
+    }
+
+    project, err := LoadLayered(configPath, []string{filepath.Join(tempDir, "missing.yml"), userPath, localPath})
+    if err != nil {
+        t.Fatalf("Failed to load layered project: %v", err)
+    }
+
+    if len(project.Layers) != 2 {
+        t.Errorf("Expected 2 applied layers, got %v", project.Layers)
+    }
+    if project.Settings.SelfUpdate != "refuse" {
+        t.Errorf("Expected self_update from the user layer, got %q", project.Settings.SelfUpdate)
+    }
+    if action, _ := project.Config.GetAction("build"); action.Run != "echo user build" {
+        t.Errorf("Expected build action from the user layer, got %q", action.Run)
+    }
+
+    stage, _ := project.Config.GetStage("pre-push")
+    var names []string
+    for _, step := range stage.Steps {
+        names = append(names, step.GetStepName())
+    }
+    if strings.Join(names, ",") != "build,lint,mine" {
+        t.Fatalf("Expected steps build,lint,mine, got %v", names)
+    }
+    lint := stage.Steps[1]
+    if lint.OnError != "warn" || len(lint.Require) != 0 {
+        t.Errorf("Expected lint with onerror warn and no requirement on the skipped step, got %+v", lint)
+    }
+
+    if origin := project.Origin("stages", "pre-push", "lint", "onerror"); origin != localPath {
+        t.Errorf("Expected onerror origin %s, got %s", localPath, origin)
+    }
+    if origin := project.Origin("stages", "pre-push", "lint", "action"); origin != configPath {
+        t.Errorf("Expected action origin %s, got %s", configPath, origin)
+    }
+    if origin := project.Origin("actions", "build"); origin != userPath {
+        t.Errorf("Expected build origin %s, got %s", userPath, origin)
+    }
+
+    output, err := project.Render(true)
+    if err != nil {
+        t.Fatalf("Failed to render project: %v", err)
+    }
+    if !strings.Contains(string(output), "slow skipped by") {
+        t.Errorf("Expected skipped step in rendered configuration:\n%s", output)
+    }
+}
+
+func TestLoadLayeredInvalidKey(t *testing.T) {
+    tempDir := t.TempDir()
+    configPath := filepath.Join(tempDir, ".project.yml")
+    layerPath := filepath.Join(tempDir, "layer.yml")
+    configContent := `
+project:
+  name: "test-project"
+actions:
+  - name: build
+    run: "echo build"
+stages:
+  pre-push:
+    steps:
+      - action: build
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if err := os.WriteFile(layerPath, []byte("project:\n  name: other\n"), 0644); err != nil {
+        t.Fatalf("Failed to write layer file: %v", err)
+    }
+
+    if _, err := LoadLayered(configPath, []string{layerPath}); err == nil {
+        t.Error("Expected error for project in a layer")
+    }
+}
+
+// TestLoadLayeredPrecedence tests that each layer overrides the files below
+// it: project, system, user and local layer
+func TestLoadLayeredPrecedence(t *testing.T) {
+    tempDir := t.TempDir()
+    configPath := filepath.Join(tempDir, ".project.yml")
+    files := map[string]string{
+        configPath: `
+pre-push:
+  min_version: v1.0.0
+  self_update: warn
+  stash_unstaged: true
+project:
+  name: "test-project"
+actions:
+  - name: build
+    run: "echo project"
+stages:
+  pre-push:
+    steps:
+      - action: build
+`,
+        filepath.Join(tempDir, "system.yml"): "pre-push:\n  min_version: v1.1.0\n  self_update: refuse\n  stash_unstaged: false\n",
+        filepath.Join(tempDir, "user.yml"):   "pre-push:\n  self_update: update\n  stash_unstaged: true\n",
+        LocalConfigPath(configPath):          "pre-push:\n  stash_unstaged: false\n",
+    }
+    for path, content := range files {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+
+    layers := []string{filepath.Join(tempDir, "system.yml"), filepath.Join(tempDir, "user.yml"), LocalConfigPath(configPath)}
+    project, err := LoadLayered(configPath, layers)
+    if err != nil {
+        t.Fatalf("Failed to load layered project: %v", err)
+    }
+
+    if project.Settings.MinVersion != "v1.1.0" {
+        t.Errorf("Expected min_version of the system layer over the project, got %q", project.Settings.MinVersion)
+    }
+    if project.Settings.SelfUpdate != "update" {
+        t.Errorf("Expected self_update of the user layer over the system layer, got %q", project.Settings.SelfUpdate)
+    }
+    if project.Settings.IsStashUnstaged() {
+        t.Error("Expected stash_unstaged of the local layer over the user layer")
+    }
+    for key, want := range map[string]string{"min_version": layers[0], "self_update": layers[1], "stash_unstaged": layers[2]} {
+        if origin := project.Origin(settingsKey, key); origin != want {
+            t.Errorf("Expected %s origin %s, got %s", key, want, origin)
+        }
+    }
+}
+
+// TestLoadLayeredSecrets tests that layers add secrets and never remove them
+func TestLoadLayeredSecrets(t *testing.T) {
+    tempDir := t.TempDir()
+    configPath := filepath.Join(tempDir, ".project.yml")
+    layerPath := LocalConfigPath(configPath)
+    configContent := `
+pre-push:
+  secrets: [DEPLOY_KEY]
+  variables:
+    API_TOKEN:
+      secret: true
+project:
+  name: "test-project"
+actions:
+  - name: build
+    run: "echo build"
+stages:
+  pre-push:
+    steps:
+      - action: build
+`
+    layerContent := `
+pre-push:
+  secrets: [MY_TOKEN, DEPLOY_KEY]
+  variables:
+    API_TOKEN:
+      secret: false
+    LOCAL_PASSWORD:
+      secret: true
+`
+    for path, content := range map[string]string{configPath: configContent, layerPath: layerContent} {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+
+    project, err := LoadLayered(configPath, []string{layerPath})
+    if err != nil {
+        t.Fatalf("Failed to load layered project: %v", err)
+    }
+
+    if strings.Join(project.Settings.Secrets, ",") != "DEPLOY_KEY,MY_TOKEN" {
+        t.Errorf("Expected secrets DEPLOY_KEY,MY_TOKEN, got %v", project.Settings.Secrets)
+    }
+    if !project.Settings.Variables["API_TOKEN"].Secret {
+        t.Error("Expected API_TOKEN declared secret by the project to stay secret")
+    }
+    if !project.Settings.Variables["LOCAL_PASSWORD"].Secret {
+        t.Error("Expected LOCAL_PASSWORD declared secret by the layer")
+    }
+
+    masker := project.Settings.Masker()
+    for _, name := range []string{"DEPLOY_KEY", "MY_TOKEN", "API_TOKEN", "LOCAL_PASSWORD"} {
+        if !masker.IsSecret(name) {
+            t.Errorf("Expected %s to be masked", name)
+        }
+    }
+}
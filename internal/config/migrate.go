@@ -0,0 +1,347 @@
+package config
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// Migration is a configuration file rewritten to the current schema
+type Migration struct {
+    Path     string   // Path of the file
+    From     int      // Schema version of the file
+    Original []byte   // Content of the file
+    Migrated []byte   // Content in the current schema
+    Changes  []string // Descriptions of the changes
+}
+
+// Changed reports whether the migration changes the file
+func (m *Migration) Changed() bool {
+    return !bytes.Equal(m.Original, m.Migrated)
+}
+
+// migrations rewrite a configuration document of a schema version to the
+// next version and return descriptions of their changes
+var migrations = map[int]func(root *yaml.Node) []string{
+    1: migrateSchema1,
+}
+
+// MigrateProject rewrites a project configuration and the files it includes
+// to the current schema. Included files have no schema version of their own,
+// they are migrated from the version of the project configuration.
+func MigrateProject(path string) ([]*Migration, error) {
+    project, err := MigrateFile(path, 0)
+    if err != nil {
+        return nil, err
+    }
+    result := []*Migration{project}
+
+    seen := map[string]bool{}
+    if abs, err := filepath.Abs(path); err == nil {
+        seen[abs] = true
+    }
+    pending := []string{path}
+    for len(pending) > 0 {
+        file := pending[0]
+        pending = pending[1:]
+
+        patterns, err := includePatterns(file)
+        if err != nil {
+            return nil, err
+        }
+        for _, pattern := range patterns {
+            files, err := resolveInclude(pattern, filepath.Dir(file))
+            if err != nil {
+                return nil, err
+            }
+            for _, included := range files {
+                abs, err := filepath.Abs(included)
+                if err != nil || seen[abs] {
+                    continue
+                }
+                seen[abs] = true
+                migration, err := MigrateFile(included, project.From)
+                if err != nil {
+                    return nil, err
+                }
+                result = append(result, migration)
+                pending = append(pending, included)
+            }
+        }
+    }
+    return result, nil
+}
+
+// includePatterns returns the include patterns of a configuration file
+func includePatterns(path string) ([]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+    var config struct {
+        Include []string `yaml:"include"`
+    }
+    if err := yaml.Unmarshal(data, &config); err != nil {
+        return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+    }
+    return config.Include, nil
+}
+
+// MigrateFile rewrites a configuration file to the current schema. A
+// project configuration (from 0) is migrated from its schema field and gets
+// the current one, an included file is migrated from the given version.
+// Comments and the order of keys are kept.
+func MigrateFile(path string, from int) (*Migration, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+    }
+    migration := &Migration{Path: path, From: from, Original: data, Migrated: data}
+    if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+        return migration, nil
+    }
+    root := doc.Content[0]
+
+    project := from == 0
+    if project {
+        migration.From = 1
+        if node := mappingValue(root, schemaKey); node != nil {
+            if migration.From, err = strconv.Atoi(node.Value); err != nil || migration.From < 1 {
+                return nil, fmt.Errorf("%s: %s must be a positive integer", path, schemaKey)
+            }
+        }
+    }
+    if migration.From > prepush.SchemaVersion {
+        return nil, fmt.Errorf("%s: configuration schema %d is newer than the supported schema %d, update pre-push", path, migration.From, prepush.SchemaVersion)
+    }
+    if migration.From == prepush.SchemaVersion {
+        return migration, nil
+    }
+
+    for version := migration.From; version < prepush.SchemaVersion; version++ {
+        migration.Changes = append(migration.Changes, migrations[version](root)...)
+    }
+    if project {
+        setSchema(root, prepush.SchemaVersion)
+        migration.Changes = append(migration.Changes, fmt.Sprintf("set %s: %d", schemaKey, prepush.SchemaVersion))
+    }
+    if len(migration.Changes) == 0 {
+        return migration, nil
+    }
+
+    var buf bytes.Buffer
+    encoder := yaml.NewEncoder(&buf)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(&doc); err != nil {
+        return nil, fmt.Errorf("failed to write %s: %w", path, err)
+    }
+    if err := encoder.Close(); err != nil {
+        return nil, fmt.Errorf("failed to write %s: %w", path, err)
+    }
+    migration.Migrated = restoreBlankLines(data, buf.Bytes())
+    return migration, nil
+}
+
+// migrationApplies reports whether the migrations from a schema version
+// change a configuration document
+func migrationApplies(data []byte, from int) bool {
+    if from >= prepush.SchemaVersion {
+        return false
+    }
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+        return false
+    }
+    for version := from; version < prepush.SchemaVersion; version++ {
+        if len(migrations[version](doc.Content[0])) > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// setSchema sets the schema version, a new key is added first
+func setSchema(root *yaml.Node, version int) {
+    value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        if root.Content[i].Value == schemaKey {
+            root.Content[i+1] = value
+            return
+        }
+    }
+
+    key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: schemaKey}
+    // A comment heading the file stays above the new key
+    if len(root.Content) > 0 {
+        key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+    }
+    root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
+
+// migrateSchema1 rewrites the schema 1 form of the original pre-push
+// configuration: stage-level verbose and debug are removed, require given
+// as a single string becomes a list and depends_on is merged into require
+func migrateSchema1(root *yaml.Node) []string {
+    var changes []string
+    stages := mappingValue(root, "stages")
+    if stages == nil || stages.Kind != yaml.MappingNode {
+        return nil
+    }
+
+    for i := 0; i+1 < len(stages.Content); i += 2 {
+        name, stage := stages.Content[i].Value, stages.Content[i+1]
+        if stage.Kind != yaml.MappingNode {
+            continue
+        }
+        for _, key := range []string{"verbose", "debug"} {
+            if removeKey(stage, key) {
+                changes = append(changes, fmt.Sprintf("stages.%s: removed %s, use --%s or PRE_PUSH_%s", name, key, key, strings.ToUpper(key)))
+            }
+        }
+
+        for j, step := range stageSteps(stage) {
+            if step.Kind != yaml.MappingNode {
+                continue
+            }
+            path := fmt.Sprintf("stages.%s.steps[%d]", name, j)
+            for _, key := range []string{"require", "depends_on"} {
+                if node := mappingValue(step, key); node != nil && node.Kind == yaml.ScalarNode {
+                    toList(node)
+                    changes = append(changes, fmt.Sprintf("%s: %s %s is now a list", path, key, node.Content[0].Value))
+                }
+            }
+
+            dependsOn := mappingValue(step, "depends_on")
+            if dependsOn == nil {
+                continue
+            }
+            changes = append(changes, fmt.Sprintf("%s: depends_on merged into require", path))
+            require := mappingValue(step, "require")
+            if require == nil {
+                renameKey(step, "depends_on", "require")
+                continue
+            }
+            for _, dep := range dependsOn.Content {
+                if !hasScalar(require, dep.Value) {
+                    require.Content = append(require.Content, dep)
+                }
+            }
+            removeKey(step, "depends_on")
+        }
+    }
+    return changes
+}
+
+// toList turns a scalar node into a flow sequence holding the scalar,
+// keeping the comments on the sequence
+func toList(node *yaml.Node) {
+    item := *node
+    item.HeadComment, item.LineComment, item.FootComment = "", "", ""
+    *node = yaml.Node{
+        Kind:        yaml.SequenceNode,
+        Tag:         "!!seq",
+        Style:       yaml.FlowStyle,
+        Content:     []*yaml.Node{&item},
+        HeadComment: node.HeadComment,
+        LineComment: node.LineComment,
+        FootComment: node.FootComment,
+    }
+}
+
+// removeKey removes a key from a mapping, a comment above it moves to the
+// next key
+func removeKey(node *yaml.Node, key string) bool {
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value != key {
+            continue
+        }
+        if comment := node.Content[i].HeadComment; comment != "" && i+2 < len(node.Content) {
+            next := node.Content[i+2]
+            next.HeadComment = strings.TrimSpace(comment + "\n" + next.HeadComment)
+        }
+        node.Content = append(node.Content[:i], node.Content[i+2:]...)
+        return true
+    }
+    return false
+}
+
+// renameKey renames a key of a mapping in place
+func renameKey(node *yaml.Node, from, to string) {
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value == from {
+            node.Content[i].Value = to
+            return
+        }
+    }
+}
+
+// hasScalar reports whether a sequence contains a scalar value
+func hasScalar(node *yaml.Node, value string) bool {
+    for _, item := range node.Content {
+        if item.Value == value {
+            return true
+        }
+    }
+    return false
+}
+
+// restoreBlankLines puts the blank lines of the original document, which
+// the YAML encoder drops, back between the lines of the migrated document
+func restoreBlankLines(original, migrated []byte) []byte {
+    var lines []string
+    var blanks [][]string // Blank lines before each line of lines
+    var pending []string
+    for _, line := range splitLines(string(original)) {
+        if strings.TrimSpace(line) == "" {
+            pending = append(pending, line)
+            continue
+        }
+        lines = append(lines, line)
+        blanks = append(blanks, pending)
+        pending = nil
+    }
+    trailing := pending
+
+    var encoded []string
+    for _, line := range splitLines(string(migrated)) {
+        if strings.TrimSpace(line) != "" {
+            encoded = append(encoded, line)
+        }
+    }
+
+    var b strings.Builder
+    var removed []string // Blank lines before removed lines
+    for _, op := range diffLines(lines, encoded) {
+        switch op.kind {
+        case '-':
+            if len(blanks[op.old]) > len(removed) {
+                removed = blanks[op.old]
+            }
+            continue
+        case '=':
+            if len(blanks[op.old]) > 0 {
+                removed = blanks[op.old]
+            }
+        }
+        for _, blank := range removed {
+            b.WriteString(blank + "\n")
+        }
+        removed = nil
+        b.WriteString(encoded[op.new] + "\n")
+    }
+    for _, blank := range trailing {
+        b.WriteString(blank + "\n")
+    }
+    return []byte(b.String())
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+    configContent := `# Project configuration
+project:
+  name: "test-project"
+
+actions:
+  # Build it
+  - name: build
+    run: |
+      make
+
+      make install
+  - name: test
+    run: make test
+
+stages:
+  pre-push:
+    verbose: true
+    steps:
+      - action: build
+
+      - action: test
+        require: build # needs build
+        depends_on: [lint]
+      - action: lint
+        depends_on: build
+`
+    expected := `# Project configuration
+schema: 2
+project:
+  name: "test-project"
+
+actions:
+  # Build it
+  - name: build
+    run: |
+      make
+
+      make install
+  - name: test
+    run: make test
+
+stages:
+  pre-push:
+    steps:
+      - action: build
+
+      - action: test
+        require: [build, lint] # needs build
+      - action: lint
+        require: [build]
+`
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    migration, err := MigrateFile(configPath, 0)
+    if err != nil {
+        t.Fatalf("Failed to migrate configuration: %v", err)
+    }
+    if migration.From != 1 {
+        t.Errorf("Expected schema 1, got %d", migration.From)
+    }
+    if string(migration.Migrated) != expected {
+        t.Errorf("Unexpected migrated configuration:\n%s", migration.Migrated)
+    }
+    if len(migration.Changes) != 6 {
+        t.Errorf("Expected 6 changes, got %v", migration.Changes)
+    }
+
+    // The migrated configuration is current
+    if err := os.WriteFile(configPath, migration.Migrated, 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    migration, err = MigrateFile(configPath, 0)
+    if err != nil {
+        t.Fatalf("Failed to migrate configuration: %v", err)
+    }
+    if migration.Changed() {
+        t.Errorf("Expected no changes, got %v", migration.Changes)
+    }
+}
+
+func TestMigrateProject(t *testing.T) {
+    tempDir := t.TempDir()
+    configPath := filepath.Join(tempDir, ".project.yml")
+    includedPath := filepath.Join(tempDir, "stages.yml")
+    files := map[string]string{
+        configPath:   "project:\n  name: test\ninclude:\n  - stages.yml\nactions:\n  - name: build\n    run: make\n",
+        includedPath: "stages:\n  build:\n    debug: true\n    steps:\n      - action: build\n",
+    }
+    for path, content := range files {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+
+    migrations, err := MigrateProject(configPath)
+    if err != nil {
+        t.Fatalf("Failed to migrate configuration: %v", err)
+    }
+    if len(migrations) != 2 {
+        t.Fatalf("Expected 2 files, got %d", len(migrations))
+    }
+    if !strings.HasPrefix(string(migrations[0].Migrated), "schema: 2\n") {
+        t.Errorf("Expected schema in project configuration:\n%s", migrations[0].Migrated)
+    }
+    included := string(migrations[1].Migrated)
+    if strings.Contains(included, "schema") || strings.Contains(included, "debug") {
+        t.Errorf("Expected included file without schema and debug:\n%s", included)
+    }
+
+    // A newer schema is refused
+    if err := os.WriteFile(configPath, []byte("schema: 99\nproject:\n  name: test\n"), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if _, err := MigrateProject(configPath); err == nil {
+        t.Error("Expected error for a newer schema")
+    }
+}
+
+func TestUnifiedDiff(t *testing.T) {
+    old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+    new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+    expected := `--- a/file.yml
++++ b/file.yml
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+    if diff := UnifiedDiff("file.yml", []byte(old), []byte(new)); diff != expected {
+        t.Errorf("Unexpected diff:\n%s", diff)
+    }
+    if diff := UnifiedDiff("file.yml", []byte(old), []byte(old)); diff != "" {
+        t.Errorf("Expected no diff, got:\n%s", diff)
+    }
+}
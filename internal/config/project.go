@@ -0,0 +1,303 @@
+package config
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/mask"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// settingsKey is the top-level key of the pre-push settings in the project
+// configuration. buildfab rejects unknown keys, so the section is removed
+// before the configuration is passed to buildfab.
+const settingsKey = "pre-push"
+
+// schemaKey is the top-level key of the configuration schema version, it is
+// removed before the configuration is passed to buildfab as well
+const schemaKey = "schema"
+
+// Settings are the pre-push settings of a project
+type Settings struct {
+    MinVersion    string                      `yaml:"min_version,omitempty"`    // Minimum pre-push version the hook must be
+    SelfUpdate    string                      `yaml:"self_update,omitempty"`    // What an outdated hook does: warn, refuse or update
+    StashUnstaged *bool                       `yaml:"stash_unstaged,omitempty"` // Whether pre-commit runs on the index with unstaged changes stashed (default true)
+    Secrets       []string                    `yaml:"secrets,omitempty"`        // Names and patterns of variables whose values are masked
+    Variables     map[string]VariableSettings `yaml:"variables,omitempty"`      // Declarations of variables by name
+}
+
+// VariableSettings is the declaration of a variable
+type VariableSettings struct {
+    Secret bool `yaml:"secret,omitempty"` // The value is masked in output, reports and history
+}
+
+// IsStashUnstaged reports whether the pre-commit hook stashes unstaged changes
+func (s Settings) IsStashUnstaged() bool {
+    return s.StashUnstaged == nil || *s.StashUnstaged
+}
+
+// IsZero reports whether no setting is set
+func (s Settings) IsZero() bool {
+    return s.MinVersion == "" && s.SelfUpdate == "" && s.StashUnstaged == nil && len(s.Secrets) == 0 && len(s.Variables) == 0
+}
+
+// Masker returns the masker for the default secret patterns, the secrets
+// setting and the variables declared secret
+func (s Settings) Masker() *mask.Masker {
+    patterns := append([]string(nil), s.Secrets...)
+    for name, variable := range s.Variables {
+        if variable.Secret {
+            patterns = append(patterns, name)
+        }
+    }
+    sort.Strings(patterns)
+    return mask.New(patterns...)
+}
+
+// Project is a loaded project configuration
+type Project struct {
+    Path     string            // Path of the configuration file
+    Config   *buildfab.Config  // Configuration passed to buildfab
+    Settings Settings          // pre-push settings
+    Schema   int               // Configuration schema version
+    Layers   []string          // Layer files merged over the configuration
+    Origins  map[string]string // Files that set keys other than those of Path, see Origin
+    Skipped  []SkippedStep     // Steps removed by layers
+    hidden   hiddenOnly        // only lists with expressions while loading
+}
+
+// LoadProject loads a project configuration the way buildfab.LoadConfig does
+// (strict fields, includes, validation) and reads the pre-push settings.
+func LoadProject(path string) (*Project, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, fmt.Errorf("configuration file not found: %s", path)
+        }
+        return nil, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+
+    original := data
+    settings, schema, data, err := splitSettings(data)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse configuration file: %w", err)
+    }
+    if schema > prepush.SchemaVersion {
+        return nil, fmt.Errorf("configuration schema %d is newer than the supported schema %d, update pre-push", schema, prepush.SchemaVersion)
+    }
+    data, hidden, err := hideOnly(data)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse configuration file: %w", err)
+    }
+
+    config, err := buildfab.LoadConfigFromBytes(data)
+    if err != nil {
+        if migrationApplies(original, schema) {
+            return nil, fmt.Errorf("%w (configuration schema %d, run 'pre-push config migrate' to update it)", err, schema)
+        }
+        return nil, err
+    }
+
+    // Set working directory to the directory containing the config file
+    if config.Project.BinDir == "" {
+        config.Project.BinDir = filepath.Dir(path)
+    }
+
+    project := &Project{Path: path, Config: config, Settings: settings, Schema: schema, hidden: hidden}
+
+    // Process includes if present
+    if len(config.Include) > 0 {
+        visited := map[string]bool{}
+        if abs, err := filepath.Abs(path); err == nil {
+            visited[abs] = true
+        }
+        for _, pattern := range config.Include {
+            if err := project.includePattern(pattern, filepath.Dir(path), visited); err != nil {
+                return nil, fmt.Errorf("failed to process includes: %w", err)
+            }
+        }
+    }
+
+    project.hidden.restore(config)
+    project.hidden = nil
+    if err := validateConfig(config); err != nil {
+        return nil, err
+    }
+
+    return project, nil
+}
+
+// splitSettings removes the pre-push section and the schema version from a
+// configuration document and decodes them. Documents without a schema
+// version are schema 1, documents without either key are returned unchanged.
+func splitSettings(data []byte) (Settings, int, []byte, error) {
+    var settings Settings
+    schema := 1
+
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return settings, schema, nil, err
+    }
+    if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+        return settings, schema, data, nil
+    }
+
+    root := doc.Content[0]
+    stripped := false
+    for i := 0; i+1 < len(root.Content); {
+        switch root.Content[i].Value {
+        case settingsKey:
+            if err := decodeStrict(root.Content[i+1], &settings); err != nil {
+                return settings, schema, nil, fmt.Errorf("%s: %w", settingsKey, err)
+            }
+        case schemaKey:
+            if err := root.Content[i+1].Decode(&schema); err != nil || schema < 1 {
+                return settings, schema, nil, fmt.Errorf("%s: must be a positive integer", schemaKey)
+            }
+        default:
+            i += 2
+            continue
+        }
+        root.Content = append(root.Content[:i], root.Content[i+2:]...)
+        stripped = true
+    }
+    if !stripped {
+        return settings, schema, data, nil
+    }
+
+    data, err := yaml.Marshal(&doc)
+    if err != nil {
+        return settings, schema, nil, err
+    }
+    return settings, schema, data, nil
+}
+
+// decodeStrict decodes a YAML node rejecting unknown fields
+func decodeStrict(node *yaml.Node, out interface{}) error {
+    data, err := yaml.Marshal(node)
+    if err != nil {
+        return err
+    }
+    decoder := yaml.NewDecoder(bytes.NewReader(data))
+    decoder.KnownFields(true)
+    return decoder.Decode(out)
+}
+
+// includePattern merges the files matching an include pattern into the
+// configuration. Later actions and stages override earlier ones with the same
+// name, as in buildfab.
+func (p *Project) includePattern(pattern, baseDir string, visited map[string]bool) error {
+    files, err := resolveInclude(pattern, baseDir)
+    if err != nil {
+        return err
+    }
+    for _, file := range files {
+        if err := p.includeFile(file, visited); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// resolveInclude returns the files an include pattern names, relative
+// patterns are relative to baseDir. A glob pattern matches the .yml and
+// .yaml files of an existing directory, other patterns must name a file.
+func resolveInclude(pattern, baseDir string) ([]string, error) {
+    if !filepath.IsAbs(pattern) {
+        pattern = filepath.Join(baseDir, pattern)
+    }
+
+    if !strings.Contains(pattern, "*") {
+        if _, err := os.Stat(pattern); os.IsNotExist(err) {
+            return nil, fmt.Errorf("included file does not exist: %s", displayPath(pattern))
+        }
+        return []string{pattern}, nil
+    }
+
+    if _, err := os.Stat(filepath.Dir(pattern)); os.IsNotExist(err) {
+        return nil, fmt.Errorf("directory for include pattern does not exist: %s", displayPath(filepath.Dir(pattern)))
+    }
+    matches, err := filepath.Glob(pattern)
+    if err != nil {
+        return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+    }
+    var files []string
+    for _, match := range matches {
+        lower := strings.ToLower(match)
+        if strings.HasSuffix(lower, ".yml") || strings.HasSuffix(lower, ".yaml") {
+            files = append(files, match)
+        }
+    }
+    return files, nil
+}
+
+// includeFile merges an included configuration file into the configuration
+func (p *Project) includeFile(path string, visited map[string]bool) error {
+    if abs, err := filepath.Abs(path); err == nil {
+        path = abs
+    }
+    if visited[path] {
+        return fmt.Errorf("circular include detected: %s", path)
+    }
+    visited[path] = true
+    defer delete(visited, path)
+
+    content, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return fmt.Errorf("included file does not exist: %s", path)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to read file %s: %w", path, err)
+    }
+
+    content, hidden, err := hideOnly(content)
+    if err != nil {
+        return fmt.Errorf("failed to parse YAML in file %s: %w", path, err)
+    }
+    included, err := buildfab.LoadConfigFromBytes(content)
+    if err != nil {
+        return fmt.Errorf("failed to parse YAML in file %s: %w", path, err)
+    }
+
+    for _, pattern := range included.Include {
+        if err := p.includePattern(pattern, filepath.Dir(path), visited); err != nil {
+            return err
+        }
+    }
+
+    config := p.Config
+    for _, action := range included.Actions {
+        p.setOrigin(path, "actions", action.Name)
+        found := false
+        for i, existing := range config.Actions {
+            if existing.Name == action.Name {
+                config.Actions[i] = action
+                found = true
+                break
+            }
+        }
+        if !found {
+            config.Actions = append(config.Actions, action)
+        }
+    }
+
+    if config.Stages == nil {
+        config.Stages = make(map[string]buildfab.Stage)
+    }
+    for name, stage := range included.Stages {
+        config.Stages[name] = stage
+        p.setOrigin(path, "stages", name)
+        if p.hidden == nil {
+            p.hidden = hiddenOnly{}
+        }
+        p.hidden[name] = hidden[name]
+    }
+
+    return nil
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestLoadProject(t *testing.T) {
+    tempDir := t.TempDir()
+
+    configContent := `
+pre-push:
+  min_version: v1.12.0
+  self_update: refuse
+
+project:
+  name: "test-project"
+
+include:
+  - actions/*.yml
+
+actions:
+  - name: test-action
+    run: "echo test"
+
+stages:
+  pre-push:
+    steps:
+      - action: test-action
+      - action: lint
+`
+    includeContent := `
+actions:
+  - name: lint
+    run: "echo lint"
+`
+
+    configPath := filepath.Join(tempDir, ".project.yml")
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if err := os.MkdirAll(filepath.Join(tempDir, "actions"), 0755); err != nil {
+        t.Fatalf("Failed to create include dir: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(tempDir, "actions", "lint.yml"), []byte(includeContent), 0644); err != nil {
+        t.Fatalf("Failed to write include file: %v", err)
+    }
+
+    project, err := LoadProject(configPath)
+    if err != nil {
+        t.Fatalf("Failed to load project: %v", err)
+    }
+
+    if project.Settings.MinVersion != "v1.12.0" || project.Settings.SelfUpdate != "refuse" {
+        t.Errorf("Expected settings to be read, got %+v", project.Settings)
+    }
+    if project.Config.Project.Name != "test-project" {
+        t.Errorf("Expected project name 'test-project', got '%s'", project.Config.Project.Name)
+    }
+    if _, exists := project.Config.GetAction("lint"); !exists {
+        t.Error("Expected included action to be merged")
+    }
+    if project.Config.Project.BinDir != tempDir {
+        t.Errorf("Expected bin dir %s, got %s", tempDir, project.Config.Project.BinDir)
+    }
+}
+
+func TestLoadProjectUnknownSetting(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `
+pre-push:
+  min_versoin: v1.12.0
+project:
+  name: "test-project"
+actions:
+  - name: test-action
+    run: "echo test"
+stages:
+  pre-push:
+    steps:
+      - action: test-action
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    if _, err := LoadProject(configPath); err == nil {
+        t.Error("Expected error for unknown setting")
+    }
+}
+
+func TestLoadProjectSecrets(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `
+pre-push:
+  secrets:
+    - DEPLOY_KEY
+    - "*_CREDENTIALS"
+  variables:
+    env.SIGNING_PASSPHRASE:
+      secret: true
+    env.TARGET:
+      secret: false
+project:
+  name: "test-project"
+actions:
+  - name: test-action
+    run: "echo test"
+stages:
+  pre-push:
+    steps:
+      - action: test-action
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    project, err := LoadProject(configPath)
+    if err != nil {
+        t.Fatalf("Failed to load project: %v", err)
+    }
+    if project.Settings.IsZero() {
+        t.Fatal("Expected secret settings to be read")
+    }
+
+    masker := project.Settings.Masker()
+    cases := map[string]bool{
+        "env.DEPLOY_KEY":         true,
+        "env.GCP_CREDENTIALS":    true,
+        "env.SIGNING_PASSPHRASE": true,
+        "env.GITHUB_TOKEN":       true,
+        "env.TARGET":             false,
+    }
+    for name, expected := range cases {
+        if got := masker.IsSecret(name); got != expected {
+            t.Errorf("IsSecret(%q) = %v, expected %v", name, got, expected)
+        }
+    }
+
+    invalid := strings.Replace(configContent, "secret: false", "secret: maybe", 1)
+    if err := os.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if _, err := LoadProject(configPath); err == nil {
+        t.Error("Expected error for an invalid secret declaration")
+    }
+}
+
+func TestLoadProjectSchema(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `
+schema: 2
+project:
+  name: "test-project"
+actions:
+  - name: test-action
+    run: "echo test"
+stages:
+  pre-push:
+    steps:
+      - action: test-action
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    project, err := LoadProject(configPath)
+    if err != nil {
+        t.Fatalf("Failed to load project: %v", err)
+    }
+    if project.Schema != 2 {
+        t.Errorf("Expected schema 2, got %d", project.Schema)
+    }
+
+    newer := strings.Replace(configContent, "schema: 2", "schema: 99", 1)
+    if err := os.WriteFile(configPath, []byte(newer), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if _, err := LoadProject(configPath); err == nil || !strings.Contains(err.Error(), "update pre-push") {
+        t.Errorf("Expected error for a newer schema, got %v", err)
+    }
+}
+
+// TestLoadProjectMigrateHint tests that a load error of an older schema
+// suggests migrating only when a migration applies to the document
+func TestLoadProjectMigrateHint(t *testing.T) {
+    tests := []struct {
+        name  string
+        stage string // Stage definition of a schema 1 document
+        hint  bool
+    }{
+        {
+            name:  "unknown key",
+            stage: "    steps:\n      - action: test-action\n        runn: echo\n",
+        },
+        {
+            name:  "stage verbose",
+            stage: "    verbose: true\n    steps:\n      - action: test-action\n",
+            hint:  true,
+        },
+        {
+            name:  "depends_on",
+            stage: "    steps:\n      - action: test-action\n        depends_on: build\n",
+            hint:  true,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            configPath := filepath.Join(t.TempDir(), ".project.yml")
+            configContent := "project:\n  name: test-project\nactions:\n  - name: test-action\n    run: echo test\nstages:\n  pre-push:\n" + test.stage
+            if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+                t.Fatalf("Failed to write config file: %v", err)
+            }
+
+            _, err := LoadProject(configPath)
+            if err == nil {
+                t.Fatal("Expected a load error")
+            }
+            if hint := strings.Contains(err.Error(), "pre-push config migrate"); hint != test.hint {
+                t.Errorf("Expected migrate hint %v, got %v", test.hint, err)
+            }
+        })
+    }
+}
+
+func TestLoadProjectConditions(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `
+project:
+  name: "test-project"
+actions:
+  - name: test-action
+    run: "echo test"
+stages:
+  pre-push:
+    steps:
+      - action: test-action
+        if: version.major > 1
+        only: [release, 'branch =~ "^release/"']
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    project, err := LoadProject(configPath)
+    if err != nil {
+        t.Fatalf("Failed to load project: %v", err)
+    }
+    step := project.Config.Stages["pre-push"].Steps[0]
+    if len(step.Only) != 2 || step.Only[1] != `branch =~ "^release/"` {
+        t.Errorf("Expected the only expression to be kept, got %v", step.Only)
+    }
+
+    invalid := strings.Replace(configContent, "version.major > 1", `version.major > "1"`, 1)
+    if err := os.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    if _, err := LoadProject(configPath); err == nil || !strings.Contains(err.Error(), "cannot compare number with string") {
+        t.Errorf("Expected a type error, got %v", err)
+    }
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// ProjectType is a kind of project init generates actions for
+type ProjectType string
+
+// Project types recognized by their marker files
+const (
+    ProjectGo     ProjectType = "go"     // go.mod
+    ProjectConan  ProjectType = "conan"  // conanfile.py or conanfile.txt
+    ProjectCMake  ProjectType = "cmake"  // CMakeLists.txt
+    ProjectNode   ProjectType = "node"   // package.json
+    ProjectDocker ProjectType = "docker" // Dockerfile
+)
+
+// ProjectTypes lists the project types in the order their actions are
+// generated
+var ProjectTypes = []ProjectType{ProjectGo, ProjectConan, ProjectCMake, ProjectNode, ProjectDocker}
+
+// projectMarkers are the files that identify a project type
+var projectMarkers = map[ProjectType][]string{
+    ProjectGo:     {"go.mod"},
+    ProjectConan:  {"conanfile.py", "conanfile.txt"},
+    ProjectCMake:  {"CMakeLists.txt"},
+    ProjectNode:   {"package.json"},
+    ProjectDocker: {"Dockerfile"},
+}
+
+// gitCheckStep is the Git check the first step of every build chain requires
+const gitCheckStep = "git-uncommitted"
+
+// scaffoldStep is a generated action with its step in the pre-push stage
+type scaffoldStep struct {
+    action  buildfab.Action
+    require []string
+    onerror string
+    heavy   bool // Marked only: [release]
+}
+
+// ParseProjectTypes parses project type names
+func ParseProjectTypes(names []string) ([]ProjectType, error) {
+    var types []ProjectType
+    for _, name := range names {
+        typ := ProjectType(strings.ToLower(strings.TrimSpace(name)))
+        if _, ok := projectMarkers[typ]; !ok {
+            return nil, fmt.Errorf("unknown project type %q (supported: %s)", name, joinTypes(ProjectTypes))
+        }
+        types = append(types, typ)
+    }
+    return types, nil
+}
+
+// DetectProjectTypes returns the types of the project in a directory by
+// their marker files
+func DetectProjectTypes(dir string) []ProjectType {
+    var types []ProjectType
+    for _, typ := range ProjectTypes {
+        for _, marker := range projectMarkers[typ] {
+            if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+                types = append(types, typ)
+                break
+            }
+        }
+    }
+    return types
+}
+
+// Scaffold generates a starter configuration for the project in a
+// directory: the Git built-in checks and the build and test actions of each
+// project type, with heavy steps marked only: [release]
+func Scaffold(dir string, types []ProjectType) ([]byte, error) {
+    name := projectName(dir, types)
+    has := map[ProjectType]bool{}
+    for _, typ := range types {
+        has[typ] = true
+    }
+
+    steps := []scaffoldStep{
+        {action: buildfab.Action{Name: "git-untracked", Uses: "git@untracked"}},
+        {action: buildfab.Action{Name: gitCheckStep, Uses: "git@uncommitted"}},
+        {action: buildfab.Action{Name: "git-modified", Uses: "git@modified"}, onerror: "warn"},
+    }
+    for _, typ := range ProjectTypes {
+        if !has[typ] {
+            continue
+        }
+        switch typ {
+        case ProjectGo:
+            steps = append(steps, goSteps()...)
+        case ProjectConan:
+            steps = append(steps, conanSteps()...)
+        case ProjectCMake:
+            steps = append(steps, cmakeSteps(has[ProjectConan])...)
+        case ProjectNode:
+            steps = append(steps, nodeSteps(dir)...)
+        case ProjectDocker:
+            steps = append(steps, scaffoldStep{
+                action:  buildfab.Action{Name: "docker-build", Description: "Build the container image", Run: fmt.Sprintf("docker build -t %s .", strings.ToLower(name))},
+                require: []string{gitCheckStep},
+                heavy:   true,
+            })
+        }
+    }
+
+    doc := struct {
+        Schema  int                       `yaml:"schema"`
+        Project buildfab.Project          `yaml:"project"`
+        Actions []buildfab.Action         `yaml:"actions"`
+        Stages  map[string]buildfab.Stage `yaml:"stages"`
+    }{
+        Schema:  prepush.SchemaVersion,
+        Project: buildfab.Project{Name: name, Modules: []string{name}},
+        Stages:  map[string]buildfab.Stage{},
+    }
+    var stage buildfab.Stage
+    release := false
+    for _, step := range steps {
+        doc.Actions = append(doc.Actions, step.action)
+        s := buildfab.Step{Action: step.action.Name, Require: step.require, OnError: step.onerror}
+        if step.heavy {
+            s.Only = []string{"release"}
+            release = true
+        }
+        stage.Steps = append(stage.Steps, s)
+    }
+    doc.Stages["pre-push"] = stage
+
+    var node yaml.Node
+    if err := node.Encode(doc); err != nil {
+        return nil, err
+    }
+    flowLists(&node)
+    description := "the Git checks"
+    if len(types) > 0 {
+        description = "a " + joinTypes(types) + " project"
+    }
+    comment := []string{fmt.Sprintf("pre-push configuration for %s, generated by 'pre-push init'.", description)}
+    if release {
+        comment = append(comment, "Heavy steps are marked only: [release], they run for release versions only.")
+    }
+    comment = append(comment, "Check changes with 'pre-push validate' and try them with 'pre-push test'.")
+    node.HeadComment = strings.Join(comment, "\n")
+
+    var buf bytes.Buffer
+    encoder := yaml.NewEncoder(&buf)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(&node); err != nil {
+        return nil, err
+    }
+    if err := encoder.Close(); err != nil {
+        return nil, err
+    }
+    return spaceSections(buf.Bytes()), nil
+}
+
+// goSteps builds, vets and tests a Go module, the race detector is a
+// release step
+func goSteps() []scaffoldStep {
+    return []scaffoldStep{
+        {action: buildfab.Action{Name: "go-build", Run: "go build ./..."}, require: []string{gitCheckStep}},
+        {action: buildfab.Action{Name: "go-vet", Run: "go vet ./..."}, require: []string{"go-build"}},
+        {action: buildfab.Action{Name: "go-test", Run: "go test ./..."}, require: []string{"go-build"}},
+        {action: buildfab.Action{Name: "go-test-race", Run: "go test -race ./..."}, require: []string{"go-test"}, heavy: true},
+    }
+}
+
+// conanSteps installs the Conan dependencies
+func conanSteps() []scaffoldStep {
+    return []scaffoldStep{{
+        action:  buildfab.Action{Name: "conan-install", Run: "conan install . --output-folder=build --build=missing"},
+        require: []string{gitCheckStep},
+        heavy:   true,
+    }}
+}
+
+// cmakeSteps configures, builds and tests a CMake project as release steps,
+// with the Conan toolchain if the project uses Conan
+func cmakeSteps(conan bool) []scaffoldStep {
+    configure := scaffoldStep{
+        action:  buildfab.Action{Name: "cmake-configure", Run: "cmake -S . -B build -DCMAKE_BUILD_TYPE=Release"},
+        require: []string{gitCheckStep},
+        heavy:   true,
+    }
+    if conan {
+        configure.action.Run += " -DCMAKE_TOOLCHAIN_FILE=build/conan_toolchain.cmake"
+        configure.require = []string{"conan-install"}
+    }
+    return []scaffoldStep{
+        configure,
+        {action: buildfab.Action{Name: "cmake-build", Run: "cmake --build build --parallel"}, require: []string{"cmake-configure"}, heavy: true},
+        {action: buildfab.Action{Name: "ctest", Run: "ctest --test-dir build --output-on-failure"}, require: []string{"cmake-build"}, heavy: true},
+    }
+}
+
+// nodeSteps runs the lint, test and build scripts of package.json with
+// the package manager of the lock file, the build is a release step
+func nodeSteps(dir string) []scaffoldStep {
+    manager := "npm"
+    for lock, name := range map[string]string{"yarn.lock": "yarn", "pnpm-lock.yaml": "pnpm"} {
+        if _, err := os.Stat(filepath.Join(dir, lock)); err == nil {
+            manager = name
+        }
+    }
+
+    var steps []scaffoldStep
+    scripts := packageJSON(dir).Scripts
+    if _, ok := scripts["lint"]; ok {
+        steps = append(steps, scaffoldStep{action: buildfab.Action{Name: "node-lint", Run: manager + " run lint"}, require: []string{gitCheckStep}})
+    }
+    if _, ok := scripts["test"]; ok {
+        steps = append(steps, scaffoldStep{action: buildfab.Action{Name: "node-test", Run: manager + " test"}, require: []string{gitCheckStep}})
+    }
+    if _, ok := scripts["build"]; ok {
+        steps = append(steps, scaffoldStep{action: buildfab.Action{Name: "node-build", Run: manager + " run build"}, require: []string{gitCheckStep}, heavy: true})
+    }
+    return steps
+}
+
+// packageJSON reads the parts of package.json init uses
+func packageJSON(dir string) (pkg struct {
+    Name    string            `json:"name"`
+    Scripts map[string]string `json:"scripts"`
+}) {
+    if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+        json.Unmarshal(data, &pkg)
+    }
+    return pkg
+}
+
+// projectName returns the name of the Go module or Node package, or the
+// name of the directory
+func projectName(dir string, types []ProjectType) string {
+    for _, typ := range types {
+        switch typ {
+        case ProjectGo:
+            if file, err := os.Open(filepath.Join(dir, "go.mod")); err == nil {
+                scanner := bufio.NewScanner(file)
+                for scanner.Scan() {
+                    if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+                        file.Close()
+                        return path.Base(strings.Trim(strings.TrimSpace(module), `"`))
+                    }
+                }
+                file.Close()
+            }
+        case ProjectNode:
+            if name := packageJSON(dir).Name; name != "" {
+                return path.Base(name)
+            }
+        }
+    }
+    if abs, err := filepath.Abs(dir); err == nil {
+        return filepath.Base(abs)
+    }
+    return filepath.Base(dir)
+}
+
+// flowLists writes the require, only and modules lists in flow style
+func flowLists(node *yaml.Node) {
+    for i, child := range node.Content {
+        if node.Kind == yaml.MappingNode && i%2 == 1 && child.Kind == yaml.SequenceNode {
+            switch node.Content[i-1].Value {
+            case "require", "only", "modules":
+                child.Style = yaml.FlowStyle
+            }
+        }
+        flowLists(child)
+    }
+}
+
+// spaceSections separates the top-level keys with blank lines
+func spaceSections(data []byte) []byte {
+    lines := strings.SplitAfter(string(data), "\n")
+    var b strings.Builder
+    for i, line := range lines {
+        if i > 0 && line != "" && line[0] != ' ' && line[0] != '#' && line[0] != '-' && !strings.HasPrefix(lines[i-1], "#") {
+            b.WriteString("\n")
+        }
+        b.WriteString(line)
+    }
+    return []byte(b.String())
+}
+
+// joinTypes joins project type names for messages
+func joinTypes(types []ProjectType) string {
+    names := make([]string, len(types))
+    for i, typ := range types {
+        names[i] = string(typ)
+    }
+    return strings.Join(names, ", ")
+}
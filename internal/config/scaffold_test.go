@@ -0,0 +1,108 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestDetectProjectTypes(t *testing.T) {
+    tempDir := t.TempDir()
+    for _, name := range []string{"go.mod", "Dockerfile", "conanfile.txt", "CMakeLists.txt"} {
+        if err := os.WriteFile(filepath.Join(tempDir, name), nil, 0644); err != nil {
+            t.Fatalf("Failed to write %s: %v", name, err)
+        }
+    }
+
+    types := DetectProjectTypes(tempDir)
+    expected := []ProjectType{ProjectGo, ProjectConan, ProjectCMake, ProjectDocker}
+    if !reflect.DeepEqual(types, expected) {
+        t.Errorf("Expected %v, got %v", expected, types)
+    }
+
+    if types, err := ParseProjectTypes([]string{"Node", "docker"}); err != nil || !reflect.DeepEqual(types, []ProjectType{ProjectNode, ProjectDocker}) {
+        t.Errorf("Expected [node docker], got %v, %v", types, err)
+    }
+    if _, err := ParseProjectTypes([]string{"rust"}); err == nil {
+        t.Error("Expected error for an unknown project type")
+    }
+}
+
+func TestScaffold(t *testing.T) {
+    tempDir := t.TempDir()
+    files := map[string]string{
+        "go.mod":         "module github.com/example/demo\n\ngo 1.24\n",
+        "conanfile.py":   "",
+        "CMakeLists.txt": "",
+        "package.json":   `{"name": "@example/web", "scripts": {"test": "jest", "build": "vite build"}}`,
+        "yarn.lock":      "",
+        "Dockerfile":     "",
+    }
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write %s: %v", name, err)
+        }
+    }
+
+    data, err := Scaffold(tempDir, DetectProjectTypes(tempDir))
+    if err != nil {
+        t.Fatalf("Failed to generate configuration: %v", err)
+    }
+    content := string(data)
+    for _, want := range []string{
+        "schema: 2\n\nproject:\n  name: demo\n  modules: [demo]\n",
+        "uses: git@uncommitted",
+        "run: conan install . --output-folder=build --build=missing",
+        "-DCMAKE_TOOLCHAIN_FILE=build/conan_toolchain.cmake",
+        "run: yarn test",
+        "run: docker build -t demo .",
+        "      - action: go-test-race\n        require: [go-test]\n        only: [release]\n",
+        "      - action: cmake-configure\n        require: [conan-install]\n        only: [release]\n",
+        "      - action: node-test\n        require: [git-uncommitted]\n",
+        "      - action: git-modified\n        onerror: warn\n",
+    } {
+        if !strings.Contains(content, want) {
+            t.Errorf("Expected %q in configuration:\n%s", want, content)
+        }
+    }
+    if strings.Contains(content, "node-lint") {
+        t.Errorf("Expected no lint step without a lint script:\n%s", content)
+    }
+
+    // The generated configuration is valid and loads
+    configPath := filepath.Join(tempDir, ".project.yml")
+    if err := os.WriteFile(configPath, data, 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+    diagnostics, err := Validate(configPath)
+    if err != nil {
+        t.Fatalf("Failed to validate configuration: %v", err)
+    }
+    if len(diagnostics) != 0 {
+        t.Errorf("Expected no diagnostics, got %v", diagnostics)
+    }
+    if _, err := LoadProject(configPath); err != nil {
+        t.Errorf("Failed to load generated configuration: %v", err)
+    }
+}
+
+func TestScaffoldGitOnly(t *testing.T) {
+    tempDir := filepath.Join(t.TempDir(), "plain")
+    if err := os.Mkdir(tempDir, 0755); err != nil {
+        t.Fatalf("Failed to create directory: %v", err)
+    }
+
+    data, err := Scaffold(tempDir, nil)
+    if err != nil {
+        t.Fatalf("Failed to generate configuration: %v", err)
+    }
+    content := string(data)
+    if !strings.Contains(content, "name: plain") || !strings.Contains(content, "for the Git checks") {
+        t.Errorf("Unexpected configuration:\n%s", content)
+    }
+    if strings.Contains(content, "only:") {
+        t.Errorf("Expected no release steps:\n%s", content)
+    }
+}
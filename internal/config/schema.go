@@ -0,0 +1,293 @@
+package config
+
+import (
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Schema is the JSON Schema of the project configuration and its includes
+//
+//go:embed pre-push.schema.json
+var Schema []byte
+
+// schema is the part of JSON Schema the validator understands: $ref to
+// $defs, type, properties, additionalProperties, required, enum, items,
+// minItems and minimum
+type schema struct {
+    Ref                  string             `json:"$ref"`
+    Defs                 map[string]*schema `json:"$defs"`
+    Title                string             `json:"title"`
+    Type                 string             `json:"type"`
+    Properties           map[string]*schema `json:"properties"`
+    AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+    Required             []string           `json:"required"`
+    Enum                 []string           `json:"enum"`
+    Items                *schema            `json:"items"`
+    MinItems             *int               `json:"minItems"`
+    Minimum              *float64           `json:"minimum"`
+}
+
+// schemaValidator checks YAML nodes of one file against the schema
+type schemaValidator struct {
+    root        *schema
+    file        string
+    diagnostics []Diagnostic
+}
+
+// loadSchema parses the embedded schema
+func loadSchema() (*schema, error) {
+    var root schema
+    if err := json.Unmarshal(Schema, &root); err != nil {
+        return nil, fmt.Errorf("invalid configuration schema: %w", err)
+    }
+    return &root, nil
+}
+
+// resolve follows a $ref to its definition
+func (v *schemaValidator) resolve(s *schema) *schema {
+    for s != nil && s.Ref != "" {
+        s = v.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+    }
+    return s
+}
+
+// report adds a diagnostic at the position of a node
+func (v *schemaValidator) report(node *yaml.Node, format string, args ...interface{}) {
+    v.diagnostics = append(v.diagnostics, Diagnostic{
+        File:    v.file,
+        Line:    node.Line,
+        Column:  node.Column,
+        Message: fmt.Sprintf(format, args...),
+    })
+}
+
+// validate checks a node against a schema, path is the dotted key of the
+// node used in messages
+func (v *schemaValidator) validate(node *yaml.Node, s *schema, path string) {
+    s = v.resolve(s)
+    if s == nil {
+        return
+    }
+    if node.Kind == yaml.AliasNode && node.Alias != nil {
+        node = node.Alias
+    }
+
+    if s.Type != "" && !matchesType(node, s.Type) {
+        v.report(node, "%sexpected %s, got %s", prefix(path), s.Type, nodeType(node))
+        return
+    }
+
+    if len(s.Enum) > 0 && node.Kind == yaml.ScalarNode && !contains(s.Enum, node.Value) {
+        message := fmt.Sprintf("%sinvalid value %q (must be one of %s)", prefix(path), node.Value, strings.Join(s.Enum, ", "))
+        if suggestion := suggest(node.Value, s.Enum); suggestion != "" {
+            message += fmt.Sprintf(", did you mean %q?", suggestion)
+        }
+        v.report(node, "%s", message)
+    }
+
+    if s.Minimum != nil && node.Kind == yaml.ScalarNode {
+        if value, err := strconv.ParseFloat(node.Value, 64); err == nil && value < *s.Minimum {
+            v.report(node, "%smust be at least %v", prefix(path), *s.Minimum)
+        }
+    }
+
+    switch node.Kind {
+    case yaml.MappingNode:
+        v.validateMapping(node, s, path)
+    case yaml.SequenceNode:
+        if s.MinItems != nil && len(node.Content) < *s.MinItems {
+            v.report(node, "%smust have at least %d item(s)", prefix(path), *s.MinItems)
+        }
+        if s.Items != nil {
+            for i, item := range node.Content {
+                v.validate(item, s.Items, fmt.Sprintf("%s[%d]", path, i))
+            }
+        }
+    }
+}
+
+// validateMapping checks the keys of a mapping: known properties, the
+// schema of additional ones and the required keys
+func (v *schemaValidator) validateMapping(node *yaml.Node, s *schema, path string) {
+    open, additional := s.additional()
+    seen := map[string]bool{}
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        key, value := node.Content[i], node.Content[i+1]
+        if key.Value == "<<" {
+            continue
+        }
+        if seen[key.Value] {
+            v.report(key, "%sduplicate key %q", prefix(path), key.Value)
+        }
+        seen[key.Value] = true
+
+        child := joinPath(path, key.Value)
+        if property, ok := s.Properties[key.Value]; ok {
+            v.validate(value, property, child)
+            continue
+        }
+        if additional != nil {
+            v.validate(value, additional, child)
+            continue
+        }
+        if !open {
+            message := fmt.Sprintf("%sunknown key %q", prefix(path), key.Value)
+            if suggestion := suggest(key.Value, s.propertyNames()); suggestion != "" {
+                message += fmt.Sprintf(", did you mean %q?", suggestion)
+            }
+            v.report(key, "%s", message)
+        }
+    }
+
+    for _, name := range s.Required {
+        if !seen[name] {
+            v.report(node, "%smissing required key %q", prefix(path), name)
+        }
+    }
+}
+
+// additional reports whether a mapping accepts keys other than its
+// properties and the schema of their values, if any
+func (s *schema) additional() (bool, *schema) {
+    raw := strings.TrimSpace(string(s.AdditionalProperties))
+    switch raw {
+    case "", "true":
+        return true, nil
+    case "false":
+        return false, nil
+    }
+    var additional schema
+    if err := json.Unmarshal(s.AdditionalProperties, &additional); err != nil {
+        return true, nil
+    }
+    return true, &additional
+}
+
+// propertyNames returns the sorted property names of a schema
+func (s *schema) propertyNames() []string {
+    names := make([]string, 0, len(s.Properties))
+    for name := range s.Properties {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// matchesType checks a node against a JSON Schema type. Any non-null
+// scalar is a string, as YAML decodes it into a string field.
+func matchesType(node *yaml.Node, typ string) bool {
+    switch typ {
+    case "object":
+        return node.Kind == yaml.MappingNode
+    case "array":
+        return node.Kind == yaml.SequenceNode
+    case "string":
+        return node.Kind == yaml.ScalarNode && node.ShortTag() != "!!null"
+    case "integer":
+        return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!int"
+    case "number":
+        return node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!int" || node.ShortTag() == "!!float")
+    case "boolean":
+        return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool"
+    case "null":
+        return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+    }
+    return true
+}
+
+// nodeType names the JSON Schema type of a node for messages
+func nodeType(node *yaml.Node) string {
+    switch node.Kind {
+    case yaml.MappingNode:
+        return "object"
+    case yaml.SequenceNode:
+        return "array"
+    }
+    switch node.ShortTag() {
+    case "!!int":
+        return "integer"
+    case "!!float":
+        return "number"
+    case "!!bool":
+        return "boolean"
+    case "!!null":
+        return "null"
+    }
+    return "string"
+}
+
+// joinPath appends a key to a dotted path
+func joinPath(path, key string) string {
+    if path == "" {
+        return key
+    }
+    return path + "." + key
+}
+
+// prefix returns the path as a message prefix
+func prefix(path string) string {
+    if path == "" {
+        return ""
+    }
+    return path + ": "
+}
+
+// contains reports whether a list contains a value
+func contains(list []string, value string) bool {
+    for _, item := range list {
+        if item == value {
+            return true
+        }
+    }
+    return false
+}
+
+// suggest returns the candidate closest to a misspelled name, or an empty
+// string if none is close enough to be a typo
+func suggest(name string, candidates []string) string {
+    best, bestDistance := "", 0
+    for _, candidate := range candidates {
+        distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+        limit := len(candidate) / 3
+        if limit < 1 {
+            limit = 1
+        }
+        if distance <= limit && (best == "" || distance < bestDistance) {
+            best, bestDistance = candidate, distance
+        }
+    }
+    return best
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters that turn a into b
+func editDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    rows := make([][]int, len(ra)+1)
+    for i := range rows {
+        rows[i] = make([]int, len(rb)+1)
+        rows[i][0] = i
+    }
+    for j := range rows[0] {
+        rows[0][j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+            if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+                rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+            }
+        }
+    }
+    return rows[len(ra)][len(rb)]
+}
@@ -0,0 +1,515 @@
+package config
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "gopkg.in/yaml.v3"
+    "github.com/AlexBurnes/pre-push/internal/expr"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// Diagnostic is a problem found in a configuration file
+type Diagnostic struct {
+    File    string // Path of the file
+    Line    int    // Line of the problem, 0 if unknown
+    Column  int    // Column of the problem, 0 if unknown
+    Message string
+}
+
+// String formats the diagnostic as file:line:col: message
+func (d Diagnostic) String() string {
+    if d.Line == 0 {
+        return fmt.Sprintf("%s: %s", displayPath(d.File), d.Message)
+    }
+    return fmt.Sprintf("%s:%d:%d: %s", displayPath(d.File), d.Line, d.Column, d.Message)
+}
+
+// yamlErrorLine matches the line number in YAML syntax errors
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// validatedFile is a parsed configuration file
+type validatedFile struct {
+    path string
+    root *yaml.Node // Top-level mapping, nil if the file is empty or invalid
+}
+
+// definition is an action or stage with the file and node that define it
+type definition struct {
+    name string
+    file string
+    node *yaml.Node
+}
+
+// validation collects the diagnostics of a configuration and its includes
+type validation struct {
+    schema      *schema
+    files       []*validatedFile // Files in the order they are merged
+    including   map[string]bool  // Files whose includes are being processed
+    actions     []definition
+    stages      []definition
+    diagnostics []Diagnostic
+}
+
+// Validate checks a project configuration and the files it includes against
+// the schema and the rules buildfab applies when it runs them: action and
+// stage references, require targets and cycles. All problems are returned
+// at once, ordered by file and position. The error is only set if the
+// configuration file cannot be read.
+func Validate(path string) ([]Diagnostic, error) {
+    s, err := loadSchema()
+    if err != nil {
+        return nil, err
+    }
+    if abs, err := filepath.Abs(path); err == nil {
+        path = abs
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, fmt.Errorf("configuration file not found: %s", path)
+        }
+        return nil, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+
+    v := &validation{schema: s, including: map[string]bool{}}
+    main := v.parse(path, data)
+    v.files = append(v.files, main)
+    if main.root != nil {
+        v.collect(main)
+        v.includes(main)
+        if schema := mappingValue(main.root, schemaKey); schema != nil {
+            if version, err := strconv.Atoi(schema.Value); err == nil && version > prepush.SchemaVersion {
+                v.report(path, schema, "configuration schema %d is newer than the supported schema %d, update pre-push", version, prepush.SchemaVersion)
+            }
+        }
+        if mappingValue(main.root, "project") == nil {
+            v.report(path, main.root, "missing required key %q", "project")
+        }
+        if len(v.actions) == 0 {
+            v.report(path, main.root, "at least one action is required")
+        }
+        v.checkActions()
+        v.checkStages()
+    }
+
+    order := map[string]int{}
+    for i, file := range v.files {
+        if _, ok := order[file.path]; !ok {
+            order[file.path] = i
+        }
+    }
+    sort.SliceStable(v.diagnostics, func(i, j int) bool {
+        a, b := v.diagnostics[i], v.diagnostics[j]
+        if a.File != b.File {
+            return order[a.File] < order[b.File]
+        }
+        if a.Line != b.Line {
+            return a.Line < b.Line
+        }
+        return a.Column < b.Column
+    })
+    return v.diagnostics, nil
+}
+
+// report adds a diagnostic at the position of a node
+func (v *validation) report(file string, node *yaml.Node, format string, args ...interface{}) {
+    v.diagnostics = append(v.diagnostics, Diagnostic{
+        File:    file,
+        Line:    node.Line,
+        Column:  node.Column,
+        Message: fmt.Sprintf(format, args...),
+    })
+}
+
+// parse parses a file and checks it against the schema
+func (v *validation) parse(path string, data []byte) *validatedFile {
+    file := &validatedFile{path: path}
+
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        diagnostic := Diagnostic{File: path, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+        if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+            diagnostic.Line, _ = strconv.Atoi(match[1])
+            diagnostic.Column = 1
+            diagnostic.Message = match[2]
+        }
+        v.diagnostics = append(v.diagnostics, diagnostic)
+        return file
+    }
+    if len(doc.Content) == 0 {
+        v.diagnostics = append(v.diagnostics, Diagnostic{File: path, Message: "file is empty"})
+        return file
+    }
+
+    validator := &schemaValidator{root: v.schema, file: path}
+    validator.validate(doc.Content[0], v.schema, "")
+    v.diagnostics = append(v.diagnostics, validator.diagnostics...)
+    if doc.Content[0].Kind == yaml.MappingNode {
+        file.root = doc.Content[0]
+    }
+    return file
+}
+
+// includes parses the files included by a file, its actions and stages are
+// merged after those of the included files, as LoadProject does
+func (v *validation) includes(file *validatedFile) {
+    node := mappingValue(file.root, "include")
+    if node == nil || node.Kind != yaml.SequenceNode {
+        return
+    }
+    v.including[file.path] = true
+    defer delete(v.including, file.path)
+
+    for _, item := range node.Content {
+        if item.Kind != yaml.ScalarNode {
+            continue
+        }
+        matches, err := resolveInclude(item.Value, filepath.Dir(file.path))
+        if err != nil {
+            v.report(file.path, item, "%v", err)
+            continue
+        }
+
+        for _, match := range matches {
+            if v.including[match] {
+                v.report(file.path, item, "circular include of %s", displayPath(match))
+                continue
+            }
+            data, err := os.ReadFile(match)
+            if err != nil {
+                v.report(file.path, item, "failed to read included file: %v", err)
+                continue
+            }
+            included := v.parse(match, data)
+            if included.root == nil {
+                v.files = append(v.files, included)
+                continue
+            }
+            for i := 0; i+1 < len(included.root.Content); i += 2 {
+                if key := included.root.Content[i]; key.Value == settingsKey || key.Value == schemaKey {
+                    v.report(match, key, "%s is only allowed in the main configuration", key.Value)
+                }
+            }
+            v.includes(included)
+            v.files = append(v.files, included)
+            v.collect(included)
+        }
+    }
+}
+
+// collect merges the actions and stages of a file, replacing those with
+// the same name from earlier files
+func (v *validation) collect(file *validatedFile) {
+    if node := mappingValue(file.root, "actions"); node != nil && node.Kind == yaml.SequenceNode {
+        defined := map[string]*yaml.Node{}
+        for _, action := range node.Content {
+            name := scalarValue(action, "name")
+            if name == "" {
+                continue
+            }
+            if first, ok := defined[name]; ok {
+                v.report(file.path, action, "duplicate action %q, first defined at line %d", name, first.Line)
+                continue
+            }
+            defined[name] = action
+            v.actions = define(v.actions, definition{name: name, file: file.path, node: action})
+        }
+    }
+
+    if node := mappingValue(file.root, "stages"); node != nil && node.Kind == yaml.MappingNode {
+        for i := 0; i+1 < len(node.Content); i += 2 {
+            stage := definition{name: node.Content[i].Value, file: file.path, node: node.Content[i+1]}
+            v.stages = define(v.stages, stage)
+        }
+    }
+}
+
+// define adds a definition or replaces the one with the same name
+func define(definitions []definition, d definition) []definition {
+    for i := range definitions {
+        if definitions[i].name == d.name {
+            definitions[i] = d
+            return definitions
+        }
+    }
+    return append(definitions, d)
+}
+
+// names returns the names of definitions
+func names(definitions []definition) []string {
+    result := make([]string, len(definitions))
+    for i, d := range definitions {
+        result[i] = d.name
+    }
+    return result
+}
+
+// checkActions checks that every action runs exactly one thing
+func (v *validation) checkActions() {
+    for _, action := range v.actions {
+        run, uses := scalarValue(action.node, "run"), scalarValue(action.node, "uses")
+        container := mappingValue(action.node, "container")
+
+        if variants := mappingValue(action.node, "variants"); variants != nil && len(variants.Content) > 0 {
+            for i, variant := range variants.Content {
+                variantRun, variantUses := scalarValue(variant, "run"), scalarValue(variant, "uses")
+                if variantRun == "" && variantUses == "" {
+                    v.report(action.file, variant, "action %q variant %d must have either 'run' or 'uses'", action.name, i)
+                }
+                if variantRun != "" && variantUses != "" {
+                    v.report(action.file, variant, "action %q variant %d cannot have both 'run' and 'uses'", action.name, i)
+                }
+            }
+            continue
+        }
+
+        switch {
+        case run == "" && uses == "" && container == nil:
+            v.report(action.file, action.node, "action %q must have either 'run', 'uses' or 'container'", action.name)
+        case run != "" && uses != "":
+            v.report(action.file, action.node, "action %q cannot have both 'run' and 'uses'", action.name)
+        case container != nil && (run != "" || uses != ""):
+            v.report(action.file, action.node, "action %q cannot have both 'container' and 'run'/'uses'", action.name)
+        }
+    }
+}
+
+// stepRef is a step of a stage with the nodes of its references
+type stepRef struct {
+    id   string
+    node *yaml.Node
+    deps []*yaml.Node // require and depends_on targets
+}
+
+// checkStages checks the steps of every stage: action and stage references,
+// unique step names, require targets and require cycles, and cycles between
+// stages that run each other
+func (v *validation) checkStages() {
+    actions, stages := names(v.actions), names(v.stages)
+    stageEdges := map[string][]edge{}
+
+    for _, stage := range v.stages {
+        steps := stageSteps(stage.node)
+        path := fmt.Sprintf("stages.%s.steps", stage.name)
+        var refs []stepRef
+        first := map[string]*yaml.Node{}
+
+        for i, node := range steps {
+            if node.Kind != yaml.MappingNode {
+                continue
+            }
+            stepPath := fmt.Sprintf("%s[%d]", path, i)
+            action, stageRef := mappingValue(node, "action"), mappingValue(node, "stage")
+
+            switch {
+            case action == nil && stageRef == nil:
+                v.report(stage.file, node, "%s: step must have either 'action' or 'stage'", stepPath)
+            case action != nil && stageRef != nil:
+                v.report(stage.file, node, "%s: step cannot have both 'action' and 'stage'", stepPath)
+            case action != nil && action.Kind == yaml.ScalarNode && !contains(actions, action.Value):
+                v.report(stage.file, action, "%s", unknownMessage(stepPath, "action", action.Value, actions))
+            case stageRef != nil && stageRef.Kind == yaml.ScalarNode:
+                if stageRef.Value == stage.name {
+                    v.report(stage.file, stageRef, "%s: stage %s cannot run itself", stepPath, stage.name)
+                } else if !contains(stages, stageRef.Value) {
+                    v.report(stage.file, stageRef, "%s", unknownMessage(stepPath, "stage", stageRef.Value, stages))
+                } else {
+                    stageEdges[stage.name] = append(stageEdges[stage.name], edge{to: stageRef.Value, file: stage.file, node: stageRef})
+                }
+            }
+            v.checkCondition(stage.file, node, stepPath)
+
+            id := scalarValue(node, "name")
+            if id == "" {
+                id = scalarValue(node, "action")
+            }
+            if id == "" {
+                id = scalarValue(node, "stage")
+            }
+            if id == "" {
+                continue
+            }
+            if previous, ok := first[id]; ok {
+                v.report(stage.file, node, "%s: duplicate step name %q, first at line %d (use 'name:' to tell steps of the same action apart)", stepPath, id, previous.Line)
+                continue
+            }
+            first[id] = node
+
+            ref := stepRef{id: id, node: node}
+            for _, key := range []string{"require", "depends_on"} {
+                if deps := mappingValue(node, key); deps != nil && deps.Kind == yaml.SequenceNode {
+                    for _, dep := range deps.Content {
+                        if dep.Kind == yaml.ScalarNode {
+                            ref.deps = append(ref.deps, dep)
+                        }
+                    }
+                }
+            }
+            refs = append(refs, ref)
+        }
+
+        ids := make([]string, len(refs))
+        for i, ref := range refs {
+            ids[i] = ref.id
+        }
+        edges := map[string][]edge{}
+        for _, ref := range refs {
+            for _, dep := range ref.deps {
+                if first[dep.Value] != nil {
+                    edges[ref.id] = append(edges[ref.id], edge{to: dep.Value, file: stage.file, node: dep})
+                    continue
+                }
+                // Matrix steps are required as <step>.<matrix value>
+                if base, _, ok := strings.Cut(dep.Value, "."); ok && first[base] != nil {
+                    continue
+                }
+                message := fmt.Sprintf("step %q requires %q, which is not a step of stage %s", ref.id, dep.Value, stage.name)
+                if suggestion := suggest(dep.Value, ids); suggestion != "" {
+                    message += fmt.Sprintf(", did you mean %q?", suggestion)
+                }
+                v.report(stage.file, dep, "%s", message)
+            }
+        }
+        v.reportCycles(ids, edges, fmt.Sprintf("require cycle in stage %s", stage.name))
+    }
+
+    v.reportCycles(stages, stageEdges, "stage cycle")
+}
+
+// edge is a reference from a step or stage to another one
+type edge struct {
+    to   string
+    file string
+    node *yaml.Node
+}
+
+// checkCondition type checks the if and only of a step
+func (v *validation) checkCondition(file string, step *yaml.Node, path string) {
+    var variables map[string]string
+    if node := mappingValue(step, "variables"); node != nil {
+        node.Decode(&variables)
+    }
+    vars := expr.StepVariables(variables)
+
+    if node := mappingValue(step, "if"); node != nil && node.Kind == yaml.ScalarNode && strings.TrimSpace(node.Value) != "" {
+        _, err := expr.Compile(node.Value, vars)
+        v.reportExpression(file, node, path+".if", err)
+    }
+    if node := mappingValue(step, "only"); node != nil && node.Kind == yaml.SequenceNode {
+        for i, item := range node.Content {
+            if item.Kind == yaml.ScalarNode {
+                _, err := expr.CompileOnly(item.Value, vars)
+                v.reportExpression(file, item, fmt.Sprintf("%s.only[%d]", path, i), err)
+            }
+        }
+    }
+}
+
+// reportExpression reports an expression error at the offending
+// sub-expression. Its column is known for expressions on a single line.
+func (v *validation) reportExpression(file string, node *yaml.Node, path string, err error) {
+    if err == nil {
+        return
+    }
+    var exprErr *expr.Error
+    if !errors.As(err, &exprErr) {
+        v.report(file, node, "%s: %v", path, err)
+        return
+    }
+
+    message := fmt.Sprintf("%s: %s", path, exprErr.Message)
+    if snippet := exprErr.Expr[exprErr.Pos:exprErr.End]; snippet != "" && snippet != exprErr.Expr {
+        message += fmt.Sprintf(" (at %s)", snippet)
+    }
+    diagnostic := Diagnostic{File: file, Line: node.Line, Column: node.Column, Message: message}
+    offset := strings.Index(node.Value, exprErr.Expr)
+    if !strings.Contains(node.Value, "\n") && offset >= 0 {
+        switch node.Style {
+        case 0:
+            diagnostic.Column += utf8.RuneCountInString(node.Value[:offset]) + exprErr.Column() - 1
+        case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+            diagnostic.Column += utf8.RuneCountInString(node.Value[:offset]) + exprErr.Column()
+        }
+    }
+    v.diagnostics = append(v.diagnostics, diagnostic)
+}
+
+// reportCycles reports every cycle of a graph once, at the reference that
+// closes it
+func (v *validation) reportCycles(nodes []string, edges map[string][]edge, message string) {
+    const (
+        unvisited = iota
+        visiting
+        done
+    )
+    state := map[string]int{}
+    reported := map[string]bool{}
+    var stack []string
+
+    var visit func(name string)
+    visit = func(name string) {
+        state[name] = visiting
+        stack = append(stack, name)
+        for _, e := range edges[name] {
+            switch state[e.to] {
+            case unvisited:
+                visit(e.to)
+            case visiting:
+                start := 0
+                for i, n := range stack {
+                    if n == e.to {
+                        start = i
+                    }
+                }
+                cycle := append(append([]string{}, stack[start:]...), e.to)
+                key := cycleKey(cycle[:len(cycle)-1])
+                if !reported[key] {
+                    reported[key] = true
+                    v.report(e.file, e.node, "%s: %s", message, strings.Join(cycle, " -> "))
+                }
+            }
+        }
+        stack = stack[:len(stack)-1]
+        state[name] = done
+    }
+
+    for _, name := range nodes {
+        if state[name] == unvisited {
+            visit(name)
+        }
+    }
+}
+
+// cycleKey identifies a cycle independent of where it starts
+func cycleKey(cycle []string) string {
+    sorted := append([]string{}, cycle...)
+    sort.Strings(sorted)
+    return strings.Join(sorted, "\x00")
+}
+
+// unknownMessage reports a reference to an undefined action or stage
+func unknownMessage(path, kind, name string, known []string) string {
+    message := fmt.Sprintf("%s: unknown %s %q", path, kind, name)
+    if suggestion := suggest(name, known); suggestion != "" {
+        message += fmt.Sprintf(", did you mean %q?", suggestion)
+    }
+    return message
+}
+
+// scalarValue returns the value of a scalar key of a mapping, or an empty
+// string
+func scalarValue(node *yaml.Node, key string) string {
+    value := mappingValue(node, key)
+    if value == nil || value.Kind != yaml.ScalarNode || value.ShortTag() == "!!null" {
+        return ""
+    }
+    return value.Value
+}
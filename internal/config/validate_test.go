@@ -0,0 +1,177 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestValidate(t *testing.T) {
+    tempDir := t.TempDir()
+
+    configContent := `project:
+  name: "test-project"
+  max_paralel: 2
+include:
+  - "actions.yml"
+  - "missing.yml"
+actions:
+  - name: build
+    run: "echo build"
+  - name: test
+    run: "echo test"
+stages:
+  pre-push:
+    steps:
+      - action: build
+        requires: [test]
+      - action: test
+        require: [biuld, lint]
+        onerror: warning
+      - action: lint
+        require: [test]
+      - action: tset
+`
+    includedContent := `actions:
+  - name: lint
+    run: "echo lint"
+    shel: bash
+`
+    configPath := filepath.Join(tempDir, ".project.yml")
+    includedPath := filepath.Join(tempDir, "actions.yml")
+    for path, content := range map[string]string{configPath: configContent, includedPath: includedContent} {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Failed to write config file: %v", err)
+        }
+    }
+
+    diagnostics, err := Validate(configPath)
+    if err != nil {
+        t.Fatalf("Failed to validate configuration: %v", err)
+    }
+
+    expected := []struct {
+        file    string
+        line    int
+        column  int
+        message string
+    }{
+        {configPath, 3, 3, `unknown key "max_paralel", did you mean "max_parallel"?`},
+        {configPath, 6, 5, "included file does not exist"},
+        {configPath, 16, 9, `unknown key "requires", did you mean "require"?`},
+        {configPath, 18, 19, `"biuld", which is not a step of stage pre-push, did you mean "build"?`},
+        {configPath, 19, 18, `invalid value "warning"`},
+        {configPath, 21, 19, "require cycle in stage pre-push: test -> lint -> test"},
+        {configPath, 22, 17, `unknown action "tset", did you mean "test"?`},
+        {includedPath, 4, 5, `unknown key "shel", did you mean "shell"?`},
+    }
+    if len(diagnostics) != len(expected) {
+        t.Fatalf("Expected %d diagnostics, got %d: %v", len(expected), len(diagnostics), diagnostics)
+    }
+    for i, want := range expected {
+        got := diagnostics[i]
+        if got.File != want.file || got.Line != want.line || got.Column != want.column || !strings.Contains(got.Message, want.message) {
+            t.Errorf("Expected %s:%d:%d: ...%s, got %s:%d:%d: %s", want.file, want.line, want.column, want.message, got.File, got.Line, got.Column, got.Message)
+        }
+    }
+}
+
+func TestValidateValid(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `project:
+  name: "test-project"
+pre-push:
+  self_update: refuse
+actions:
+  - name: build
+    run: "echo build"
+  - name: checks
+    uses: git@untracked
+stages:
+  build:
+    steps:
+      - action: build
+  pre-push:
+    steps:
+      - stage: build
+      - action: checks
+        require: [build]
+        only: [release]
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    diagnostics, err := Validate(configPath)
+    if err != nil {
+        t.Fatalf("Failed to validate configuration: %v", err)
+    }
+    if len(diagnostics) != 0 {
+        t.Errorf("Expected no diagnostics, got %v", diagnostics)
+    }
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    if err := os.WriteFile(configPath, []byte("project:\n  name: a\n  bad: [\n"), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    diagnostics, err := Validate(configPath)
+    if err != nil {
+        t.Fatalf("Failed to validate configuration: %v", err)
+    }
+    if len(diagnostics) != 1 || diagnostics[0].Line == 0 {
+        t.Errorf("Expected one syntax error with a line, got %v", diagnostics)
+    }
+}
+
+func TestValidateConditions(t *testing.T) {
+    configPath := filepath.Join(t.TempDir(), ".project.yml")
+    configContent := `project:
+  name: "test-project"
+actions:
+  - name: build
+    run: "echo build"
+  - name: test
+    run: "echo test"
+  - name: lint
+    run: "echo lint"
+stages:
+  pre-push:
+    steps:
+      - action: build
+        if: branch == "main" && version.major > "1"
+      - action: test
+        only: [release, 'version.mjaor > 1']
+      - action: lint
+        if: ${{ changed("src/**") && branch =~ "^release/" }}
+`
+    if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+        t.Fatalf("Failed to write config file: %v", err)
+    }
+
+    diagnostics, err := Validate(configPath)
+    if err != nil {
+        t.Fatalf("Failed to validate configuration: %v", err)
+    }
+
+    expected := []struct {
+        line    int
+        column  int
+        message string
+    }{
+        {14, 33, "cannot compare number with string"},
+        {16, 26, "unknown variable version.mjaor, did you mean version.major?"},
+    }
+    if len(diagnostics) != len(expected) {
+        t.Fatalf("Expected %d diagnostics, got %d: %v", len(expected), len(diagnostics), diagnostics)
+    }
+    for i, want := range expected {
+        got := diagnostics[i]
+        if got.Line != want.line || got.Column != want.column || !strings.Contains(got.Message, want.message) {
+            t.Errorf("Expected %d:%d: ...%s, got %d:%d: %s", want.line, want.column, want.message, got.Line, got.Column, got.Message)
+        }
+    }
+}
@@ -3,14 +3,20 @@ package exec
 
 import (
     "context"
+    "errors"
     "fmt"
+    "io"
     "os"
     "os/exec"
     "path/filepath"
+    "sort"
     "strings"
     "time"
 
     "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/cache"
+    "github.com/AlexBurnes/pre-push/internal/mask"
+    "github.com/AlexBurnes/pre-push/internal/vars"
     "github.com/AlexBurnes/pre-push/internal/version"
     "github.com/AlexBurnes/pre-push/pkg/prepush"
 )
@@ -19,11 +25,13 @@ import (
 type UI interface {
     PrintCLIHeader(name, version string)
     PrintProjectCheck(projectName, version string)
+    PrintConfigFile(path string)
     PrintStepStatus(stepName string, status prepush.Status, message string)
     PrintStageHeader(stageName string)
     PrintStageResult(stageName string, success bool, duration time.Duration)
     PrintCommand(command string)
     PrintCommandOutput(output string)
+    PrintOutputTail(stepName string, lines []string, omitted int, logPath string)
     PrintRepro(stepName, repro string)
     PrintReproInline(stepName, repro string)
     PrintSummary(results []prepush.Result)
@@ -60,6 +68,18 @@ type BuildfabExecutor struct {
     versionDetector *version.Detector
     cliVersion string
     gitPushInfo *GitPushInfo
+    outputOpts OutputOptions
+    results []StepResult
+    cache *cache.Cache
+    previous []StepResult
+    only []string
+    skip []string
+    chained *ChainedHook
+    hookVariables map[string]string
+    stagedOnly bool
+    stagedFiles []string
+    configPath string
+    masker *mask.Masker
 }
 
 
@@ -70,6 +90,8 @@ func NewBuildfabExecutor(config *buildfab.Config, ui UI) *BuildfabExecutor {
         ui:     ui,
         versionDetector: version.New(),
         cliVersion: "unknown",
+        outputOpts: DefaultOutputOptions(),
+        masker: mask.New(),
     }
 }
 
@@ -81,6 +103,8 @@ func BuildfabExecutorWithCLIVersion(config *buildfab.Config, ui UI, cliVersion s
         versionDetector: version.New(),
         cliVersion: cliVersion,
         gitPushInfo: nil,
+        outputOpts: DefaultOutputOptions(),
+        masker: mask.New(),
     }
 }
 
@@ -89,6 +113,40 @@ func (e *BuildfabExecutor) SetGitPushInfo(pushInfo *GitPushInfo) {
     e.gitPushInfo = pushInfo
 }
 
+// SetHookVariables sets the variables describing the Git hook input, such
+// as the staged files of pre-commit or the message file of commit-msg
+func (e *BuildfabExecutor) SetHookVariables(variables map[string]string) {
+    e.hookVariables = variables
+}
+
+// SetConfigPath sets the path of the configuration file, printed after the project check
+func (e *BuildfabExecutor) SetConfigPath(path string) {
+    e.configPath = path
+}
+
+// SetOutputOptions sets the options used to capture step output
+func (e *BuildfabExecutor) SetOutputOptions(opts OutputOptions) {
+    e.outputOpts = opts
+}
+
+// SetMasker sets the masker of secret variables, whose values are redacted
+// from debug output, step output and results. The default masker knows the
+// default secret patterns only.
+func (e *BuildfabExecutor) SetMasker(masker *mask.Masker) {
+    e.masker = masker
+}
+
+// Results returns the step results of the last executed stage, none if the
+// stage failed before its steps ran
+func (e *BuildfabExecutor) Results() []StepResult {
+    return e.results
+}
+
+// FindBuildfabBinary returns the path of the buildfab binary used for container actions
+func FindBuildfabBinary() (string, error) {
+    return findBuildfabBinary()
+}
+
 // findBuildfabBinary searches for buildfab binary in system directories
 func findBuildfabBinary() (string, error) {
     // Get HOME directory
@@ -147,18 +205,69 @@ func findBuildfabBinary() (string, error) {
     return "", fmt.Errorf("buildfab binary not found in system directories")
 }
 
-// RunStage executes a specific stage using buildfab SimpleRunner
+// RunStage executes a specific stage using buildfab SimpleRunner. In staged
+// mode the stage runs on the index and unstaged changes are restored after it.
 func (e *BuildfabExecutor) RunStage(ctx context.Context, stageName string) error {
+    e.results = nil
     _, exists := e.config.GetStage(stageName)
     if !exists {
         return fmt.Errorf("stage not found: %s", stageName)
     }
+    if !e.stagedOnly {
+        return e.runStage(ctx, stageName)
+    }
+    
+    files, err := StagedFiles(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to list staged files: %w", err)
+    }
+    e.stagedFiles = files
+    
+    stash, err := stashUnstaged(ctx)
+    if err != nil {
+        return err
+    }
+    if stash == nil {
+        return e.runStage(ctx, stageName)
+    }
+    if e.ui.IsDebug() {
+        fmt.Fprintf(os.Stderr, "DEBUG: Unstaged changes stashed in %s\n", stash.patch)
+    }
+    
+    // Restore even when the stage was interrupted and ctx is cancelled
+    err = e.runStage(ctx, stageName)
+    if restoreErr := stash.restore(context.WithoutCancel(ctx)); restoreErr != nil {
+        if err != nil {
+            return fmt.Errorf("%w; %v", err, restoreErr)
+        }
+        return restoreErr
+    }
+    return err
+}
+
+// runStage executes a stage on the working tree
+func (e *BuildfabExecutor) runStage(ctx context.Context, stageName string) error {
+    // Apply the step filter
+    config, err := e.stageConfig(stageName)
+    if err != nil {
+        return err
+    }
+    return e.runConfig(ctx, chainConfig(config, stageName, e.chained), stageName)
+}
+
+// runConfig executes a stage of a configuration derived from the executor's
+// configuration. Conditions, templates, the cache and output capture are
+// applied here, so stages and single actions are run the same way.
+func (e *BuildfabExecutor) runConfig(ctx context.Context, config *buildfab.Config, stageName string) error {
 
     // Print CLI header and project check first
     projectVersion := e.getVersion()
     cliVersion := e.getCLIVersion()
     e.ui.PrintCLIHeader("pre-push", cliVersion)
     e.ui.PrintProjectCheck(e.config.Project.Name, projectVersion)
+    if e.configPath != "" {
+        e.ui.PrintConfigFile(e.configPath)
+    }
     
     // Debug output
     if e.ui.IsDebug() {
@@ -200,14 +309,28 @@ func (e *BuildfabExecutor) RunStage(ctx context.Context, stageName string) error
         fmt.Fprintf(os.Stderr, "  BuildfabBinaryPath: %s\n", opts.BuildfabBinaryPath)
     }
     
-    // Pass variables to buildfab for interpolation
+    // Pass variables to buildfab for interpolation, and learn the secret values
     variables := e.GetAllVariables()
     opts.Variables = variables
+    e.masker.Collect(variables)
+    
+    // Evaluate step conditions
+    config, err = e.conditionConfig(ctx, config, variables)
+    if err != nil {
+        return err
+    }
     
     // Debug: Log variables
     if e.ui.IsDebug() {
         fmt.Fprintf(os.Stderr, "DEBUG: Variables passed to buildfab:\n")
-        for k, v := range variables {
+        names := make([]string, 0, len(variables))
+        for k := range variables {
+            names = append(names, k)
+        }
+        sort.Strings(names)
+        for _, k := range names {
+            // Mask before truncating, a truncated secret would not match
+            v := e.masker.Value(k, variables[k])
             if len(v) > 50 {
                 fmt.Fprintf(os.Stderr, "  %s = %s... (truncated)\n", k, v[:50])
             } else {
@@ -218,11 +341,11 @@ func (e *BuildfabExecutor) RunStage(ctx context.Context, stageName string) error
     
     // Debug: Log stage configuration
     if e.ui.IsDebug() {
-        stage, _ := e.config.GetStage(stageName)
+        stage, _ := config.GetStage(stageName)
         fmt.Fprintf(os.Stderr, "DEBUG: Stage '%s' has %d steps\n", stageName, len(stage.Steps))
         for i, step := range stage.Steps {
             fmt.Fprintf(os.Stderr, "DEBUG: Step %d: action=%s\n", i+1, step.Action)
-            if action, exists := e.config.GetAction(step.Action); exists {
+            if action, exists := config.GetAction(step.Action); exists {
                 fmt.Fprintf(os.Stderr, "DEBUG:   Action type: ")
                 if action.Run != "" {
                     fmt.Fprintf(os.Stderr, "run command\n")
@@ -237,16 +360,84 @@ func (e *BuildfabExecutor) RunStage(ctx context.Context, stageName string) error
         }
     }
     
+    // Capture step output. Below verbose level 2 buildfab output is replaced by
+    // our own rendering, so step output is held back and only shown for failed
+    // steps. At level 2 and above buildfab streams all output as before.
+    render := e.ui.GetVerboseLevel() < 2
+    stage, _ := config.GetStage(stageName)
+    order := make([]string, len(stage.Steps))
+    for i := range stage.Steps {
+        order[i] = stage.Steps[i].GetStepName()
+    }
+    outputOpts := e.outputOpts
+    outputOpts.Mask = e.masker
+    recorder := newStepRecorder(e.ui, outputOpts, render, order)
+    opts.StepCallback = recorder
+
+    // Steps reused from a previous run or with a cached successful result
+    // are replaced by no-op actions
+    replaced := make(map[string]bool)
+    recorder.reused = e.reusedResults(stage, order)
+    for name := range recorder.reused {
+        replaced[name] = true
+    }
+    plan := e.planCache(ctx, stage, variables, replaced)
+    for name := range plan.hits {
+        recorder.cached[name] = true
+        replaced[name] = true
+    }
+    config = noopConfig(config, stageName, replaced)
+
+    // Render run commands with filters, buildfab only substitutes variables
+    config, err = renderConfig(config, stageName, variables)
+    if err != nil {
+        return err
+    }
+    if render {
+        opts.VerboseLevel = 1
+        opts.Output = io.Discard
+        opts.ErrorOutput = io.Discard
+    } else {
+        // Streamed output is redacted line by line
+        stdout, stderr := e.masker.Writer(os.Stdout), e.masker.Writer(os.Stderr)
+        defer stdout.Close()
+        defer stderr.Close()
+        opts.Output = stdout
+        opts.ErrorOutput = stderr
+    }
+    
     // Create simple runner
-    runner := buildfab.NewSimpleRunner(e.config, opts)
+    runner := buildfab.NewSimpleRunner(config, opts)
     
     // Debug: Log before execution
     if e.ui.IsDebug() {
         fmt.Fprintf(os.Stderr, "DEBUG: Starting stage execution via SimpleRunner\n")
     }
     
-    // Execute the stage - buildfab handles all output automatically
+    if render {
+        e.ui.PrintStageHeader(stageName)
+    }
+    
+    // Execute the stage
+    stageStart := time.Now()
     err = runner.RunStage(ctx, stageName)
+    if err != nil {
+        err = errors.New(e.masker.String(err.Error()))
+    }
+    e.results = recorder.Results()
+    e.storeCache(plan, e.results)
+    
+    if render {
+        recorder.flush()
+        success := err == nil
+        for _, result := range e.results {
+            if result.Status == prepush.StatusError {
+                success = false
+            }
+        }
+        e.ui.PrintSummary(recorder.Summary())
+        e.ui.PrintStageResult(stageName, success, time.Since(stageStart).Round(time.Millisecond))
+    }
     
     // Debug: Log after execution
     if e.ui.IsDebug() {
@@ -260,30 +451,30 @@ func (e *BuildfabExecutor) RunStage(ctx context.Context, stageName string) error
     return err
 }
 
-// RunAction executes a specific action using buildfab SimpleRunner
+// RunAction executes a single action as a stage of one step, so its
+// command is rendered and its output captured like the steps of a stage
 func (e *BuildfabExecutor) RunAction(ctx context.Context, actionName string) error {
-    // Create simple run options
-    opts := buildfab.DefaultSimpleRunOptions()
-    opts.VerboseLevel = e.ui.GetVerboseLevel()  // Use UI verbose level directly
-    opts.Debug = e.ui.IsDebug()
-    opts.WorkingDir = "."
-    
-    // Pass variables to buildfab for interpolation
-    variables := e.GetAllVariables()
-    opts.Variables = variables
-    
-    // Create simple runner (handles all output internally)
-    runner := buildfab.NewSimpleRunner(e.config, opts)
-
-    // Execute using buildfab SimpleRunner
-    err := runner.RunAction(ctx, actionName)
-    if err != nil {
-        e.ui.PrintStepStatus(actionName, prepush.StatusError, err.Error())
-        return err
+    e.results = nil
+    if _, exists := e.config.GetAction(actionName); !exists {
+        return fmt.Errorf("action not found: %s", actionName)
     }
-    
-    e.ui.PrintStepStatus(actionName, prepush.StatusOK, "executed successfully")
-    return nil
+    stageName := actionStagePrefix + actionName
+    return e.runConfig(ctx, actionConfig(e.config, stageName, actionName), stageName)
+}
+
+// actionStagePrefix names the stage a single action is run as
+const actionStagePrefix = "action "
+
+// actionConfig returns a copy of the configuration with a stage that runs
+// only the named action
+func actionConfig(config *buildfab.Config, stageName, actionName string) *buildfab.Config {
+    action := *config
+    action.Stages = make(map[string]buildfab.Stage, len(config.Stages)+1)
+    for name, s := range config.Stages {
+        action.Stages[name] = s
+    }
+    action.Stages[stageName] = buildfab.Stage{Steps: []buildfab.Step{{Action: actionName}}}
+    return &action
 }
 
 // ListActions returns all available actions
@@ -322,159 +513,25 @@ func (e *BuildfabExecutor) getCLIVersion() string {
     return e.cliVersion
 }
 
-// GetAllVariables combines all available variables (Git, version, platform, environment)
+// GetAllVariables returns all available variables (platform, version, Git,
+// push, hook input and environment)
 func (e *BuildfabExecutor) GetAllVariables() map[string]string {
-    variables := make(map[string]string)
-    
-    // Add buildfab platform variables with simple names
-    platformVars := buildfab.GetPlatformVariables()
-    if platformVars != nil {
-        variables["platform"] = platformVars.Platform
-        variables["arch"] = platformVars.Arch
-        variables["os"] = platformVars.OS
-        variables["os_version"] = platformVars.OSVersion
-        variables["cpu"] = fmt.Sprintf("%d", platformVars.CPU)
-    }
-    
-    // Add version library variables
-    if versionInfo, err := version.GetVersionInfo(context.Background()); err == nil && versionInfo != nil {
-        // Standard version variables
-        if versionInfo.Version != "" {
-            variables["version"] = versionInfo.Version
-            variables["version.version"] = versionInfo.Version
-        }
-        if versionInfo.Project != "" {
-            variables["project"] = versionInfo.Project
-            variables["version.project"] = versionInfo.Project
-        }
-        if versionInfo.Module != "" {
-            variables["module"] = versionInfo.Module
-            variables["version.module"] = versionInfo.Module
-        }
-        if len(versionInfo.Modules) > 0 {
-            modulesStr := strings.Join(versionInfo.Modules, ",")
-            variables["modules"] = modulesStr
-            variables["version.modules"] = modulesStr
-        }
-        // New build-type and version-type variables
-        if versionInfo.BuildType != "" {
-            variables["version.build-type"] = versionInfo.BuildType
-        }
-        if versionInfo.VersionType != "" {
-            variables["version.version-type"] = versionInfo.VersionType
-        }
-    }
-    
-    // Add enhanced Git variables from push information
-    if e.gitPushInfo != nil {
-        // Current push information
-        if len(e.gitPushInfo.Tags) > 0 {
-            // Single tag (most common case)
-            if len(e.gitPushInfo.Tags) == 1 {
-                variables["tag"] = e.gitPushInfo.Tags[0]
-            }
-            // Multiple tags as comma-separated list
-            variables["tags"] = strings.Join(e.gitPushInfo.Tags, ",")
-        }
-        
-        if len(e.gitPushInfo.Branches) > 0 {
-            // Single branch (most common case)
-            if len(e.gitPushInfo.Branches) == 1 {
-                variables["branch"] = e.gitPushInfo.Branches[0]
-            }
-            // Multiple branches as comma-separated list
-            variables["branches"] = strings.Join(e.gitPushInfo.Branches, ",")
-        }
-        
-        // Version-specific variables for current branch and tag
-        if currentBranch, err := e.versionDetector.DetectCurrentBranch(context.Background()); err == nil && currentBranch != "" {
-            variables["version.branch"] = currentBranch
-        }
-        if currentTag, err := e.versionDetector.DetectCurrentVersion(context.Background()); err == nil && currentTag != "" {
-            variables["version.tag"] = currentTag
-        }
-    } else {
-        // Fallback to legacy Git detection if no push info available
-        if tag, err := e.versionDetector.DetectCurrentVersion(context.Background()); err == nil && tag != "" {
-            variables["tag"] = tag
-        }
-        if branch, err := e.versionDetector.DetectCurrentBranch(context.Background()); err == nil && branch != "" {
-            variables["branch"] = branch
-        }
-    }
-    
-    // Add environment variables
-    for _, env := range os.Environ() {
-        parts := strings.SplitN(env, "=", 2)
-        if len(parts) == 2 {
-            variables["env."+parts[0]] = parts[1]
-        }
-    }
-    
-    // Add buildfab platform variables using the helper function (these will have platform. prefix)
-    variables = buildfab.AddPlatformVariables(variables)
-    
-    // Add buildfab version variables (including version.rawversion, version.major, version.minor, version.patch, etc.)
-    variables = buildfab.AddVersionVariables(variables)
-    
-    return variables
+    return vars.Map(e.Variables(context.Background()))
 }
 
-// detectGitVariables detects Git-related variables
-func (e *BuildfabExecutor) detectGitVariables(ctx context.Context) (map[string]string, error) {
-    variables := make(map[string]string)
-    
-    // Detect current tag
-    if tag, err := e.detectGitTag(ctx); err == nil {
-        variables["tag"] = tag
-    }
-    
-    // Detect current branch
-    if branch, err := e.detectGitBranch(ctx); err == nil {
-        variables["branch"] = branch
-    }
-    
-    // Detect version library variables with simple names
-    if versionInfo, err := version.GetVersionInfo(ctx); err == nil {
-        if versionInfo.Version != "" {
-            variables["version"] = versionInfo.Version
-            variables["version.version"] = versionInfo.Version  // Keep both for compatibility
-        }
-        if versionInfo.Project != "" {
-            variables["project"] = versionInfo.Project
-            variables["version.project"] = versionInfo.Project  // Keep both for compatibility
-        }
-        if versionInfo.Module != "" {
-            variables["module"] = versionInfo.Module
-            variables["version.module"] = versionInfo.Module  // Keep both for compatibility
-        }
-        if len(versionInfo.Modules) > 0 {
-            variables["modules"] = strings.Join(versionInfo.Modules, " ")
-            variables["version.modules"] = strings.Join(versionInfo.Modules, " ")  // Keep both for compatibility
-        }
+// Variables returns all available variables with their sources
+func (e *BuildfabExecutor) Variables(ctx context.Context) []vars.Variable {
+    provider := &vars.Provider{Hook: make(map[string]string, len(e.hookVariables)+1), Mask: e.masker}
+    if e.gitPushInfo != nil {
+        provider.Push = &vars.Push{Tags: e.gitPushInfo.Tags, Branches: e.gitPushInfo.Branches}
     }
-    
-    return variables, nil
-}
-
-// detectGitTag detects the current Git tag
-func (e *BuildfabExecutor) detectGitTag(ctx context.Context) (string, error) {
-    cmd := exec.CommandContext(ctx, "git", "describe", "--tags", "--abbrev=0")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
+    for name, value := range e.hookVariables {
+        provider.Hook[name] = value
     }
-    return strings.TrimSpace(string(output)), nil
-}
-
-// detectGitBranch detects the current Git branch
-func (e *BuildfabExecutor) detectGitBranch(ctx context.Context) (string, error) {
-    cmd := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
-    output, err := cmd.Output()
-    if err != nil {
-        return "", err
+    if e.stagedOnly {
+        provider.Hook["staged_files"] = strings.Join(e.stagedFiles, " ")
     }
-    return strings.TrimSpace(string(output)), nil
+    return provider.Collect(ctx)
 }
 
 // readVersionFile reads the version from the VERSION file
@@ -20,6 +20,7 @@ func (m *mockUI) Println(args ...interface{}) {}
 func (m *mockUI) PrintStepStatus(stepName string, status prepush.Status, message string) {}
 func (m *mockUI) PrintCLIHeader(name, version string) {}
 func (m *mockUI) PrintProjectCheck(projectName, version string) {}
+func (m *mockUI) PrintConfigFile(path string) {}
 func (m *mockUI) PrintStageHeader(stageName string) {}
 func (m *mockUI) PrintStageResult(stageName string, success bool, duration time.Duration) {}
 func (m *mockUI) PrintError(err error) {}
@@ -29,6 +30,7 @@ func (m *mockUI) PrintDebug(message string) {}
 func (m *mockUI) PrintVerbose(message string) {}
 func (m *mockUI) PrintCommand(command string) {}
 func (m *mockUI) PrintCommandOutput(output string) {}
+func (m *mockUI) PrintOutputTail(stepName string, lines []string, omitted int, logPath string) {}
 func (m *mockUI) PrintRepro(stepName, repro string) {}
 func (m *mockUI) PrintReproInline(stepName, repro string) {}
 func (m *mockUI) PrintSummary(results []prepush.Result) {}
@@ -0,0 +1,236 @@
+package exec
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/cache"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// noopActionSuffix marks the no-op actions that replace cached and reused steps
+const noopActionSuffix = "#noop"
+
+// variableRefPattern matches variable references like ${{ name }}
+var variableRefPattern = regexp.MustCompile(`\$\{\{\s*([A-Za-z0-9_.\-]+)`)
+
+// cachePlan contains the cache keys and hits of the steps of a stage
+type cachePlan struct {
+    keys map[string]string       // Step name to cache key for cacheable steps
+    hits map[string]*cache.Entry // Step name to cached entry for steps that can be skipped
+}
+
+// SetCache sets the step result cache; nil disables caching
+func (e *BuildfabExecutor) SetCache(c *cache.Cache) {
+    e.cache = c
+}
+
+// planCache computes cache keys for the steps of a stage and looks them up.
+// Only plain run actions are cached; matrix steps, stage references, builtin
+// and container actions always run. Skipped steps are not considered.
+func (e *BuildfabExecutor) planCache(ctx context.Context, stage buildfab.Stage, variables map[string]string, skipped map[string]bool) *cachePlan {
+    plan := &cachePlan{
+        keys: make(map[string]string),
+        hits: make(map[string]*cache.Entry),
+    }
+    if e.cache == nil {
+        return plan
+    }
+
+    for _, step := range stage.Steps {
+        if step.Stage != "" || step.Matrix != nil || skipped[step.GetStepName()] {
+            continue
+        }
+        action, exists := e.config.GetAction(step.Action)
+        if !exists || !isCacheable(action) {
+            continue
+        }
+
+        key, err := e.stepCacheKey(ctx, step, action, variables)
+        if err != nil {
+            if e.ui.IsDebug() {
+                fmt.Fprintf(os.Stderr, "DEBUG: Step %s is not cached: %v\n", step.GetStepName(), err)
+            }
+            continue
+        }
+
+        name := step.GetStepName()
+        plan.keys[name] = key
+        if entry, hit := e.cache.Get(key); hit {
+            plan.hits[name] = entry
+        }
+    }
+
+    return plan
+}
+
+// stepCacheKey computes the cache key of a step. The key covers the tree the
+// step depends on, the action definition, the variables it references and
+// the versions of the tools it declares.
+func (e *BuildfabExecutor) stepCacheKey(ctx context.Context, step buildfab.Step, action buildfab.Action, variables map[string]string) (string, error) {
+    paths := optionStrings(action.Options, "paths")
+    tree, clean, err := cache.TreeHash(ctx, paths)
+    if err != nil {
+        return "", err
+    }
+    if !clean {
+        return "", fmt.Errorf("uncommitted changes")
+    }
+
+    parts := []string{
+        "tree", tree,
+        "cli", e.cliVersion,
+        "step", step.GetStepName(), step.If, strings.Join(step.Only, ","),
+        "action", action.Name, action.Shell, action.Run,
+    }
+    texts := []string{step.If, action.Run}
+    for _, variant := range action.Variants {
+        parts = append(parts, "variant", variant.When, variant.Shell, variant.Run, variant.Uses)
+        texts = append(texts, variant.When, variant.Run)
+    }
+
+    // Step variables override global ones
+    stepVariables := make(map[string]string, len(variables)+len(step.Variables))
+    for name, value := range variables {
+        stepVariables[name] = value
+    }
+    for name, value := range step.Variables {
+        stepVariables[name] = value
+        texts = append(texts, value)
+    }
+    for _, name := range referencedVariables(texts...) {
+        parts = append(parts, "var", name, stepVariables[name])
+    }
+
+    for _, tool := range optionStrings(action.Options, "tools") {
+        parts = append(parts, "tool", tool, toolVersion(ctx, tool))
+    }
+
+    return cache.Key(parts...), nil
+}
+
+// storeCache records successful steps of a run in the cache
+func (e *BuildfabExecutor) storeCache(plan *cachePlan, results []StepResult) {
+    if e.cache == nil {
+        return
+    }
+
+    for _, result := range results {
+        key, cacheable := plan.keys[result.Name]
+        if !cacheable || plan.hits[result.Name] != nil || result.Status != prepush.StatusOK {
+            continue
+        }
+
+        entry := cache.Entry{
+            Key:       key,
+            Step:      result.Name,
+            Timestamp: time.Now(),
+            Duration:  result.Duration,
+        }
+        if err := e.cache.Put(entry); err != nil && e.ui.IsDebug() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Could not cache result of %s: %v\n", result.Name, err)
+        }
+    }
+}
+
+// noopConfig returns a copy of the configuration in which the named steps of
+// a stage run a no-op action under their original step name. It replaces
+// steps whose result is taken from the cache or from a previous run.
+func noopConfig(config *buildfab.Config, stageName string, names map[string]bool) *buildfab.Config {
+    if len(names) == 0 {
+        return config
+    }
+
+    stage, exists := config.Stages[stageName]
+    if !exists {
+        return config
+    }
+
+    replaced := *config
+    replaced.Actions = append([]buildfab.Action(nil), config.Actions...)
+    replaced.Stages = make(map[string]buildfab.Stage, len(config.Stages))
+    for name, s := range config.Stages {
+        replaced.Stages[name] = s
+    }
+
+    steps := make([]buildfab.Step, len(stage.Steps))
+    noops := make(map[string]bool)
+    for i, step := range stage.Steps {
+        steps[i] = step
+        if !names[step.GetStepName()] {
+            continue
+        }
+
+        noop := step.GetStepName() + noopActionSuffix
+        steps[i].Name = step.GetStepName()
+        steps[i].Action = noop
+        steps[i].Stage = ""
+        steps[i].Matrix = nil
+        if !noops[noop] {
+            noops[noop] = true
+            replaced.Actions = append(replaced.Actions, buildfab.Action{Name: noop, Run: "exit 0"})
+        }
+    }
+    replaced.Stages[stageName] = buildfab.Stage{Steps: steps}
+
+    return &replaced
+}
+
+// isCacheable checks if an action may be cached
+func isCacheable(action buildfab.Action) bool {
+    if action.Run == "" || action.Uses != "" || action.Container != nil {
+        return false
+    }
+    if enabled, exists := action.Options["cache"].(bool); exists && !enabled {
+        return false
+    }
+    return true
+}
+
+// optionStrings returns an action option as a list of strings
+func optionStrings(options map[string]interface{}, name string) []string {
+    switch value := options[name].(type) {
+    case string:
+        return []string{value}
+    case []interface{}:
+        var values []string
+        for _, item := range value {
+            values = append(values, fmt.Sprintf("%v", item))
+        }
+        return values
+    default:
+        return nil
+    }
+}
+
+// referencedVariables returns the sorted names of variables referenced in the texts
+func referencedVariables(texts ...string) []string {
+    seen := make(map[string]bool)
+    var names []string
+    for _, text := range texts {
+        for _, match := range variableRefPattern.FindAllStringSubmatch(text, -1) {
+            if !seen[match[1]] {
+                seen[match[1]] = true
+                names = append(names, match[1])
+            }
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+// toolVersion returns the version output of a tool, or the error if it cannot be run
+func toolVersion(ctx context.Context, tool string) string {
+    output, err := exec.CommandContext(ctx, tool, "--version").CombinedOutput()
+    if err != nil {
+        return "error: " + err.Error()
+    }
+    return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,68 @@
+package exec
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// TestNoopConfig tests that cached steps are replaced by no-op actions
+func TestNoopConfig(t *testing.T) {
+    config := &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "build", Run: "make"},
+            {Name: "test", Run: "make test"},
+        },
+        Stages: map[string]buildfab.Stage{
+            "pre-push": {Steps: []buildfab.Step{
+                {Action: "build"},
+                {Action: "test", Require: []string{"build"}},
+            }},
+        },
+    }
+
+    cached := noopConfig(config, "pre-push", map[string]bool{"build": true})
+
+    steps := cached.Stages["pre-push"].Steps
+    if steps[0].GetStepName() != "build" || steps[0].Action != "build"+noopActionSuffix {
+        t.Errorf("Expected cached step to keep its name and run a no-op, got %+v", steps[0])
+    }
+    if steps[1].Action != "test" {
+        t.Errorf("Expected uncached step to be unchanged, got %+v", steps[1])
+    }
+    if _, exists := cached.GetAction("build" + noopActionSuffix); !exists {
+        t.Error("Expected no-op action to be added")
+    }
+    if config.Stages["pre-push"].Steps[0].Action != "build" {
+        t.Error("Expected original config to be unchanged")
+    }
+}
+
+// TestIsCacheable tests the per-action cache opt-out
+func TestIsCacheable(t *testing.T) {
+    tests := []struct {
+        action   buildfab.Action
+        expected bool
+    }{
+        {buildfab.Action{Name: "a", Run: "make"}, true},
+        {buildfab.Action{Name: "b", Run: "make", Options: map[string]interface{}{"cache": false}}, false},
+        {buildfab.Action{Name: "c", Run: "make", Options: map[string]interface{}{"cache": true}}, true},
+        {buildfab.Action{Name: "d", Uses: "git@untracked"}, false},
+    }
+
+    for _, test := range tests {
+        if result := isCacheable(test.action); result != test.expected {
+            t.Errorf("isCacheable(%s) = %v, expected %v", test.action.Name, result, test.expected)
+        }
+    }
+}
+
+// TestReferencedVariables tests variable reference extraction for cache keys
+func TestReferencedVariables(t *testing.T) {
+    names := referencedVariables("echo ${{ tag }} ${{branch}}", "${{ tag }} ${{ env.HOME }}")
+    expected := []string{"branch", "env.HOME", "tag"}
+    if !reflect.DeepEqual(names, expected) {
+        t.Errorf("Expected %v, got %v", expected, names)
+    }
+}
@@ -0,0 +1,85 @@
+package exec
+
+import (
+    "strings"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// ChainedHook is an existing Git hook that runs as a step of the stage, so
+// its result is reported like any other step
+type ChainedHook struct {
+    Name  string   // Step name reported in the summary
+    Path  string   // Path of the hook executable
+    Args  []string // Arguments Git passed to the hook
+    Stdin string   // Path of a file with the input Git passed to the hook
+    After bool     // Run after all other steps instead of before them
+}
+
+// SetChainedHook sets a hook that RunStage runs together with the steps of
+// the stage; nil disables chaining
+func (e *BuildfabExecutor) SetChainedHook(hook *ChainedHook) {
+    e.chained = hook
+}
+
+// chainConfig returns a copy of the configuration with the chained hook added
+// as a step of the stage. Run before, all steps without requirements require
+// the hook; run after, the hook requires all steps of the stage.
+func chainConfig(config *buildfab.Config, stageName string, hook *ChainedHook) *buildfab.Config {
+    if hook == nil {
+        return config
+    }
+
+    stage, exists := config.Stages[stageName]
+    if !exists {
+        return config
+    }
+
+    chained := *config
+    chained.Actions = append(append([]buildfab.Action(nil), config.Actions...), buildfab.Action{
+        Name: hook.Name,
+        Run:  hookCommand(hook),
+    })
+    chained.Stages = make(map[string]buildfab.Stage, len(config.Stages))
+    for name, s := range config.Stages {
+        chained.Stages[name] = s
+    }
+
+    hookStep := buildfab.Step{Name: hook.Name, Action: hook.Name}
+    var steps []buildfab.Step
+    if !hook.After {
+        steps = append(steps, hookStep)
+    }
+    for _, step := range stage.Steps {
+        if hook.After {
+            hookStep.Require = append(hookStep.Require, step.GetStepName())
+        } else if len(stepRequires(step)) == 0 {
+            step.Require = []string{hook.Name}
+        }
+        steps = append(steps, step)
+    }
+    if hook.After {
+        steps = append(steps, hookStep)
+    }
+    chained.Stages[stageName] = buildfab.Stage{Steps: steps}
+
+    return &chained
+}
+
+// hookCommand returns the shell command that runs a chained hook
+func hookCommand(hook *ChainedHook) string {
+    words := []string{shellQuote(hook.Path)}
+    for _, arg := range hook.Args {
+        words = append(words, shellQuote(arg))
+    }
+    command := strings.Join(words, " ")
+    if hook.Stdin != "" {
+        command += " <" + shellQuote(hook.Stdin)
+    }
+    return command
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,84 @@
+package exec
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// chainTestConfig returns a configuration with a build step and a test step requiring it
+func chainTestConfig() *buildfab.Config {
+    return &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "build", Run: "make"},
+            {Name: "test", Run: "make test"},
+        },
+        Stages: map[string]buildfab.Stage{
+            "pre-push": {Steps: []buildfab.Step{
+                {Action: "build"},
+                {Action: "test", Require: []string{"build"}},
+            }},
+        },
+    }
+}
+
+// TestChainConfigBefore tests that a chained hook runs before the steps of the stage
+func TestChainConfigBefore(t *testing.T) {
+    config := chainTestConfig()
+    hook := &ChainedHook{Name: "pre-push.local", Path: "/hooks/pre-push.local", Args: []string{"origin", "url"}, Stdin: "/tmp/input"}
+
+    chained := chainConfig(config, "pre-push", hook)
+
+    steps := chained.Stages["pre-push"].Steps
+    if len(steps) != 3 || steps[0].GetStepName() != "pre-push.local" {
+        t.Fatalf("Expected hook step first, got %+v", steps)
+    }
+    if !reflect.DeepEqual(steps[1].Require, []string{"pre-push.local"}) {
+        t.Errorf("Expected step without requirements to require the hook, got %v", steps[1].Require)
+    }
+    if !reflect.DeepEqual(steps[2].Require, []string{"build"}) {
+        t.Errorf("Expected step requirements to be unchanged, got %v", steps[2].Require)
+    }
+
+    action, exists := chained.GetAction("pre-push.local")
+    if !exists {
+        t.Fatal("Expected hook action to be added")
+    }
+    expected := `'/hooks/pre-push.local' 'origin' 'url' <'/tmp/input'`
+    if action.Run != expected {
+        t.Errorf("Expected run %q, got %q", expected, action.Run)
+    }
+    if len(config.Stages["pre-push"].Steps) != 2 || config.Stages["pre-push"].Steps[0].Require != nil {
+        t.Error("Expected original config to be unchanged")
+    }
+}
+
+// TestChainConfigAfter tests that a chained hook runs after the steps of the stage
+func TestChainConfigAfter(t *testing.T) {
+    hook := &ChainedHook{Name: "pre-push.local", Path: "/hooks/pre-push.local", After: true}
+
+    chained := chainConfig(chainTestConfig(), "pre-push", hook)
+
+    steps := chained.Stages["pre-push"].Steps
+    if len(steps) != 3 || steps[2].GetStepName() != "pre-push.local" {
+        t.Fatalf("Expected hook step last, got %+v", steps)
+    }
+    if !reflect.DeepEqual(steps[2].Require, []string{"build", "test"}) {
+        t.Errorf("Expected hook to require all steps, got %v", steps[2].Require)
+    }
+    if steps[0].Require != nil {
+        t.Errorf("Expected steps to be unchanged, got %v", steps[0].Require)
+    }
+}
+
+// TestHookVariables tests that hook variables are available for interpolation
+func TestHookVariables(t *testing.T) {
+    executor := NewBuildfabExecutor(chainTestConfig(), &mockUI{})
+    executor.SetHookVariables(map[string]string{"hook": "pre-commit", "staged_files": "a.go b.go"})
+
+    variables := executor.GetAllVariables()
+    if variables["hook"] != "pre-commit" || variables["staged_files"] != "a.go b.go" {
+        t.Errorf("Expected hook variables, got hook=%q staged_files=%q", variables["hook"], variables["staged_files"])
+    }
+}
@@ -0,0 +1,143 @@
+package exec
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/expr"
+)
+
+// conditionConfig returns the configuration with the if and only of every
+// step evaluated by pre-push. Conditions become the constants true or false,
+// which buildfab reports as skipped (condition not met). Conditions that use
+// matrix variables are left to buildfab, which evaluates if per matrix job.
+func (e *BuildfabExecutor) conditionConfig(ctx context.Context, config *buildfab.Config, variables map[string]string) (*buildfab.Config, error) {
+    var files []string
+    var filesErr error
+    loaded := false
+    changed := func() ([]string, error) {
+        if !loaded {
+            files, filesErr = e.changedFiles(ctx)
+            loaded = true
+        }
+        return files, filesErr
+    }
+
+    result := *config
+    result.Stages = make(map[string]buildfab.Stage, len(config.Stages))
+    for name, stage := range config.Stages {
+        stage.Steps = append([]buildfab.Step(nil), stage.Steps...)
+        for i := range stage.Steps {
+            step := &stage.Steps[i]
+            if step.If == "" && len(step.Only) == 0 {
+                continue
+            }
+            condition, err := expr.CompileCondition(step.If, step.Only, expr.StepVariables(step.Variables))
+            if err != nil {
+                return nil, fmt.Errorf("stage %s, step %s: %w", name, step.GetStepName(), err)
+            }
+            if usesMatrix(condition) {
+                continue
+            }
+
+            values := make(map[string]string, len(variables)+len(step.Variables)+1)
+            values["ci"] = strconv.FormatBool(os.Getenv("CI") != "")
+            for k, v := range variables {
+                values[k] = v
+            }
+            for k, v := range step.Variables {
+                values[k] = v
+            }
+            ok, err := condition.Eval(&expr.Env{Values: values, Files: changed})
+            if err != nil {
+                return nil, fmt.Errorf("stage %s, step %s: %w", name, step.GetStepName(), err)
+            }
+            if e.ui.IsDebug() {
+                fmt.Fprintf(os.Stderr, "DEBUG: Condition of step %s in stage %s: %v\n", step.GetStepName(), name, ok)
+            }
+            step.If = strconv.FormatBool(ok)
+            step.Only = nil
+        }
+        result.Stages[name] = stage
+    }
+    return &result, nil
+}
+
+// usesMatrix reports whether a condition uses matrix variables
+func usesMatrix(condition *expr.Condition) bool {
+    for _, name := range condition.Variables() {
+        if strings.HasPrefix(name, "matrix.") {
+            return true
+        }
+    }
+    return false
+}
+
+// changedFiles returns the files changed() matches against: the staged
+// files of pre-commit or a staged run, the changed files of post-merge, the
+// files of the pushed commits, or else the files of the commits not on the
+// upstream branch and the uncommitted changes
+func (e *BuildfabExecutor) changedFiles(ctx context.Context) ([]string, error) {
+    if e.stagedOnly {
+        return e.stagedFiles, nil
+    }
+    for _, name := range []string{"staged_files", "changed_files"} {
+        if files, ok := e.hookVariables[name]; ok {
+            return strings.Fields(files), nil
+        }
+    }
+
+    set := map[string]bool{}
+    add := func(output []byte) {
+        for _, file := range strings.Split(string(output), "\n") {
+            if file = strings.TrimSpace(file); file != "" {
+                set[file] = true
+            }
+        }
+    }
+
+    if e.gitPushInfo != nil && len(e.gitPushInfo.Refs) > 0 {
+        remotes := "--remotes"
+        if e.gitPushInfo.RemoteName != "" {
+            remotes = "--remotes=" + e.gitPushInfo.RemoteName
+        }
+        for _, ref := range e.gitPushInfo.Refs {
+            if ref.IsDelete {
+                continue
+            }
+            if strings.Trim(ref.RemoteSHA, "0") != "" {
+                if output, err := runGit(ctx, nil, "diff", "--name-only", ref.RemoteSHA, ref.LocalSHA); err == nil {
+                    add(output)
+                    continue
+                }
+            }
+            // A new ref, or a remote commit missing locally: the commits not on the remote
+            output, err := runGit(ctx, nil, "log", "--name-only", "--format=", ref.LocalSHA, "--not", remotes)
+            if err != nil {
+                return nil, err
+            }
+            add(output)
+        }
+    } else {
+        if output, err := runGit(ctx, nil, "diff", "--name-only", "@{upstream}...HEAD"); err == nil {
+            add(output)
+        }
+        output, err := runGit(ctx, nil, "diff", "--name-only", "HEAD")
+        if err != nil {
+            return nil, err
+        }
+        add(output)
+    }
+
+    files := make([]string, 0, len(set))
+    for file := range set {
+        files = append(files, file)
+    }
+    sort.Strings(files)
+    return files, nil
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+    "context"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// TestConditionConfig tests that pre-push evaluates the conditions of steps
+func TestConditionConfig(t *testing.T) {
+    config := &buildfab.Config{
+        Stages: map[string]buildfab.Stage{
+            "pre-push": {
+                Steps: []buildfab.Step{
+                    {Action: "build", If: `version.major > 1 && branch =~ "^release/"`},
+                    {Action: "test", Only: []string{"release"}},
+                    {Action: "docs", If: `changed("docs/**")`},
+                    {Action: "lint", If: `matrix.os == "linux"`},
+                    {Action: "vet"},
+                    {Action: "deploy", If: `!ci && inputs.mode == ""`},
+                },
+            },
+        },
+    }
+
+    t.Setenv("CI", "1")
+    executor := NewBuildfabExecutor(config, &mockUI{})
+    executor.stagedOnly = true
+    executor.stagedFiles = []string{"docs/guide.md"}
+
+    variables := map[string]string{"version.major": "2", "branch": "release/2.0", "version.version-type": "patch"}
+    result, err := executor.conditionConfig(context.Background(), config, variables)
+    if err != nil {
+        t.Fatalf("Failed to evaluate conditions: %v", err)
+    }
+
+    expected := []string{"true", "false", "true", `matrix.os == "linux"`, "", "false"}
+    for i, step := range result.Stages["pre-push"].Steps {
+        if step.If != expected[i] || len(step.Only) != 0 {
+            t.Errorf("Expected step %s to have if %q, got %q, only %v", step.Action, expected[i], step.If, step.Only)
+        }
+    }
+    if config.Stages["pre-push"].Steps[0].If == "true" {
+        t.Error("Expected the original configuration to be unchanged")
+    }
+}
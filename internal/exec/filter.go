@@ -0,0 +1,106 @@
+package exec
+
+import (
+    "fmt"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// SetStepFilter restricts the steps executed by RunStage. Steps in only run
+// together with the steps they require; steps in skip are left out together
+// with the steps that require them.
+func (e *BuildfabExecutor) SetStepFilter(only, skip []string) {
+    e.only = only
+    e.skip = skip
+}
+
+// ValidateStepFilter checks that the step filter matches the steps of a stage
+func (e *BuildfabExecutor) ValidateStepFilter(stageName string) error {
+    _, err := e.stageConfig(stageName)
+    return err
+}
+
+// stageConfig returns the configuration used to run a stage with the step filter applied
+func (e *BuildfabExecutor) stageConfig(stageName string) (*buildfab.Config, error) {
+    if len(e.only) == 0 && len(e.skip) == 0 {
+        return e.config, nil
+    }
+
+    stage, exists := e.config.GetStage(stageName)
+    if !exists {
+        return nil, fmt.Errorf("stage not found: %s", stageName)
+    }
+
+    filtered, err := filterStage(stage, e.only, e.skip)
+    if err != nil {
+        return nil, fmt.Errorf("stage %s: %w", stageName, err)
+    }
+
+    config := *e.config
+    config.Stages = make(map[string]buildfab.Stage, len(e.config.Stages))
+    for name, s := range e.config.Stages {
+        config.Stages[name] = s
+    }
+    config.Stages[stageName] = filtered
+
+    return &config, nil
+}
+
+// filterStage returns a stage that contains only the selected steps
+func filterStage(stage buildfab.Stage, only, skip []string) (buildfab.Stage, error) {
+    steps := make(map[string]buildfab.Step, len(stage.Steps))
+    for _, step := range stage.Steps {
+        steps[step.GetStepName()] = step
+    }
+    for _, name := range append(append([]string(nil), only...), skip...) {
+        if _, exists := steps[name]; !exists {
+            return buildfab.Stage{}, fmt.Errorf("step not found: %s", name)
+        }
+    }
+
+    // Selected steps pull in everything they require
+    selected := make(map[string]bool)
+    if len(only) == 0 {
+        for name := range steps {
+            selected[name] = true
+        }
+    }
+    var queue []string
+    queue = append(queue, only...)
+    for len(queue) > 0 {
+        name := queue[0]
+        queue = queue[1:]
+        if selected[name] {
+            continue
+        }
+        selected[name] = true
+        queue = append(queue, stepRequires(steps[name])...)
+    }
+
+    // Skipped steps take the steps that require them along
+    skipped := make(map[string]bool)
+    for _, name := range skip {
+        skipped[name] = true
+    }
+    for name := range dependentSteps(stage, skipped) {
+        skipped[name] = true
+    }
+
+    var filtered buildfab.Stage
+    for _, step := range stage.Steps {
+        name := step.GetStepName()
+        if selected[name] && !skipped[name] {
+            filtered.Steps = append(filtered.Steps, step)
+        }
+    }
+    if len(filtered.Steps) == 0 {
+        return buildfab.Stage{}, fmt.Errorf("no steps left to run")
+    }
+
+    return filtered, nil
+}
+
+// stepRequires returns the names of the steps a step requires
+func stepRequires(step buildfab.Step) []string {
+    return append(append([]string(nil), step.Require...), step.DependsOn...)
+}
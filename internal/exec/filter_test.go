@@ -0,0 +1,63 @@
+package exec
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// filterTestStage returns a stage with a chain of required steps
+func filterTestStage() buildfab.Stage {
+    return buildfab.Stage{Steps: []buildfab.Step{
+        {Action: "lint"},
+        {Action: "build"},
+        {Action: "test", Require: []string{"build"}},
+        {Action: "package", Require: []string{"test"}},
+    }}
+}
+
+// stepNames returns the declared step names of a stage
+func stepNames(stage buildfab.Stage) []string {
+    var names []string
+    for _, step := range stage.Steps {
+        names = append(names, step.GetStepName())
+    }
+    return names
+}
+
+// TestFilterStageOnly tests that selected steps include the steps they require
+func TestFilterStageOnly(t *testing.T) {
+    filtered, err := filterStage(filterTestStage(), []string{"test"}, nil)
+    if err != nil {
+        t.Fatalf("Failed to filter stage: %v", err)
+    }
+
+    expected := []string{"build", "test"}
+    if names := stepNames(filtered); !reflect.DeepEqual(names, expected) {
+        t.Errorf("Expected steps %v, got %v", expected, names)
+    }
+}
+
+// TestFilterStageSkip tests that skipped steps take their dependents along
+func TestFilterStageSkip(t *testing.T) {
+    filtered, err := filterStage(filterTestStage(), nil, []string{"test"})
+    if err != nil {
+        t.Fatalf("Failed to filter stage: %v", err)
+    }
+
+    expected := []string{"lint", "build"}
+    if names := stepNames(filtered); !reflect.DeepEqual(names, expected) {
+        t.Errorf("Expected steps %v, got %v", expected, names)
+    }
+}
+
+// TestFilterStageUnknownStep tests that unknown step names are rejected
+func TestFilterStageUnknownStep(t *testing.T) {
+    if _, err := filterStage(filterTestStage(), []string{"deploy"}, nil); err == nil {
+        t.Error("Expected error for unknown step")
+    }
+    if _, err := filterStage(filterTestStage(), []string{"build"}, []string{"build"}); err == nil {
+        t.Error("Expected error when no steps are left")
+    }
+}
@@ -0,0 +1,227 @@
+package exec
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync"
+
+    "github.com/AlexBurnes/pre-push/internal/mask"
+)
+
+// OutputOptions configures how step output is captured
+type OutputOptions struct {
+    TailLines   int          // Number of trailing lines shown when a step fails
+    MemoryLimit int64        // Bytes kept in memory before output is spilled to a temp file
+    LogDir      string       // Directory for spilled and persisted logs (default: os.TempDir())
+    Mask        *mask.Masker // Redacts secret values from captured lines (nil: no redaction)
+}
+
+// DefaultOutputOptions returns default output capture options
+func DefaultOutputOptions() OutputOptions {
+    return OutputOptions{
+        TailLines:   20,
+        MemoryLimit: 1 << 20,
+        LogDir:      "",
+    }
+}
+
+// StepOutput captures the output of a single step. Output is kept in memory
+// until it grows past the memory limit and is spilled to a temp file after that.
+// The last lines are always kept in memory so they can be shown on failure.
+type StepOutput struct {
+    mu      sync.Mutex
+    name    string
+    opts    OutputOptions
+    buf     bytes.Buffer
+    file    *os.File
+    size    int64
+    lines   int
+    tail    []string
+    tailPos int
+}
+
+// NewStepOutput creates a new output capture for the named step
+func NewStepOutput(name string, opts OutputOptions) *StepOutput {
+    return &StepOutput{
+        name: name,
+        opts: opts,
+    }
+}
+
+// WriteLine appends a single line of output
+func (o *StepOutput) WriteLine(line string) error {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    line = o.opts.Mask.String(strings.TrimRight(line, "\r\n"))
+    o.lines++
+    o.addTail(line)
+
+    data := line + "\n"
+    o.size += int64(len(data))
+
+    if o.file != nil {
+        _, err := o.file.WriteString(data)
+        return err
+    }
+
+    o.buf.WriteString(data)
+
+    // Spill to a temp file once the memory limit is exceeded
+    if o.opts.MemoryLimit > 0 && int64(o.buf.Len()) > o.opts.MemoryLimit {
+        return o.spill()
+    }
+
+    return nil
+}
+
+// addTail records a line in the tail ring buffer
+func (o *StepOutput) addTail(line string) {
+    if o.opts.TailLines <= 0 {
+        return
+    }
+    if len(o.tail) < o.opts.TailLines {
+        o.tail = append(o.tail, line)
+        return
+    }
+    o.tail[o.tailPos] = line
+    o.tailPos = (o.tailPos + 1) % o.opts.TailLines
+}
+
+// spill moves buffered output to a temp file
+func (o *StepOutput) spill() error {
+    file, err := os.CreateTemp(o.opts.LogDir, "pre-push-"+sanitizeLogName(o.name)+"-*.log")
+    if err != nil {
+        return fmt.Errorf("failed to create log file for %s: %w", o.name, err)
+    }
+
+    if _, err := file.Write(o.buf.Bytes()); err != nil {
+        file.Close()
+        os.Remove(file.Name())
+        return fmt.Errorf("failed to write log file for %s: %w", o.name, err)
+    }
+
+    o.file = file
+    o.buf.Reset()
+    return nil
+}
+
+// Tail returns the last captured lines and the number of earlier lines omitted
+func (o *StepOutput) Tail() ([]string, int) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    lines := make([]string, 0, len(o.tail))
+    lines = append(lines, o.tail[o.tailPos:]...)
+    lines = append(lines, o.tail[:o.tailPos]...)
+
+    return lines, o.lines - len(lines)
+}
+
+// Lines returns the total number of captured lines
+func (o *StepOutput) Lines() int {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    return o.lines
+}
+
+// Size returns the total number of captured bytes
+func (o *StepOutput) Size() int64 {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    return o.size
+}
+
+// Persist makes sure the full output is stored on disk and returns the log path
+func (o *StepOutput) Persist() (string, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    if o.file == nil {
+        if err := o.spill(); err != nil {
+            return "", err
+        }
+    }
+
+    if err := o.file.Sync(); err != nil {
+        return "", fmt.Errorf("failed to sync log file for %s: %w", o.name, err)
+    }
+
+    return o.file.Name(), nil
+}
+
+// copyLog copies the log of a step into the log directory and returns the
+// path of the copy
+func copyLog(logPath string, name string, opts OutputOptions) (string, error) {
+    src, err := os.Open(logPath)
+    if err != nil {
+        return "", err
+    }
+    defer src.Close()
+
+    dst, err := os.CreateTemp(opts.LogDir, "pre-push-"+sanitizeLogName(name)+"-*.log")
+    if err != nil {
+        return "", fmt.Errorf("failed to create log file for %s: %w", name, err)
+    }
+    defer dst.Close()
+
+    if _, err := io.Copy(dst, src); err != nil {
+        os.Remove(dst.Name())
+        return "", fmt.Errorf("failed to copy log of %s: %w", name, err)
+    }
+    return dst.Name(), nil
+}
+
+// WriteTo writes the full captured output to w
+func (o *StepOutput) WriteTo(w io.Writer) (int64, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    if o.file == nil {
+        n, err := w.Write(o.buf.Bytes())
+        return int64(n), err
+    }
+
+    file, err := os.Open(o.file.Name())
+    if err != nil {
+        return 0, err
+    }
+    defer file.Close()
+
+    return io.Copy(w, file)
+}
+
+// Discard releases the captured output and removes any temp file
+func (o *StepOutput) Discard() {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    if o.file != nil {
+        o.file.Close()
+        os.Remove(o.file.Name())
+        o.file = nil
+    }
+    o.buf.Reset()
+    o.tail = nil
+    o.tailPos = 0
+}
+
+// sanitizeLogName converts a step name into a string usable in file names
+func sanitizeLogName(name string) string {
+    var b strings.Builder
+    for _, r := range name {
+        switch {
+        case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+            b.WriteRune(r)
+        default:
+            b.WriteRune('_')
+        }
+    }
+    if b.Len() == 0 {
+        return "step"
+    }
+    return b.String()
+}
@@ -0,0 +1,181 @@
+package exec
+
+import (
+    "bytes"
+    "fmt"
+    "context"
+    "os"
+    "strings"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/mask"
+)
+
+// TestStepOutputTail tests that only the last lines are kept for display
+func TestStepOutputTail(t *testing.T) {
+    out := NewStepOutput("test", OutputOptions{TailLines: 3, MemoryLimit: 1 << 20})
+    defer out.Discard()
+
+    for i := 1; i <= 5; i++ {
+        if err := out.WriteLine(fmt.Sprintf("line %d", i)); err != nil {
+            t.Fatalf("Failed to write line: %v", err)
+        }
+    }
+
+    lines, omitted := out.Tail()
+    if omitted != 2 {
+        t.Errorf("Expected 2 omitted lines, got %d", omitted)
+    }
+    expected := []string{"line 3", "line 4", "line 5"}
+    if len(lines) != len(expected) {
+        t.Fatalf("Expected %d tail lines, got %d", len(expected), len(lines))
+    }
+    for i := range expected {
+        if lines[i] != expected[i] {
+            t.Errorf("Expected tail line %d to be '%s', got '%s'", i, expected[i], lines[i])
+        }
+    }
+}
+
+// TestStepOutputMask tests that secret values are redacted from captured output
+func TestStepOutputMask(t *testing.T) {
+    masker := mask.New()
+    masker.Collect(map[string]string{"env.NPM_TOKEN": "npm_0123456789"})
+    out := NewStepOutput("publish", OutputOptions{TailLines: 5, MemoryLimit: 1 << 20, LogDir: t.TempDir(), Mask: masker})
+    defer out.Discard()
+
+    if err := out.WriteLine("//registry/:_authToken=npm_0123456789\n"); err != nil {
+        t.Fatalf("Failed to write line: %v", err)
+    }
+    lines, _ := out.Tail()
+    if len(lines) != 1 || lines[0] != "//registry/:_authToken=***" {
+        t.Errorf("Expected redacted tail, got %q", lines)
+    }
+
+    logPath, err := out.Persist()
+    if err != nil {
+        t.Fatalf("Failed to persist output: %v", err)
+    }
+    content, err := os.ReadFile(logPath)
+    if err != nil {
+        t.Fatalf("Failed to read log file: %v", err)
+    }
+    if strings.Contains(string(content), "npm_0123456789") {
+        t.Errorf("Expected persisted log to be redacted, got %q", content)
+    }
+}
+
+// TestStepOutputSpill tests that output above the memory limit is spilled to a file
+func TestStepOutputSpill(t *testing.T) {
+    tempDir := t.TempDir()
+    out := NewStepOutput("spill/step", OutputOptions{TailLines: 2, MemoryLimit: 16, LogDir: tempDir})
+
+    for i := 1; i <= 10; i++ {
+        if err := out.WriteLine(fmt.Sprintf("line %d", i)); err != nil {
+            t.Fatalf("Failed to write line: %v", err)
+        }
+    }
+
+    if out.file == nil {
+        t.Fatal("Expected output to be spilled to a file")
+    }
+
+    logPath, err := out.Persist()
+    if err != nil {
+        t.Fatalf("Failed to persist output: %v", err)
+    }
+
+    content, err := os.ReadFile(logPath)
+    if err != nil {
+        t.Fatalf("Failed to read log file: %v", err)
+    }
+
+    var expected bytes.Buffer
+    for i := 1; i <= 10; i++ {
+        fmt.Fprintf(&expected, "line %d\n", i)
+    }
+    if string(content) != expected.String() {
+        t.Errorf("Expected log content %q, got %q", expected.String(), string(content))
+    }
+
+    out.Discard()
+    if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+        t.Errorf("Expected log file to be removed after discard")
+    }
+}
+
+// TestStepOutputPersist tests that in-memory output is written to disk on demand
+func TestStepOutputPersist(t *testing.T) {
+    out := NewStepOutput("persist", OutputOptions{TailLines: 5, MemoryLimit: 1 << 20, LogDir: t.TempDir()})
+    defer out.Discard()
+
+    out.WriteLine("hello")
+    out.WriteLine("world")
+
+    logPath, err := out.Persist()
+    if err != nil {
+        t.Fatalf("Failed to persist output: %v", err)
+    }
+
+    content, err := os.ReadFile(logPath)
+    if err != nil {
+        t.Fatalf("Failed to read log file: %v", err)
+    }
+    if string(content) != "hello\nworld\n" {
+        t.Errorf("Expected persisted content, got %q", string(content))
+    }
+
+    var buf bytes.Buffer
+    if _, err := out.WriteTo(&buf); err != nil {
+        t.Fatalf("Failed to write output: %v", err)
+    }
+    if buf.String() != "hello\nworld\n" {
+        t.Errorf("Expected WriteTo content, got %q", buf.String())
+    }
+}
+
+// TestRunStageCapture tests that the output of each step is captured
+// through the step callback and run scripts are passed on unchanged
+func TestRunStageCapture(t *testing.T) {
+    // buildfab stops reading output when the command exits, the pause
+    // keeps the test independent of that race
+    script := "echo \"checking $STEP\"\nsleep 0.1\nexit 1"
+    config := &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "check", Run: script},
+        },
+        Stages: map[string]buildfab.Stage{
+            "pre-push": {Steps: []buildfab.Step{
+                {Name: "first", Action: "check", Variables: map[string]string{"STEP": "one"}, OnError: "warn"},
+                {Name: "second", Action: "check", Variables: map[string]string{"STEP": "two"}, OnError: "warn"},
+            }},
+        },
+    }
+
+    executor := NewBuildfabExecutor(config, &mockUI{})
+    executor.SetOutputOptions(OutputOptions{TailLines: 5, LogDir: t.TempDir()})
+    executor.RunStage(context.Background(), "pre-push")
+
+    results := executor.Results()
+    if len(results) != 2 {
+        t.Fatalf("Expected 2 results, got %+v", results)
+    }
+    for i, want := range []string{"checking one", "checking two"} {
+        result := results[i]
+        if result.Output == nil {
+            t.Errorf("Expected captured output for %s", result.Name)
+            continue
+        }
+        if lines, _ := result.Output.Tail(); len(lines) != 1 || lines[0] != want {
+            t.Errorf("Expected output [%s] for %s, got %v", want, result.Name, lines)
+        }
+        if result.LogPath == "" {
+            t.Errorf("Expected a log file for %s", result.Name)
+        }
+        result.Output.Discard()
+    }
+    if action, _ := config.GetAction("check"); action.Run != script {
+        t.Errorf("Expected run script to be unchanged, got %q", action.Run)
+    }
+}
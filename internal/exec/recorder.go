@@ -0,0 +1,308 @@
+package exec
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sync"
+    "time"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// StepResult contains the outcome of a single step together with its captured output
+type StepResult struct {
+    Name     string
+    Status   prepush.Status
+    Message  string
+    Duration time.Duration
+    LogPath  string
+    Output   *StepOutput
+}
+
+// stepRecorder implements buildfab.StepCallback. It captures per-step output
+// and collects results, and optionally renders step progress through the UI.
+// Rendered results follow the declaration order of the stage steps.
+type stepRecorder struct {
+    mu      sync.Mutex
+    ui      UI
+    opts    OutputOptions
+    render  bool
+    outputs map[string]*StepOutput
+    results []StepResult
+
+    order   []string             // Declared step names in stage order
+    next    int                  // Index of the next declared step to render
+    running map[string]bool      // Steps that started and did not complete yet
+    pending map[int][]StepResult // Completed results waiting for earlier steps
+
+    cached map[string]bool         // Steps replaced by their cached result
+    reused map[string][]StepResult // Steps replaced by their results from a previous run
+}
+
+// newStepRecorder creates a new step recorder; order lists the declared step names
+func newStepRecorder(ui UI, opts OutputOptions, render bool, order []string) *stepRecorder {
+    return &stepRecorder{
+        ui:      ui,
+        opts:    opts,
+        render:  render,
+        outputs: make(map[string]*StepOutput),
+        order:   order,
+        running: make(map[string]bool),
+        pending: make(map[int][]StepResult),
+        cached:  make(map[string]bool),
+        reused:  make(map[string][]StepResult),
+    }
+}
+
+// groupOf returns the index of the declared step a reported step belongs to
+func (r *stepRecorder) groupOf(stepName string) int {
+    return stepGroup(r.order, stepName)
+}
+
+// groupRunning checks if any step of a declared step group is still running
+func (r *stepRecorder) groupRunning(group int) bool {
+    for name := range r.running {
+        if r.groupOf(name) == group {
+            return true
+        }
+    }
+    return false
+}
+
+// advance renders completed results whose preceding steps are all rendered
+func (r *stepRecorder) advance() {
+    for r.next < len(r.order) {
+        results, exists := r.pending[r.next]
+        if !exists || r.groupRunning(r.next) {
+            return
+        }
+        for _, result := range results {
+            r.print(result)
+        }
+        delete(r.pending, r.next)
+        r.next++
+    }
+}
+
+// flush renders all results that are still waiting
+func (r *stepRecorder) flush() {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for ; r.next < len(r.order); r.next++ {
+        for _, result := range r.pending[r.next] {
+            r.print(result)
+        }
+        delete(r.pending, r.next)
+    }
+}
+
+// print renders a single step result
+func (r *stepRecorder) print(result StepResult) {
+    r.ui.PrintStepStatus(result.Name, result.Status, result.Message)
+    if result.Status != prepush.StatusError && result.Status != prepush.StatusWarn {
+        return
+    }
+    if result.Output != nil {
+        lines, omitted := result.Output.Tail()
+        r.ui.PrintOutputTail(result.Name, lines, omitted, result.LogPath)
+    } else if result.LogPath != "" {
+        r.ui.PrintOutputTail(result.Name, nil, 0, result.LogPath)
+    }
+}
+
+// output returns the output capture for a step, creating it if needed
+func (r *stepRecorder) output(stepName string) *StepOutput {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    out, exists := r.outputs[stepName]
+    if !exists {
+        out = NewStepOutput(stepName, r.opts)
+        r.outputs[stepName] = out
+    }
+    return out
+}
+
+// OnStepStart is called when a step starts execution
+func (r *stepRecorder) OnStepStart(ctx context.Context, stepName string) {
+    r.output(stepName)
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    r.running[stepName] = true
+    if r.render && r.ui.GetVerboseLevel() > 0 {
+        r.ui.PrintStepStatus(stepName, prepush.StatusRunning, "running")
+    }
+}
+
+// OnStepComplete is called when a step completes (success, warning, or error)
+func (r *stepRecorder) OnStepComplete(ctx context.Context, stepName string, status buildfab.StepStatus, message string, duration time.Duration, bufferedOutput string) {
+    out := r.output(stepName)
+    result := StepResult{
+        Name:     stepName,
+        Status:   convertStepStatus(status),
+        Message:  r.opts.Mask.String(message),
+        Duration: duration,
+        Output:   out,
+    }
+    if r.cached[stepName] && result.Status == prepush.StatusOK {
+        result.Message = "cached"
+    }
+
+    failed := result.Status == prepush.StatusError || result.Status == prepush.StatusWarn
+    if failed && out.Lines() > 0 {
+        logPath, err := out.Persist()
+        if err != nil {
+            if r.ui.IsDebug() {
+                fmt.Fprintf(os.Stderr, "DEBUG: Could not persist output of %s: %v\n", stepName, err)
+            }
+        } else {
+            result.LogPath = logPath
+        }
+    } else if !failed {
+        // Output of successful steps is not needed anymore
+        out.Discard()
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    delete(r.running, stepName)
+
+    // Steps reused from a previous run report their previous results
+    if previous, exists := r.reused[stepName]; exists {
+        out.Discard()
+        for _, result := range previous {
+            r.record(result)
+        }
+        return
+    }
+    r.record(result)
+}
+
+// record stores a step result and renders it once all preceding steps are rendered
+func (r *stepRecorder) record(result StepResult) {
+    stepName := result.Name
+
+    // Replace a previously reported result for the same step
+    found := false
+    for i := range r.results {
+        if r.results[i].Name == stepName {
+            r.results[i] = result
+            found = true
+            break
+        }
+    }
+    if !found {
+        r.results = append(r.results, result)
+    }
+
+    if !r.render {
+        return
+    }
+
+    // Steps outside the declared order, or whose turn has passed, render immediately
+    group := r.groupOf(stepName)
+    if group < r.next {
+        r.print(result)
+        return
+    }
+    r.pending[group] = append(r.pending[group], result)
+    r.advance()
+}
+
+// OnStepOutput is called for every line of step output
+func (r *stepRecorder) OnStepOutput(ctx context.Context, stepName string, output string) {
+    out := r.output(stepName)
+    if err := out.WriteLine(output); err != nil && r.ui.IsDebug() {
+        fmt.Fprintf(os.Stderr, "DEBUG: Could not capture output of %s: %v\n", stepName, err)
+    }
+}
+
+// OnStepError is called for step errors
+func (r *stepRecorder) OnStepError(ctx context.Context, stepName string, err error) {
+    // Errors are reported through OnStepComplete
+}
+
+// GetResults returns the collected step results in buildfab form
+func (r *stepRecorder) GetResults() []buildfab.StepResult {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    results := make([]buildfab.StepResult, len(r.results))
+    for i, result := range r.results {
+        results[i] = buildfab.StepResult{
+            StepName: result.Name,
+            Status:   convertStatus(result.Status),
+            Duration: result.Duration,
+            Message:  result.Message,
+        }
+    }
+    return results
+}
+
+// Results returns the collected step results
+func (r *stepRecorder) Results() []StepResult {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    results := make([]StepResult, len(r.results))
+    copy(results, r.results)
+    return results
+}
+
+// Summary returns the collected step results in the form used by UI.PrintSummary
+func (r *stepRecorder) Summary() []prepush.Result {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    results := make([]prepush.Result, len(r.results))
+    for i, result := range r.results {
+        results[i] = prepush.Result{
+            Name:    result.Name,
+            Status:  result.Status,
+            Message: result.Message,
+        }
+    }
+    return results
+}
+
+// convertStepStatus converts a buildfab step status to a pre-push status
+func convertStepStatus(status buildfab.StepStatus) prepush.Status {
+    switch status {
+    case buildfab.StepStatusPending:
+        return prepush.StatusPending
+    case buildfab.StepStatusRunning:
+        return prepush.StatusRunning
+    case buildfab.StepStatusOK:
+        return prepush.StatusOK
+    case buildfab.StepStatusWarn:
+        return prepush.StatusWarn
+    case buildfab.StepStatusError:
+        return prepush.StatusError
+    default:
+        return prepush.StatusSkipped
+    }
+}
+
+// convertStatus converts a pre-push status to a buildfab step status
+func convertStatus(status prepush.Status) buildfab.StepStatus {
+    switch status {
+    case prepush.StatusPending:
+        return buildfab.StepStatusPending
+    case prepush.StatusRunning:
+        return buildfab.StepStatusRunning
+    case prepush.StatusOK:
+        return buildfab.StepStatusOK
+    case prepush.StatusWarn:
+        return buildfab.StepStatusWarn
+    case prepush.StatusError:
+        return buildfab.StepStatusError
+    default:
+        return buildfab.StepStatusSkipped
+    }
+}
@@ -0,0 +1,118 @@
+package exec
+
+import (
+    "fmt"
+    "regexp"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/template"
+)
+
+// renderActionSuffix marks the copies of actions whose run commands were
+// rendered for a step
+const renderActionSuffix = "#render"
+
+// buildfabRefPattern matches the references buildfab interpolates itself
+var buildfabRefPattern = regexp.MustCompile(`\$\{\{\s*([^}]+?)\s*\}\}`)
+
+// renderConfig returns a copy of the configuration in which the run
+// commands of the steps of a stage are rendered by pre-push, with filters
+// and defaults. Step variables override global ones, so every step whose
+// command changes gets its own copy of the action under its original step
+// name. Matrix values are only known per job: a matrix reference is left
+// to buildfab and takes no filters. Steps that reference a stage are left
+// to buildfab as well.
+//
+// buildfab interpolates the rendered command again, so a command that still
+// contains a ${{ }} reference after rendering, from $${{ or from a value,
+// is an error instead of being rendered twice.
+func renderConfig(config *buildfab.Config, stageName string, variables map[string]string) (*buildfab.Config, error) {
+    stage, exists := config.Stages[stageName]
+    if !exists {
+        return config, nil
+    }
+
+    rendered := *config
+    rendered.Actions = append([]buildfab.Action(nil), config.Actions...)
+    rendered.Stages = make(map[string]buildfab.Stage, len(config.Stages))
+    for name, s := range config.Stages {
+        rendered.Stages[name] = s
+    }
+
+    steps := make([]buildfab.Step, len(stage.Steps))
+    for i, step := range stage.Steps {
+        steps[i] = step
+        if step.Action == "" {
+            continue
+        }
+        action, exists := config.GetAction(step.Action)
+        if !exists {
+            continue
+        }
+
+        name := step.GetStepName()
+        values := make(map[string]string, len(variables)+len(step.Variables))
+        for k, v := range variables {
+            values[k] = v
+        }
+        for k, v := range step.Variables {
+            values[k] = v
+        }
+        matrix := make(map[string]bool)
+        if step.Matrix != nil {
+            for key := range step.Matrix.Values {
+                matrix["matrix."+key] = true
+                values["matrix."+key] = "${{ matrix." + key + " }}"
+            }
+        }
+        render := func(field, s string) (string, error) {
+            result, err := template.Render(s, values, template.Strict)
+            if err != nil {
+                return "", fmt.Errorf("stage %s, step %s: %s: %w", stageName, name, field, err)
+            }
+            for _, match := range buildfabRefPattern.FindAllStringSubmatch(result, -1) {
+                if !matrix[match[1]] {
+                    return "", fmt.Errorf("stage %s, step %s: %s: rendered command contains %s, which buildfab would render again", stageName, name, field, match[0])
+                }
+            }
+            return result, nil
+        }
+
+        changed := false
+        if action.Run != "" {
+            run, err := render("run", action.Run)
+            if err != nil {
+                return nil, err
+            }
+            changed = changed || run != action.Run
+            action.Run = run
+        }
+        if len(action.Variants) > 0 {
+            variants := make([]buildfab.ActionVariant, len(action.Variants))
+            for j, variant := range action.Variants {
+                variants[j] = variant
+                if variant.Run == "" {
+                    continue
+                }
+                run, err := render(fmt.Sprintf("variants[%d].run", j), variant.Run)
+                if err != nil {
+                    return nil, err
+                }
+                changed = changed || run != variant.Run
+                variants[j].Run = run
+            }
+            action.Variants = variants
+        }
+        if !changed {
+            continue
+        }
+
+        action.Name = name + renderActionSuffix
+        rendered.Actions = append(rendered.Actions, action)
+        steps[i].Name = name
+        steps[i].Action = action.Name
+    }
+    rendered.Stages[stageName] = buildfab.Stage{Steps: steps}
+
+    return &rendered, nil
+}
@@ -0,0 +1,159 @@
+package exec
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+)
+
+// TestRenderConfig tests that run commands are rendered per step
+func TestRenderConfig(t *testing.T) {
+    config := &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "lint", Run: "gofmt -l ${{ files | split | shellquote }}"},
+            {Name: "build", Run: "make ${{ target | default(\"all\") }}"},
+            {Name: "cross", Run: "GOOS=${{ matrix.os }} go build"},
+            {Name: "plain", Run: "make test"},
+        },
+        Stages: map[string]buildfab.Stage{
+            "pre-push": {Steps: []buildfab.Step{
+                {Action: "lint"},
+                {Action: "build"},
+                {Name: "build-docs", Action: "build", Variables: map[string]string{"target": "docs"}},
+                {Action: "cross", Matrix: &buildfab.MatrixConfig{
+                    Values: map[string][]interface{}{"os": {"linux", "darwin"}},
+                }},
+                {Action: "plain", Require: []string{"lint"}},
+            }},
+        },
+    }
+
+    rendered, err := renderConfig(config, "pre-push", map[string]string{"files": "a.go it's.go"})
+    if err != nil {
+        t.Fatalf("Failed to render config: %v", err)
+    }
+
+    if config.Actions[0].Run != "gofmt -l ${{ files | split | shellquote }}" || config.Stages["pre-push"].Steps[0].Name != "" {
+        t.Errorf("Expected original config to be unchanged, got %+v", config)
+    }
+    tests := []struct {
+        name string
+        run  string
+    }{
+        {"lint", `gofmt -l a.go 'it'\''s.go'`},
+        {"build", "make all"},
+        {"build-docs", "make docs"},
+        {"cross", "GOOS=${{ matrix.os }} go build"},
+        {"plain", "make test"},
+    }
+    steps := rendered.Stages["pre-push"].Steps
+    for i, test := range tests {
+        if steps[i].GetStepName() != test.name {
+            t.Errorf("Expected step %d to keep its name %s, got %s", i, test.name, steps[i].GetStepName())
+        }
+        action, exists := rendered.GetAction(steps[i].Action)
+        if !exists || action.Run != test.run {
+            t.Errorf("Expected step %s to run %q, got %+v", test.name, test.run, action)
+        }
+    }
+    if steps[4].Action != "plain" || steps[4].Require[0] != "lint" {
+        t.Errorf("Expected unchanged step to keep its action and requirements, got %+v", steps[4])
+    }
+}
+
+// TestRenderConfigErrors tests the commands renderConfig rejects
+func TestRenderConfigErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        run  string
+        want string
+    }{
+        {"undefined", "echo ${{ missing }}", "undefined variable: missing"},
+        {"literal", "echo $${{ files }}", "buildfab would render again"},
+        {"value", "echo ${{ message }}", "buildfab would render again"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            config := &buildfab.Config{
+                Actions: []buildfab.Action{{Name: "echo", Run: test.run}},
+                Stages: map[string]buildfab.Stage{
+                    "pre-push": {Steps: []buildfab.Step{{Action: "echo"}}},
+                },
+            }
+            variables := map[string]string{"files": "a.go", "message": "use ${{ files }}"}
+            _, err := renderConfig(config, "pre-push", variables)
+            if err == nil || !strings.Contains(err.Error(), test.want) {
+                t.Errorf("Expected error containing %q, got %v", test.want, err)
+            }
+        })
+    }
+}
+
+// TestRunStageRender tests that a stage runs the rendered commands
+func TestRunStageRender(t *testing.T) {
+    out := filepath.Join(t.TempDir(), "out")
+    config := &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "list", Run: "printf '%s\\n' ${{ staged_files | split | shellquote }} ${{ suffix | upper }} > '" + out + "'"},
+        },
+        Stages: map[string]buildfab.Stage{
+            "pre-commit": {Steps: []buildfab.Step{
+                {Action: "list", Variables: map[string]string{"suffix": "done"}},
+            }},
+        },
+    }
+
+    executor := NewBuildfabExecutor(config, &mockUI{})
+    executor.SetHookVariables(map[string]string{"staged_files": "a.go it's.go"})
+    if err := executor.RunStage(context.Background(), "pre-commit"); err != nil {
+        t.Fatalf("Failed to run stage: %v", err)
+    }
+
+    data, err := os.ReadFile(out)
+    if err != nil {
+        t.Fatalf("Failed to read output: %v", err)
+    }
+    if string(data) != "a.go\nit's.go\nDONE\n" {
+        t.Errorf("Expected rendered command output, got %q", data)
+    }
+    results := executor.Results()
+    if len(results) != 1 || results[0].Name != "list" {
+        t.Errorf("Expected a result for step list, got %+v", results)
+    }
+}
+
+// TestRunActionRender tests that a single action runs its rendered command
+func TestRunActionRender(t *testing.T) {
+    out := filepath.Join(t.TempDir(), "out")
+    config := &buildfab.Config{
+        Actions: []buildfab.Action{
+            {Name: "show", Run: "echo ${{ target | default(\"all\") | upper }} > '" + out + "'"},
+            {Name: "broken", Run: "echo ${{ missing | upper }}"},
+        },
+    }
+
+    executor := NewBuildfabExecutor(config, &mockUI{})
+    if err := executor.RunAction(context.Background(), "show"); err != nil {
+        t.Fatalf("Failed to run action: %v", err)
+    }
+    data, err := os.ReadFile(out)
+    if err != nil || string(data) != "ALL\n" {
+        t.Errorf("Expected rendered command output, got %q (%v)", data, err)
+    }
+    if results := executor.Results(); len(results) != 1 || results[0].Name != "show" {
+        t.Errorf("Expected a result for action show, got %+v", results)
+    }
+
+    err = executor.RunAction(context.Background(), "broken")
+    if err == nil || !strings.Contains(err.Error(), "undefined variable: missing") {
+        t.Errorf("Expected a render error, got %v", err)
+    }
+    if results := executor.Results(); len(results) != 0 {
+        t.Errorf("Expected no results for an action that never ran, got %+v", results)
+    }
+}
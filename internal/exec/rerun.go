@@ -0,0 +1,116 @@
+package exec
+
+import (
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// SetPreviousResults sets the results of a previous run of the stage. When set,
+// RunStage re-executes only the steps that failed in that run and the steps
+// that require them, and reports the previous results for all other steps.
+func (e *BuildfabExecutor) SetPreviousResults(results []StepResult) {
+    e.previous = results
+}
+
+// reusedResults returns the previous results of the declared steps that do not
+// need to run again, keyed by declared step name
+func (e *BuildfabExecutor) reusedResults(stage buildfab.Stage, order []string) map[string][]StepResult {
+    reused := make(map[string][]StepResult)
+    if e.previous == nil {
+        return reused
+    }
+
+    byGroup := make(map[int][]StepResult)
+    for _, result := range e.previous {
+        if group := stepGroup(order, result.Name); group >= 0 {
+            byGroup[group] = append(byGroup[group], result)
+        }
+    }
+
+    // Steps that failed or have no previous result run again
+    rerun := make(map[string]bool)
+    for i, name := range order {
+        results, exists := byGroup[i]
+        if !exists {
+            rerun[name] = true
+        }
+        for _, result := range results {
+            if result.Status == prepush.StatusError {
+                rerun[name] = true
+            }
+        }
+    }
+
+    for name := range dependentSteps(stage, rerun) {
+        rerun[name] = true
+    }
+
+    for i, name := range order {
+        if !rerun[name] {
+            reused[name] = e.copyLogs(byGroup[i])
+        }
+    }
+
+    return reused
+}
+
+// copyLogs copies the logs of reused results into the log directory, the
+// logs of the previous run go away with that run
+func (e *BuildfabExecutor) copyLogs(results []StepResult) []StepResult {
+    copied := make([]StepResult, len(results))
+    for i, result := range results {
+        copied[i] = result
+        if result.LogPath == "" {
+            continue
+        }
+        logPath, err := copyLog(result.LogPath, result.Name, e.outputOpts)
+        if err != nil && e.ui.IsDebug() {
+            fmt.Fprintf(os.Stderr, "DEBUG: Could not copy log of %s: %v\n", result.Name, err)
+        }
+        copied[i].LogPath = logPath
+    }
+    return copied
+}
+
+// dependentSteps returns the steps that directly or transitively require any of the named steps
+func dependentSteps(stage buildfab.Stage, names map[string]bool) map[string]bool {
+    dependents := make(map[string]bool)
+    changed := true
+    for changed {
+        changed = false
+        for _, step := range stage.Steps {
+            name := step.GetStepName()
+            if names[name] || dependents[name] {
+                continue
+            }
+            for _, required := range stepRequires(step) {
+                if names[required] || dependents[required] {
+                    dependents[name] = true
+                    changed = true
+                    break
+                }
+            }
+        }
+    }
+    return dependents
+}
+
+// stepGroup returns the index of the declared step a reported step belongs to.
+// Matrix and stage expansions are reported as the declared name followed by
+// a dot and the job or step name.
+func stepGroup(order []string, stepName string) int {
+    best := -1
+    for i, name := range order {
+        if name == stepName {
+            return i
+        }
+        if strings.HasPrefix(stepName, name+".") && (best == -1 || len(name) > len(order[best])) {
+            best = i
+        }
+    }
+    return best
+}
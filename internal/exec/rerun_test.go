@@ -0,0 +1,118 @@
+package exec
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/pkg/prepush"
+)
+
+// TestReusedResults tests that failed steps and their dependents run again
+func TestReusedResults(t *testing.T) {
+    stage := buildfab.Stage{Steps: []buildfab.Step{
+        {Action: "lint"},
+        {Action: "build"},
+        {Action: "test", Require: []string{"build"}},
+        {Action: "package", Require: []string{"test"}},
+        {Name: "matrix", Action: "check"},
+        {Action: "new"},
+    }}
+    order := make([]string, len(stage.Steps))
+    for i := range stage.Steps {
+        order[i] = stage.Steps[i].GetStepName()
+    }
+
+    executor := NewBuildfabExecutor(&buildfab.Config{}, &mockUI{})
+    executor.SetPreviousResults([]StepResult{
+        {Name: "lint", Status: prepush.StatusOK},
+        {Name: "build", Status: prepush.StatusError},
+        {Name: "test", Status: prepush.StatusSkipped},
+        {Name: "package", Status: prepush.StatusSkipped},
+        {Name: "matrix.a", Status: prepush.StatusOK},
+        {Name: "matrix.b", Status: prepush.StatusWarn},
+    })
+
+    reused := executor.reusedResults(stage, order)
+
+    for _, name := range []string{"build", "test", "package", "new"} {
+        if _, exists := reused[name]; exists {
+            t.Errorf("Expected step %s to run again", name)
+        }
+    }
+    if _, exists := reused["lint"]; !exists {
+        t.Error("Expected lint result to be reused")
+    }
+    if len(reused["matrix"]) != 2 {
+        t.Errorf("Expected 2 reused matrix results, got %d", len(reused["matrix"]))
+    }
+}
+
+// TestStepGroup tests that reported steps are matched to declared steps by
+// name or by name and a dot
+func TestStepGroup(t *testing.T) {
+    order := []string{"lint", "lint-docs", "build", "build.linux"}
+    tests := []struct {
+        step string
+        want int
+    }{
+        {"lint", 0},
+        {"lint-docs", 1},
+        {"lint.go", 0},
+        {"lint-docs.md", 1},
+        {"linter", -1},
+        {"build.linux", 3},
+        {"build.linux.amd64", 3},
+        {"build.darwin", 2},
+        {"test", -1},
+    }
+
+    for _, test := range tests {
+        if group := stepGroup(order, test.step); group != test.want {
+            t.Errorf("stepGroup(%q) = %d, want %d", test.step, group, test.want)
+        }
+    }
+}
+
+// TestReusedResultsLogs tests that the logs of reused results are copied into the log directory
+func TestReusedResultsLogs(t *testing.T) {
+    previousDir, logDir := t.TempDir(), t.TempDir()
+    previousLog := filepath.Join(previousDir, "lint.log")
+    if err := os.WriteFile(previousLog, []byte("warning\n"), 0644); err != nil {
+        t.Fatalf("Failed to write log: %v", err)
+    }
+
+    stage := buildfab.Stage{Steps: []buildfab.Step{{Action: "lint"}, {Action: "vet"}, {Action: "build"}}}
+    executor := NewBuildfabExecutor(&buildfab.Config{}, &mockUI{})
+    executor.SetOutputOptions(OutputOptions{LogDir: logDir})
+    executor.SetPreviousResults([]StepResult{
+        {Name: "lint", Status: prepush.StatusWarn, LogPath: previousLog},
+        {Name: "vet", Status: prepush.StatusWarn, LogPath: filepath.Join(previousDir, "missing.log")},
+        {Name: "build", Status: prepush.StatusError},
+    })
+
+    reused := executor.reusedResults(stage, []string{"lint", "vet", "build"})
+
+    logPath := reused["lint"][0].LogPath
+    if !strings.HasPrefix(logPath, logDir) {
+        t.Fatalf("Expected log to be copied into %s, got %s", logDir, logPath)
+    }
+    if content, err := os.ReadFile(logPath); err != nil || string(content) != "warning\n" {
+        t.Errorf("Expected copied log content, got %q %v", content, err)
+    }
+    if reused["vet"][0].LogPath != "" {
+        t.Errorf("Expected a missing log to be dropped, got %s", reused["vet"][0].LogPath)
+    }
+}
+
+// TestReusedResultsWithoutPrevious tests that all steps run without previous results
+func TestReusedResultsWithoutPrevious(t *testing.T) {
+    stage := buildfab.Stage{Steps: []buildfab.Step{{Action: "lint"}}}
+    executor := NewBuildfabExecutor(&buildfab.Config{}, &mockUI{})
+
+    if reused := executor.reusedResults(stage, []string{"lint"}); len(reused) != 0 {
+        t.Errorf("Expected no reused results, got %v", reused)
+    }
+}
@@ -0,0 +1,140 @@
+package exec
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+
+    "github.com/AlexBurnes/pre-push/internal/repo"
+)
+
+// unstagedPatchFile is the file in the state directory that keeps the
+// unstaged changes while a stage runs on the index. It is removed once the
+// changes are restored, so a file left behind means a run was killed or
+// its changes did not apply.
+const unstagedPatchFile = "unstaged.patch"
+
+// SetStagedOnly makes RunStage run on exactly the staged content: unstaged
+// changes of tracked files are stashed before the stage and restored after
+// it, also when the stage is interrupted. The staged_files variable lists
+// the staged files.
+func (e *BuildfabExecutor) SetStagedOnly(enabled bool) {
+    e.stagedOnly = enabled
+}
+
+// StagedFiles returns the files added, copied, modified or renamed in the index
+func StagedFiles(ctx context.Context) ([]string, error) {
+    output, err := runGit(ctx, nil, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+    if err != nil {
+        return nil, err
+    }
+    return splitNul(output), nil
+}
+
+// unstagedStash holds the unstaged changes removed from the working tree
+type unstagedStash struct {
+    patch  string // Path of the patch with the unstaged changes
+    backup string // Commit of the index and working tree created by git stash create
+}
+
+// stashUnstaged removes the unstaged changes of tracked files from the
+// working tree, so it matches the index. It returns nil if there are none.
+// The changes are written to a patch in the state directory before the
+// working tree is touched, and a stash commit is kept as a backup.
+func stashUnstaged(ctx context.Context) (*unstagedStash, error) {
+    stateDir, err := repo.StateDir(ctx)
+    if err != nil {
+        return nil, err
+    }
+    patch := filepath.Join(stateDir, unstagedPatchFile)
+    if _, err := os.Stat(patch); err == nil {
+        return nil, fmt.Errorf("unstaged changes of a previous run are kept in %s, restore them with 'git apply %s' and remove the file", patch, patch)
+    }
+
+    output, err := runGit(ctx, nil, "diff", "--name-only", "-z", "--ignore-submodules")
+    if err != nil {
+        return nil, err
+    }
+    files := splitNul(output)
+    if len(files) == 0 {
+        return nil, nil
+    }
+
+    diff, err := runGit(ctx, nil, "diff", "--binary", "--no-color", "--no-ext-diff", "--ignore-submodules", "--src-prefix=a/", "--dst-prefix=b/")
+    if err != nil {
+        return nil, err
+    }
+    backup, err := runGit(ctx, nil, "stash", "create")
+    if err != nil {
+        return nil, err
+    }
+
+    if err := os.MkdirAll(stateDir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create state directory: %w", err)
+    }
+    if err := os.WriteFile(patch, diff, 0644); err != nil {
+        return nil, fmt.Errorf("failed to save unstaged changes: %w", err)
+    }
+    stash := &unstagedStash{patch: patch, backup: strings.TrimSpace(string(backup))}
+
+    // From here on the changes are saved, an interrupt must not stop the checkout halfway
+    input := strings.Join(files, "\x00") + "\x00"
+    if _, err := runGit(context.WithoutCancel(ctx), strings.NewReader(input), "checkout-index", "--force", "-z", "--stdin"); err != nil {
+        if restoreErr := stash.restore(context.WithoutCancel(ctx)); restoreErr != nil {
+            return nil, fmt.Errorf("failed to stash unstaged changes: %w; %v", err, restoreErr)
+        }
+        return nil, fmt.Errorf("failed to stash unstaged changes: %w", err)
+    }
+
+    return stash, nil
+}
+
+// restore applies the unstaged changes to the working tree again. If the
+// patch no longer applies, for example because a step rewrote a file, the
+// backup commit is added to the stash list and the patch is kept.
+func (s *unstagedStash) restore(ctx context.Context) error {
+    if _, err := runGit(ctx, nil, "apply", "--binary", "--whitespace=nowarn", s.patch); err != nil {
+        kept := s.patch
+        if s.backup != "" {
+            if _, storeErr := runGit(ctx, nil, "stash", "store", "-m", "pre-push: unstaged changes", s.backup); storeErr == nil {
+                kept += " and in the stash list"
+            }
+        }
+        return fmt.Errorf("failed to restore unstaged changes (%v), they are kept in %s", err, kept)
+    }
+
+    if err := os.Remove(s.patch); err != nil {
+        return fmt.Errorf("failed to remove %s: %w", s.patch, err)
+    }
+    return nil
+}
+
+// runGit runs a git command with optional stdin and returns its output.
+// Errors include what git printed on stderr.
+func runGit(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+    cmd := exec.CommandContext(ctx, "git", args...)
+    cmd.Stdin = stdin
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    output, err := cmd.Output()
+    if err != nil {
+        return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+    }
+    return output, nil
+}
+
+// splitNul splits NUL terminated git output into its entries
+func splitNul(output []byte) []string {
+    var entries []string
+    for _, entry := range strings.Split(string(output), "\x00") {
+        if entry != "" {
+            entries = append(entries, entry)
+        }
+    }
+    return entries
+}
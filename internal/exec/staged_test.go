@@ -0,0 +1,114 @@
+package exec
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "reflect"
+    "testing"
+)
+
+// initStagedRepo creates a repository with a committed file, a staged change
+// and an unstaged change on top of it, and changes into it
+func initStagedRepo(t *testing.T) {
+    tempDir := t.TempDir()
+    oldDir, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get current dir: %v", err)
+    }
+    t.Cleanup(func() { os.Chdir(oldDir) })
+    if err := os.Chdir(tempDir); err != nil {
+        t.Fatalf("Failed to change to temp dir: %v", err)
+    }
+
+    commands := [][]string{
+        {"git", "init", "-q"},
+        {"git", "config", "user.email", "test@example.com"},
+        {"git", "config", "user.name", "Test User"},
+        {"git", "add", "a.txt"},
+        {"git", "commit", "-q", "-m", "initial"},
+    }
+    if err := os.WriteFile("a.txt", []byte("base\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+    for _, args := range commands {
+        if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+            t.Fatalf("Failed to run %v: %v: %s", args, err, output)
+        }
+    }
+
+    if err := os.WriteFile("a.txt", []byte("base\nstaged\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+    if output, err := exec.Command("git", "add", "a.txt").CombinedOutput(); err != nil {
+        t.Fatalf("Failed to stage file: %v: %s", err, output)
+    }
+    if err := os.WriteFile("a.txt", []byte("base\nstaged\nunstaged\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+}
+
+// TestStashUnstaged tests that unstaged changes are removed and restored
+func TestStashUnstaged(t *testing.T) {
+    initStagedRepo(t)
+    ctx := context.Background()
+
+    files, err := StagedFiles(ctx)
+    if err != nil {
+        t.Fatalf("Failed to list staged files: %v", err)
+    }
+    if !reflect.DeepEqual(files, []string{"a.txt"}) {
+        t.Errorf("Expected staged a.txt, got %v", files)
+    }
+
+    stash, err := stashUnstaged(ctx)
+    if err != nil {
+        t.Fatalf("Failed to stash unstaged changes: %v", err)
+    }
+    if stash == nil {
+        t.Fatal("Expected unstaged changes to be stashed")
+    }
+    if content, _ := os.ReadFile("a.txt"); string(content) != "base\nstaged\n" {
+        t.Errorf("Expected the working tree to match the index, got %q", content)
+    }
+
+    if err := stash.restore(ctx); err != nil {
+        t.Fatalf("Failed to restore unstaged changes: %v", err)
+    }
+    if content, _ := os.ReadFile("a.txt"); string(content) != "base\nstaged\nunstaged\n" {
+        t.Errorf("Expected unstaged changes to be restored, got %q", content)
+    }
+    if _, err := os.Stat(stash.patch); !os.IsNotExist(err) {
+        t.Error("Expected the patch to be removed")
+    }
+}
+
+// TestStashUnstagedConflict tests that changes that no longer apply are kept
+func TestStashUnstagedConflict(t *testing.T) {
+    initStagedRepo(t)
+    ctx := context.Background()
+
+    stash, err := stashUnstaged(ctx)
+    if err != nil || stash == nil {
+        t.Fatalf("Failed to stash unstaged changes: %v", err)
+    }
+
+    // A step rewrites the file
+    if err := os.WriteFile("a.txt", []byte("formatted\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+    if err := stash.restore(ctx); err == nil {
+        t.Fatal("Expected restore to fail")
+    }
+    if _, err := os.Stat(stash.patch); err != nil {
+        t.Error("Expected the patch to be kept")
+    }
+    if output, _ := exec.Command("git", "stash", "list").Output(); len(output) == 0 {
+        t.Error("Expected the backup to be added to the stash list")
+    }
+
+    // The next run refuses to stash over the kept changes
+    if _, err := stashUnstaged(ctx); err == nil {
+        t.Error("Expected stash to be refused while a patch is kept")
+    }
+}
@@ -0,0 +1,229 @@
+package expr
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// Variables declares the variables an expression may use and their types
+type Variables struct {
+    Types    map[string]Type // Variables by name
+    Prefixes map[string]Type // Families of variables by name prefix, such as env.
+}
+
+// Lookup returns the type of a variable
+func (v *Variables) Lookup(name string) (Type, bool) {
+    if v == nil {
+        return Invalid, false
+    }
+    if typ, ok := v.Types[name]; ok {
+        return typ, true
+    }
+    for prefix, typ := range v.Prefixes {
+        if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+            return typ, true
+        }
+    }
+    return Invalid, false
+}
+
+// checker resolves variables and functions and computes the type of every
+// node, so evaluation cannot fail on types
+type checker struct {
+    source string
+    vars   *Variables
+    used   []string
+}
+
+// errorf returns an error for a node
+func (c *checker) errorf(n node, format string, args ...any) error {
+    start, end := n.span()
+    return &Error{c.source, start, end, fmt.Sprintf(format, args...)}
+}
+
+// check returns the type of a node
+func (c *checker) check(n node) (Type, error) {
+    switch n := n.(type) {
+    case *literal:
+        return typeOf(n.value), nil
+    case *variable:
+        typ, ok := c.vars.Lookup(n.name)
+        if !ok {
+            message := fmt.Sprintf("unknown variable %s", n.name)
+            if c.vars != nil {
+                if name := closest(n.name, c.vars.names()); name != "" {
+                    message += fmt.Sprintf(", did you mean %s?", name)
+                }
+            }
+            return Invalid, c.errorf(n, "%s", message)
+        }
+        n.typ = typ
+        c.used = append(c.used, n.name)
+        return typ, nil
+    case *unary:
+        typ, err := c.check(n.x)
+        if err != nil {
+            return Invalid, err
+        }
+        if typ != Bool {
+            return Invalid, c.errorf(n.x, "operator ! needs a bool, got %s", typ)
+        }
+        return Bool, nil
+    case *binary:
+        return c.checkBinary(n)
+    case *call:
+        return c.checkCall(n)
+    }
+    return Invalid, c.errorf(n, "unsupported expression")
+}
+
+// checkBinary checks the operands of a logical operator or comparison
+func (c *checker) checkBinary(n *binary) (Type, error) {
+    x, err := c.check(n.x)
+    if err != nil {
+        return Invalid, err
+    }
+    y, err := c.check(n.y)
+    if err != nil {
+        return Invalid, err
+    }
+
+    switch n.op {
+    case "&&", "||":
+        for _, operand := range []struct {
+            node node
+            typ  Type
+        }{{n.x, x}, {n.y, y}} {
+            if operand.typ != Bool {
+                return Invalid, c.errorf(operand.node, "operator %s needs bool operands, got %s", n.op, operand.typ)
+            }
+        }
+    case "=~", "!~":
+        if x != String {
+            return Invalid, c.errorf(n.x, "operator %s needs a string, got %s", n.op, x)
+        }
+        if y != String {
+            return Invalid, c.errorf(n.y, "operator %s needs a regular expression string, got %s", n.op, y)
+        }
+        if err := c.checkPattern(n.y, compileRegexp); err != nil {
+            return Invalid, err
+        }
+    case "<", "<=", ">", ">=":
+        if x == Bool {
+            return Invalid, c.errorf(n.x, "operator %s cannot compare bool values", n.op)
+        }
+        fallthrough
+    default:
+        if x != y {
+            return Invalid, c.errorf(n, "cannot compare %s with %s", x, y)
+        }
+    }
+    return Bool, nil
+}
+
+// checkCall checks the arguments of a function call
+func (c *checker) checkCall(n *call) (Type, error) {
+    fn, ok := functions[n.name]
+    if !ok {
+        message := fmt.Sprintf("unknown function %s", n.name)
+        if name := closest(n.name, functionNames()); name != "" {
+            message += fmt.Sprintf(", did you mean %s?", name)
+        }
+        return Invalid, &Error{c.source, n.pos, n.nameEnd, message}
+    }
+    n.fn = fn
+
+    if len(n.args) < len(fn.params) || (!fn.variadic && len(n.args) > len(fn.params)) {
+        want := fmt.Sprintf("%d", len(fn.params))
+        if fn.variadic {
+            want = "at least " + want
+        }
+        return Invalid, c.errorf(n, "%s expects %s argument(s), got %d", n.name, want, len(n.args))
+    }
+    for i, arg := range n.args {
+        typ, err := c.check(arg)
+        if err != nil {
+            return Invalid, err
+        }
+        param := fn.params[min(i, len(fn.params)-1)]
+        if typ != param {
+            return Invalid, c.errorf(arg, "argument %d of %s must be a %s, got %s", i+1, n.name, param, typ)
+        }
+        if fn.pattern != nil && i >= fn.patternArg {
+            if err := c.checkPattern(arg, fn.pattern); err != nil {
+                return Invalid, err
+            }
+        }
+    }
+    return fn.result, nil
+}
+
+// checkPattern compiles a constant pattern, so invalid patterns are found
+// when the configuration is loaded
+func (c *checker) checkPattern(n node, compile func(string) (*regexp.Regexp, error)) error {
+    if lit, ok := n.(*literal); ok {
+        if _, err := compile(lit.value.(string)); err != nil {
+            return c.errorf(n, "%v", err)
+        }
+    }
+    return nil
+}
+
+// names returns the declared variable names
+func (v *Variables) names() []string {
+    var names []string
+    for name := range v.Types {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// typeOf returns the type of a value
+func typeOf(value any) Type {
+    switch value.(type) {
+    case bool:
+        return Bool
+    case float64:
+        return Number
+    case string:
+        return String
+    }
+    return Invalid
+}
+
+// closest returns the candidate nearest to a misspelled name, if any is near
+// enough
+func closest(name string, candidates []string) string {
+    best, bestDistance := "", 0
+    for _, candidate := range candidates {
+        distance := levenshtein(name, candidate)
+        if distance <= max(1, len(candidate)/3) && (best == "" || distance < bestDistance) {
+            best, bestDistance = candidate, distance
+        }
+    }
+    return best
+}
+
+// levenshtein returns the edit distance of two strings
+func levenshtein(a, b string) int {
+    previous := make([]int, len(b)+1)
+    for j := range previous {
+        previous[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        current := make([]int, len(b)+1)
+        current[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] {
+                cost = 0
+            }
+            current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+        }
+        previous = current
+    }
+    return previous[len(b)]
+}
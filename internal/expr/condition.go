@@ -0,0 +1,131 @@
+package expr
+
+import (
+    "fmt"
+    "strings"
+)
+
+// variableTypes are the types of the variables pre-push provides to
+// conditions, see internal/vars. ci is a buildfab built-in, true when the
+// CI environment variable is set.
+var variableTypes = map[string]Type{
+    "ci": Bool,
+    "platform": String, "arch": String, "os": String, "os_version": String, "cpu": Number,
+    "project": String, "module": String, "modules": String,
+    "version": String, "version.version": String, "version.rawversion": String,
+    "version.project": String, "version.module": String, "version.modules": String,
+    "version.type": String, "version.version-type": String, "version.build-type": String,
+    "version.major": Number, "version.minor": Number, "version.patch": Number,
+    "version.commit": String, "version.date": String, "version.tag": String, "version.branch": String,
+    "tag": String, "tags": String, "branch": String, "branches": String,
+    "hook": String, "hook.args": String, "staged_files": String, "changed_files": String,
+    "commit_msg": String, "commit_msg_file": String, "merge_head": String, "merge_squash": Bool,
+}
+
+// variablePrefixes are the families of variables conditions may use
+var variablePrefixes = map[string]Type{
+    "env.":      String,
+    "platform.": String,
+    "matrix.":   String,
+    "inputs.":   String,
+}
+
+// OnlyKeywords are the version types an only entry may name instead of an
+// expression
+var OnlyKeywords = map[string]string{
+    "release":    `version.version-type == "release"`,
+    "prerelease": `version.version-type == "prerelease"`,
+    "major":      `version.minor == 0 && version.patch == 0`,
+    "minor":      `version.minor > 0 && version.patch == 0`,
+    "patch":      `version.patch > 0`,
+}
+
+// StepVariables declares the variables available to the conditions of a
+// step: those pre-push provides and the variables of the step
+func StepVariables(step map[string]string) *Variables {
+    vars := &Variables{Types: make(map[string]Type, len(variableTypes)+len(step)), Prefixes: variablePrefixes}
+    for name, typ := range variableTypes {
+        vars.Types[name] = typ
+    }
+    for name := range step {
+        if _, ok := vars.Types[name]; !ok {
+            vars.Types[name] = String
+        }
+    }
+    return vars
+}
+
+// Condition is the compiled if and only of a step. The step runs when if
+// holds and any only entry holds.
+type Condition struct {
+    If   *Expression
+    Only []*Expression
+}
+
+// CompileCondition compiles the if and only of a step. Errors name the
+// field they are in.
+func CompileCondition(ifSource string, only []string, vars *Variables) (*Condition, error) {
+    condition := &Condition{}
+    if strings.TrimSpace(ifSource) != "" {
+        expression, err := Compile(ifSource, vars)
+        if err != nil {
+            return nil, fmt.Errorf("if: %w", err)
+        }
+        condition.If = expression
+    }
+    for i, entry := range only {
+        expression, err := CompileOnly(entry, vars)
+        if err != nil {
+            return nil, fmt.Errorf("only[%d]: %w", i, err)
+        }
+        condition.Only = append(condition.Only, expression)
+    }
+    return condition, nil
+}
+
+// CompileOnly compiles an only entry, a version type keyword or an
+// expression
+func CompileOnly(entry string, vars *Variables) (*Expression, error) {
+    if keyword, ok := OnlyKeywords[strings.TrimSpace(entry)]; ok {
+        return Compile(keyword, vars)
+    }
+    return Compile(entry, vars)
+}
+
+// IsOnlyKeyword reports whether an only entry is a version type keyword
+func IsOnlyKeyword(entry string) bool {
+    _, ok := OnlyKeywords[strings.TrimSpace(entry)]
+    return ok
+}
+
+// Eval reports whether the step runs
+func (c *Condition) Eval(env *Env) (bool, error) {
+    if c.If != nil {
+        ok, err := c.If.Eval(env)
+        if err != nil || !ok {
+            return false, err
+        }
+    }
+    if len(c.Only) == 0 {
+        return true, nil
+    }
+    for _, only := range c.Only {
+        ok, err := only.Eval(env)
+        if err != nil || ok {
+            return ok, err
+        }
+    }
+    return false, nil
+}
+
+// Variables returns the names of the variables the condition uses
+func (c *Condition) Variables() []string {
+    var names []string
+    if c.If != nil {
+        names = append(names, c.If.Variables()...)
+    }
+    for _, only := range c.Only {
+        names = append(names, only.Variables()...)
+    }
+    return names
+}
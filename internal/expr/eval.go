@@ -0,0 +1,144 @@
+package expr
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// evaluator evaluates a type checked syntax tree
+type evaluator struct {
+    source string
+    env    *Env
+}
+
+// errorf returns an error for a node
+func (e *evaluator) errorf(n node, format string, args ...any) error {
+    start, end := n.span()
+    return &Error{e.source, start, end, fmt.Sprintf(format, args...)}
+}
+
+// eval returns the value of a node: a bool, float64 or string
+func (e *evaluator) eval(n node) (any, error) {
+    switch n := n.(type) {
+    case *literal:
+        return n.value, nil
+    case *variable:
+        return e.variable(n)
+    case *unary:
+        x, err := e.eval(n.x)
+        if err != nil {
+            return nil, err
+        }
+        return !x.(bool), nil
+    case *binary:
+        return e.binary(n)
+    case *call:
+        args := make([]any, len(n.args))
+        for i, arg := range n.args {
+            value, err := e.eval(arg)
+            if err != nil {
+                return nil, err
+            }
+            args[i] = value
+        }
+        value, err := n.fn.call(e.env, args)
+        if err != nil {
+            return nil, e.errorf(n, "%s: %v", n.name, err)
+        }
+        return value, nil
+    }
+    return nil, e.errorf(n, "unsupported expression")
+}
+
+// variable converts the value of a variable to its declared type
+func (e *evaluator) variable(n *variable) (any, error) {
+    value := strings.TrimSpace(e.env.Values[n.name])
+    switch n.typ {
+    case Number:
+        if value == "" {
+            return 0.0, nil
+        }
+        number, err := strconv.ParseFloat(value, 64)
+        if err != nil {
+            return nil, e.errorf(n, "variable %s is not a number: %q", n.name, value)
+        }
+        return number, nil
+    case Bool:
+        if value == "" {
+            return false, nil
+        }
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return nil, e.errorf(n, "variable %s is not a bool: %q", n.name, value)
+        }
+        return b, nil
+    }
+    return e.env.Values[n.name], nil
+}
+
+// binary evaluates a logical operator, with short circuit, or a comparison
+func (e *evaluator) binary(n *binary) (any, error) {
+    x, err := e.eval(n.x)
+    if err != nil {
+        return nil, err
+    }
+    switch n.op {
+    case "&&":
+        if !x.(bool) {
+            return false, nil
+        }
+        return e.eval(n.y)
+    case "||":
+        if x.(bool) {
+            return true, nil
+        }
+        return e.eval(n.y)
+    }
+
+    y, err := e.eval(n.y)
+    if err != nil {
+        return nil, err
+    }
+    switch n.op {
+    case "==":
+        return x == y, nil
+    case "!=":
+        return x != y, nil
+    case "=~", "!~":
+        matched, err := matchRegexp(x.(string), y.(string))
+        if err != nil {
+            return nil, e.errorf(n.y, "%v", err)
+        }
+        return matched == (n.op == "=~"), nil
+    }
+
+    var order int
+    switch x := x.(type) {
+    case float64:
+        order = compare(x, y.(float64))
+    case string:
+        order = strings.Compare(x, y.(string))
+    }
+    switch n.op {
+    case "<":
+        return order < 0, nil
+    case "<=":
+        return order <= 0, nil
+    case ">":
+        return order > 0, nil
+    default:
+        return order >= 0, nil
+    }
+}
+
+// compare returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compare(a, b float64) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
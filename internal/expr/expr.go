@@ -0,0 +1,139 @@
+// Package expr implements the typed expressions of step conditions (if and
+// only). Expressions are parsed and type checked when the configuration is
+// loaded, so mistakes are reported before anything runs, with the offending
+// sub-expression marked.
+//
+//	version.major > 1 && branch =~ "release/.*" && env.CI != "true"
+//
+// Operators are ||, &&, !, the comparisons ==, !=, <, <=, >, >= and the regular
+// expression matches =~ and !~. Functions are listed in functions.
+package expr
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+)
+
+// Type is the type of an expression
+type Type int
+
+const (
+    Invalid Type = iota
+    Bool
+    Number
+    String
+)
+
+// String returns the name of the type
+func (t Type) String() string {
+    switch t {
+    case Bool:
+        return "bool"
+    case Number:
+        return "number"
+    case String:
+        return "string"
+    default:
+        return "invalid"
+    }
+}
+
+// Error is a problem of an expression. Pos and End are the byte offsets of
+// the offending sub-expression in Expr.
+type Error struct {
+    Expr     string
+    Pos, End int
+    Message  string
+}
+
+// Error returns the message followed by the expression with the offending
+// sub-expression underlined
+func (e *Error) Error() string {
+    width := max(utf8.RuneCountInString(e.Expr[e.Pos:e.End]), 1)
+    return fmt.Sprintf("%s\n    %s\n    %s%s", e.Message, e.Expr,
+        strings.Repeat(" ", utf8.RuneCountInString(e.Expr[:e.Pos])), strings.Repeat("^", width))
+}
+
+// Column returns the 1-based column of the offending sub-expression
+func (e *Error) Column() int {
+    return utf8.RuneCountInString(e.Expr[:e.Pos]) + 1
+}
+
+// Expression is a parsed and type checked boolean expression
+type Expression struct {
+    source    string
+    root      node
+    variables []string
+}
+
+// Compile parses an expression and checks it against the declared
+// variables. A condition must be boolean. An expression wrapped in ${{ }}
+// is unwrapped.
+func Compile(source string, vars *Variables) (*Expression, error) {
+    source = strings.TrimSpace(source)
+    if inner, ok := strings.CutPrefix(source, "${{"); ok {
+        if inner, ok = strings.CutSuffix(inner, "}}"); ok {
+            source = strings.TrimSpace(inner)
+        }
+    }
+
+    p := &parser{source: source}
+    root, err := p.parse()
+    if err != nil {
+        return nil, err
+    }
+    c := &checker{source: source, vars: vars}
+    typ, err := c.check(root)
+    if err != nil {
+        return nil, err
+    }
+    if typ != Bool {
+        start, end := root.span()
+        return nil, &Error{source, start, end, fmt.Sprintf("condition must be a bool, got %s", typ)}
+    }
+    return &Expression{source: source, root: root, variables: c.used}, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+    return e.source
+}
+
+// Variables returns the names of the variables the expression uses
+func (e *Expression) Variables() []string {
+    return e.variables
+}
+
+// Env holds what an expression is evaluated with
+type Env struct {
+    Values map[string]string         // Values of the variables, unset variables are the zero value of their type
+    Files  func() ([]string, error) // Changed files for changed(), called at most once
+    files  []string
+    loaded bool
+}
+
+// changedFiles returns the changed files, loading them on first use
+func (env *Env) changedFiles() ([]string, error) {
+    if !env.loaded && env.Files != nil {
+        files, err := env.Files()
+        if err != nil {
+            return nil, err
+        }
+        env.files = files
+    }
+    env.loaded = true
+    return env.files, nil
+}
+
+// Eval evaluates the expression
+func (e *Expression) Eval(env *Env) (bool, error) {
+    if env == nil {
+        env = &Env{}
+    }
+    value, err := (&evaluator{source: e.source, env: env}).eval(e.root)
+    if err != nil {
+        return false, err
+    }
+    return value.(bool), nil
+}
@@ -0,0 +1,187 @@
+package expr
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestEval(t *testing.T) {
+    values := map[string]string{
+        "version.major":        "2",
+        "version.minor":        "0",
+        "version.patch":        "0",
+        "version.version-type": "release",
+        "branch":               "release/2.0",
+        "env.CI":               "false",
+        "merge_squash":         "true",
+        "staged_files":         "cmd/main.go",
+    }
+    env := &Env{Values: values, Files: func() ([]string, error) {
+        return []string{"docs/guide.md", "internal/expr/expr.go"}, nil
+    }}
+
+    tests := []struct {
+        expr     string
+        expected bool
+    }{
+        {`version.major > 1 && branch =~ "release/.*" && env.CI != "true"`, true},
+        {`version.major >= 3 || branch == "main"`, false},
+        {`!(version.major == 2)`, false},
+        {`branch !~ "^main$"`, true},
+        {`matches(branch, "^release/[0-9.]+$")`, true},
+        {`glob("internal/expr/expr.go", "internal/**/*.go")`, true},
+        {`glob("internal/expr/expr.go", "internal/*.go")`, false},
+        {`glob("main.go", "**/*.go")`, true},
+        {`changed("internal/**")`, true},
+        {`changed("web/**", "*.md")`, false},
+        {`changed("web/**", "docs/*.md")`, true},
+        {`contains(staged_files, "cmd/") && startsWith(branch, "release") && endsWith(branch, ".0")`, true},
+        {`upper(env.CI) == "FALSE" && lower("A") == "a"`, true},
+        {`merge_squash`, true},
+        {`env.UNSET == "" && tag == ""`, true},
+        {`semverCompare("1.2.3", "1.10.0") < 0`, true},
+        {`"b" > "a" && 1.5 < 2 && -1 < 0`, true},
+        {`${{ branch == 'release/2.0' }}`, true},
+    }
+    for _, test := range tests {
+        expression, err := Compile(test.expr, StepVariables(nil))
+        if err != nil {
+            t.Errorf("Failed to compile %s: %v", test.expr, err)
+            continue
+        }
+        result, err := expression.Eval(env)
+        if err != nil {
+            t.Errorf("Failed to evaluate %s: %v", test.expr, err)
+            continue
+        }
+        if result != test.expected {
+            t.Errorf("Expected %s to be %v", test.expr, test.expected)
+        }
+    }
+}
+
+// TestBuildfabConditions tests that conditions buildfab evaluated before
+// pre-push checked them still compile and keep their meaning
+func TestBuildfabConditions(t *testing.T) {
+    env := &Env{Values: map[string]string{
+        "ci":     "true",
+        "os":     "linux",
+        "arch":   "amd64",
+        "branch": "main",
+        "env.CI": "true",
+    }}
+
+    tests := []struct {
+        expr     string
+        expected bool
+    }{
+        {`ci`, true},
+        {`!ci`, false},
+        {`ci == true`, true},
+        {`ci && os == "linux"`, true},
+        {`inputs.mode == ""`, true},
+        {`inputs.mode != "fast" || !ci`, true},
+        {`os == "linux" && arch != "arm64"`, true},
+        {`branch == "main" && env.CI == "true"`, true},
+        {`contains(branch, "ma") && startsWith(os, "li") && endsWith(arch, "64")`, true},
+        {`matches(branch, "^main$")`, true},
+        {`${{ os == 'windows' }}`, false},
+    }
+    for _, test := range tests {
+        expression, err := Compile(test.expr, StepVariables(nil))
+        if err != nil {
+            t.Errorf("Failed to compile %s: %v", test.expr, err)
+            continue
+        }
+        result, err := expression.Eval(env)
+        if err != nil {
+            t.Errorf("Failed to evaluate %s: %v", test.expr, err)
+            continue
+        }
+        if result != test.expected {
+            t.Errorf("Expected %s to be %v", test.expr, test.expected)
+        }
+    }
+}
+
+func TestCompileErrors(t *testing.T) {
+    tests := []struct {
+        expr    string
+        snippet string // Offending sub-expression
+        message string
+    }{
+        {`version.major > "1"`, `version.major > "1"`, "cannot compare number with string"},
+        {`branch == "main" && version.mjaor > 1`, "version.mjaor", "unknown variable version.mjaor, did you mean version.major?"},
+        {`branch`, "branch", "condition must be a bool, got string"},
+        {`!branch`, "branch", "operator ! needs a bool, got string"},
+        {`branch =~ "release/(.*"`, `"release/(.*"`, "invalid regular expression"},
+        {`glob(branch, 1)`, "1", "argument 2 of glob must be a string, got number"},
+        {`changed()`, "changed()", "changed expects at least 1 argument(s), got 0"},
+        {`chagned("a/**")`, "chagned", "unknown function chagned, did you mean changed?"},
+        {`branch = "main"`, "=", "use == to compare"},
+        {`1 < 2 < 3`, "1 < 2 <", "comparisons cannot be chained"},
+        {`(branch == "main"`, `(branch == "main"`, "missing )"},
+        {`branch == "main`, `"main`, "unterminated string"},
+        {`true > false`, "true", "cannot compare bool values"},
+        {`version.major > 1 &&`, "", "expected a value, got end of expression"},
+    }
+    for _, test := range tests {
+        _, err := Compile(test.expr, StepVariables(nil))
+        var exprErr *Error
+        if !errors.As(err, &exprErr) {
+            t.Errorf("Expected an expression error for %s, got %v", test.expr, err)
+            continue
+        }
+        if snippet := exprErr.Expr[exprErr.Pos:exprErr.End]; snippet != test.snippet || !strings.Contains(exprErr.Message, test.message) {
+            t.Errorf("Expected %q at %q for %s, got %q at %q", test.message, test.snippet, test.expr, exprErr.Message, snippet)
+        }
+    }
+}
+
+func TestErrorMarksSubExpression(t *testing.T) {
+    _, err := Compile(`branch == "main" && version.major > "1"`, StepVariables(nil))
+    expected := "cannot compare number with string\n" +
+        "    branch == \"main\" && version.major > \"1\"\n" +
+        "                        ^^^^^^^^^^^^^^^^^^^"
+    if err == nil || err.Error() != expected {
+        t.Errorf("Expected:\n%s\ngot:\n%v", expected, err)
+    }
+}
+
+func TestEvalErrors(t *testing.T) {
+    expression, err := Compile(`version.major > 1`, StepVariables(nil))
+    if err != nil {
+        t.Fatalf("Failed to compile: %v", err)
+    }
+    if _, err := expression.Eval(&Env{Values: map[string]string{"version.major": "x"}}); err == nil || !strings.Contains(err.Error(), "variable version.major is not a number") {
+        t.Errorf("Expected a number error, got %v", err)
+    }
+}
+
+func TestCondition(t *testing.T) {
+    vars := StepVariables(map[string]string{"target": "linux"})
+    condition, err := CompileCondition(`target == "linux"`, []string{"major", `branch == "main"`}, vars)
+    if err != nil {
+        t.Fatalf("Failed to compile condition: %v", err)
+    }
+
+    tests := []struct {
+        values   map[string]string
+        expected bool
+    }{
+        {map[string]string{"target": "linux", "version.major": "2"}, true},
+        {map[string]string{"target": "linux", "version.patch": "1", "branch": "main"}, true},
+        {map[string]string{"target": "linux", "version.patch": "1"}, false},
+        {map[string]string{"target": "darwin"}, false},
+    }
+    for _, test := range tests {
+        if result, err := condition.Eval(&Env{Values: test.values}); err != nil || result != test.expected {
+            t.Errorf("Expected %v for %v, got %v, %v", test.expected, test.values, result, err)
+        }
+    }
+
+    if _, err := CompileCondition("", []string{"release", "branch"}, vars); err == nil || !strings.HasPrefix(err.Error(), "only[1]: ") {
+        t.Errorf("Expected an error in only[1], got %v", err)
+    }
+}
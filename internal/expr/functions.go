@@ -0,0 +1,176 @@
+package expr
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/AlexBurnes/version-go/pkg/version"
+)
+
+// function is a built-in function
+type function struct {
+    params     []Type
+    variadic   bool // The last parameter repeats
+    result     Type
+    pattern    func(string) (*regexp.Regexp, error) // Compiles constant pattern arguments
+    patternArg int                                  // Index of the first pattern argument
+    call       func(env *Env, args []any) (any, error)
+}
+
+// functions are the built-in functions by name
+var functions map[string]*function
+
+func init() {
+    functions = map[string]*function{
+        // matches(s, regexp) reports whether s matches a regular expression
+        "matches": {params: []Type{String, String}, result: Bool, pattern: compileRegexp, patternArg: 1,
+            call: func(env *Env, args []any) (any, error) {
+                return matchRegexp(args[0].(string), args[1].(string))
+            }},
+        // glob(path, pattern) reports whether a path matches a glob pattern
+        "glob": {params: []Type{String, String}, result: Bool, pattern: compileGlob, patternArg: 1,
+            call: func(env *Env, args []any) (any, error) {
+                re, err := compileGlob(args[1].(string))
+                if err != nil {
+                    return nil, err
+                }
+                return re.MatchString(args[0].(string)), nil
+            }},
+        // changed(pattern...) reports whether a changed file matches a pattern
+        "changed": {params: []Type{String}, variadic: true, result: Bool, pattern: compileGlob,
+            call: func(env *Env, args []any) (any, error) {
+                files, err := env.changedFiles()
+                if err != nil {
+                    return nil, fmt.Errorf("failed to list changed files: %w", err)
+                }
+                for _, arg := range args {
+                    re, err := compileGlob(arg.(string))
+                    if err != nil {
+                        return nil, err
+                    }
+                    for _, file := range files {
+                        if re.MatchString(file) {
+                            return true, nil
+                        }
+                    }
+                }
+                return false, nil
+            }},
+        "contains": {params: []Type{String, String}, result: Bool,
+            call: func(env *Env, args []any) (any, error) {
+                return strings.Contains(args[0].(string), args[1].(string)), nil
+            }},
+        "startsWith": {params: []Type{String, String}, result: Bool,
+            call: func(env *Env, args []any) (any, error) {
+                return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+            }},
+        "endsWith": {params: []Type{String, String}, result: Bool,
+            call: func(env *Env, args []any) (any, error) {
+                return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+            }},
+        "lower": {params: []Type{String}, result: String,
+            call: func(env *Env, args []any) (any, error) {
+                return strings.ToLower(args[0].(string)), nil
+            }},
+        "upper": {params: []Type{String}, result: String,
+            call: func(env *Env, args []any) (any, error) {
+                return strings.ToUpper(args[0].(string)), nil
+            }},
+        // fileExists(path) reports whether a file exists
+        "fileExists": {params: []Type{String}, result: Bool,
+            call: func(env *Env, args []any) (any, error) {
+                _, err := os.Stat(args[0].(string))
+                return err == nil, nil
+            }},
+        // semverCompare(a, b) returns -1, 0 or 1 as version a is older, equal or newer than b
+        "semverCompare": {params: []Type{String, String}, result: Number,
+            call: func(env *Env, args []any) (any, error) {
+                a, err := version.Parse(args[0].(string))
+                if err != nil {
+                    return nil, fmt.Errorf("invalid version %q: %w", args[0], err)
+                }
+                b, err := version.Parse(args[1].(string))
+                if err != nil {
+                    return nil, fmt.Errorf("invalid version %q: %w", args[1], err)
+                }
+                return float64(version.Compare(a, b)), nil
+            }},
+    }
+}
+
+// functionNames returns the names of the built-in functions
+func functionNames() []string {
+    var names []string
+    for name := range functions {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// compileRegexp compiles a regular expression
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+    re, err := regexp.Compile(pattern)
+    if err != nil {
+        return nil, fmt.Errorf("invalid regular expression %q: %s", pattern, strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+    }
+    return re, nil
+}
+
+// matchRegexp reports whether s contains a match of a regular expression
+func matchRegexp(s, pattern string) (bool, error) {
+    re, err := compileRegexp(pattern)
+    if err != nil {
+        return false, err
+    }
+    return re.MatchString(s), nil
+}
+
+// compileGlob compiles a glob pattern matching whole slash separated paths:
+// * matches within a path element, ** across elements, ? one character and
+// [...] a character class
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+    var b strings.Builder
+    b.WriteString("^")
+    for i := 0; i < len(pattern); i++ {
+        switch c := pattern[i]; c {
+        case '*':
+            if i+1 < len(pattern) && pattern[i+1] == '*' {
+                i++
+                if i+1 < len(pattern) && pattern[i+1] == '/' {
+                    // **/ matches any number of leading directories
+                    i++
+                    b.WriteString("(?:.*/)?")
+                } else {
+                    b.WriteString(".*")
+                }
+            } else {
+                b.WriteString("[^/]*")
+            }
+        case '?':
+            b.WriteString("[^/]")
+        case '[':
+            end := strings.IndexByte(pattern[i+1:], ']')
+            if end < 0 {
+                return nil, fmt.Errorf("invalid glob pattern %q: missing ]", pattern)
+            }
+            class := pattern[i+1 : i+1+end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            b.WriteString("[" + class + "]")
+            i += end + 1
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    b.WriteString("$")
+    re, err := regexp.Compile(b.String())
+    if err != nil {
+        return nil, fmt.Errorf("invalid glob pattern %q", pattern)
+    }
+    return re, nil
+}
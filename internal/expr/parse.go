@@ -0,0 +1,375 @@
+package expr
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// node is a node of the syntax tree
+type node interface {
+    span() (int, int)
+}
+
+// literal is a bool, number or string constant
+type literal struct {
+    pos, end int
+    value    any
+}
+
+// variable is a reference to a variable, its type is set by the checker
+type variable struct {
+    pos, end int
+    name     string
+    typ      Type
+}
+
+// unary is the negation of an operand
+type unary struct {
+    pos, end int
+    op       string
+    x        node
+}
+
+// binary is a logical operator or a comparison
+type binary struct {
+    pos, end int
+    op       string
+    x, y     node
+}
+
+// call is a function call, its function is set by the checker
+type call struct {
+    pos, end int
+    name     string
+    nameEnd  int
+    args     []node
+    fn       *function
+}
+
+func (n *literal) span() (int, int)  { return n.pos, n.end }
+func (n *variable) span() (int, int) { return n.pos, n.end }
+func (n *unary) span() (int, int)    { return n.pos, n.end }
+func (n *binary) span() (int, int)   { return n.pos, n.end }
+func (n *call) span() (int, int)     { return n.pos, n.end }
+
+// token kinds
+const (
+    tokenEOF = iota
+    tokenIdent
+    tokenNumber
+    tokenString
+    tokenOperator
+)
+
+// token is a lexical token with its byte offsets in the source
+type token struct {
+    kind     int
+    text     string
+    value    any
+    pos, end int
+}
+
+// operators lists the operators, longer ones first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")", ","}
+
+// parser is a recursive descent parser. Precedence from low to high: ||,
+// &&, comparisons (not chainable), !.
+type parser struct {
+    source string
+    offset int
+    tok    token
+}
+
+// errorf returns an error for the source between pos and end
+func (p *parser) errorf(pos, end int, format string, args ...any) error {
+    return &Error{p.source, pos, end, fmt.Sprintf(format, args...)}
+}
+
+// parse parses the whole source
+func (p *parser) parse() (node, error) {
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    if p.tok.kind == tokenEOF {
+        return nil, p.errorf(0, 0, "empty expression")
+    }
+    root, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if p.tok.kind != tokenEOF {
+        return nil, p.errorf(p.tok.pos, p.tok.end, "unexpected %s", p.describe())
+    }
+    return root, nil
+}
+
+// describe describes the current token for messages
+func (p *parser) describe() string {
+    if p.tok.kind == tokenEOF {
+        return "end of expression"
+    }
+    return strconv.Quote(p.tok.text)
+}
+
+// next reads the next token
+func (p *parser) next() error {
+    for p.offset < len(p.source) && strings.ContainsRune(" \t\r\n", rune(p.source[p.offset])) {
+        p.offset++
+    }
+    start := p.offset
+    if start == len(p.source) {
+        p.tok = token{kind: tokenEOF, pos: start, end: start}
+        return nil
+    }
+
+    c := p.source[start]
+    switch {
+    case isIdentStart(c):
+        end := start + 1
+        for end < len(p.source) && isIdentPart(p.source[end]) {
+            end++
+        }
+        // A name does not end with a separator
+        for end > start+1 && strings.ContainsRune(".-", rune(p.source[end-1])) {
+            end--
+        }
+        p.tok = token{kind: tokenIdent, text: p.source[start:end], pos: start, end: end}
+    case isDigit(c) || (c == '-' && start+1 < len(p.source) && isDigit(p.source[start+1])):
+        end := start + 1
+        for end < len(p.source) && (isDigit(p.source[end]) || p.source[end] == '.') {
+            end++
+        }
+        value, err := strconv.ParseFloat(p.source[start:end], 64)
+        if err != nil {
+            return p.errorf(start, end, "invalid number %s", p.source[start:end])
+        }
+        p.tok = token{kind: tokenNumber, text: p.source[start:end], value: value, pos: start, end: end}
+    case c == '"' || c == '\'':
+        var b strings.Builder
+        end := start + 1
+        for {
+            if end >= len(p.source) {
+                return p.errorf(start, end, "unterminated string")
+            }
+            ch := p.source[end]
+            if ch == c {
+                end++
+                break
+            }
+            if ch == '\\' && end+1 < len(p.source) {
+                end++
+                ch = p.source[end]
+                switch ch {
+                case 'n':
+                    ch = '\n'
+                case 't':
+                    ch = '\t'
+                case '"', '\'', '\\':
+                default:
+                    // Other escapes are kept, so regular expressions read naturally
+                    b.WriteByte('\\')
+                }
+            }
+            b.WriteByte(ch)
+            end++
+        }
+        p.tok = token{kind: tokenString, text: p.source[start:end], value: b.String(), pos: start, end: end}
+    default:
+        for _, op := range operators {
+            if strings.HasPrefix(p.source[start:], op) {
+                p.tok = token{kind: tokenOperator, text: op, pos: start, end: start + len(op)}
+                p.offset = p.tok.end
+                return nil
+            }
+        }
+        if c == '=' {
+            return p.errorf(start, start+1, "unexpected \"=\", use == to compare")
+        }
+        return p.errorf(start, start+1, "unexpected character %q", c)
+    }
+    p.offset = p.tok.end
+    return nil
+}
+
+// is reports whether the current token is the operator op
+func (p *parser) is(op string) bool {
+    return p.tok.kind == tokenOperator && p.tok.text == op
+}
+
+// parseOr parses a || b || ...
+func (p *parser) parseOr() (node, error) {
+    x, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    for p.is("||") {
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        y, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        x = newBinary("||", x, y)
+    }
+    return x, nil
+}
+
+// parseAnd parses a && b && ...
+func (p *parser) parseAnd() (node, error) {
+    x, err := p.parseComparison()
+    if err != nil {
+        return nil, err
+    }
+    for p.is("&&") {
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        y, err := p.parseComparison()
+        if err != nil {
+            return nil, err
+        }
+        x = newBinary("&&", x, y)
+    }
+    return x, nil
+}
+
+// parseComparison parses an operand optionally compared with another
+func (p *parser) parseComparison() (node, error) {
+    x, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    if p.tok.kind != tokenOperator || !isComparison(p.tok.text) {
+        return x, nil
+    }
+    op := p.tok.text
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    y, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    if p.tok.kind == tokenOperator && isComparison(p.tok.text) {
+        start, _ := x.span()
+        return nil, p.errorf(start, p.tok.end, "comparisons cannot be chained, use &&")
+    }
+    return newBinary(op, x, y), nil
+}
+
+// parseUnary parses !operand or an operand
+func (p *parser) parseUnary() (node, error) {
+    if !p.is("!") {
+        return p.parsePrimary()
+    }
+    pos := p.tok.pos
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    x, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    _, end := x.span()
+    return &unary{pos: pos, end: end, op: "!", x: x}, nil
+}
+
+// parsePrimary parses a literal, variable, function call or parenthesized
+// expression
+func (p *parser) parsePrimary() (node, error) {
+    tok := p.tok
+    switch tok.kind {
+    case tokenNumber, tokenString:
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        return &literal{pos: tok.pos, end: tok.end, value: tok.value}, nil
+    case tokenIdent:
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        if p.is("(") {
+            return p.parseCall(tok)
+        }
+        switch tok.text {
+        case "true", "false":
+            return &literal{pos: tok.pos, end: tok.end, value: tok.text == "true"}, nil
+        }
+        return &variable{pos: tok.pos, end: tok.end, name: tok.text}, nil
+    case tokenOperator:
+        if tok.text == "(" {
+            if err := p.next(); err != nil {
+                return nil, err
+            }
+            x, err := p.parseOr()
+            if err != nil {
+                return nil, err
+            }
+            if !p.is(")") {
+                return nil, p.errorf(tok.pos, p.tok.pos, "missing )")
+            }
+            return x, p.next()
+        }
+    }
+    return nil, p.errorf(tok.pos, tok.end, "expected a value, got %s", p.describe())
+}
+
+// parseCall parses the arguments of a function call
+func (p *parser) parseCall(name token) (node, error) {
+    n := &call{pos: name.pos, name: name.text, nameEnd: name.end}
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    for !p.is(")") {
+        if len(n.args) > 0 {
+            if !p.is(",") {
+                return nil, p.errorf(p.tok.pos, p.tok.end, "expected , or ) in call of %s, got %s", name.text, p.describe())
+            }
+            if err := p.next(); err != nil {
+                return nil, err
+            }
+        }
+        if p.tok.kind == tokenEOF {
+            return nil, p.errorf(name.pos, p.tok.pos, "missing ) in call of %s", name.text)
+        }
+        arg, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        n.args = append(n.args, arg)
+    }
+    n.end = p.tok.end
+    return n, p.next()
+}
+
+// newBinary returns a binary node spanning its operands
+func newBinary(op string, x, y node) *binary {
+    start, _ := x.span()
+    _, end := y.span()
+    return &binary{pos: start, end: end, op: op, x: x, y: y}
+}
+
+// isComparison reports whether an operator is a comparison
+func isComparison(op string) bool {
+    switch op {
+    case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+        return true
+    }
+    return false
+}
+
+func isDigit(c byte) bool {
+    return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+    return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isIdentPart reports whether a byte continues a name. Names contain dots
+// and dashes, as in version.build-type.
+func isIdentPart(c byte) bool {
+    return isIdentStart(c) || isDigit(c) || c == '.' || c == '-'
+}
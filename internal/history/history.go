@@ -0,0 +1,279 @@
+// Package history provides persistence of pre-push run results.
+package history
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+const (
+    // DefaultRetention is the default number of runs kept in the history
+    DefaultRetention = 20
+
+    // runFileName is the name of the run record inside a run directory
+    runFileName = "run.json"
+
+    // runIDLayout is the time layout used as prefix of run IDs (sortable)
+    runIDLayout = "20060102-150405"
+)
+
+// Ref represents a Git reference that was pushed during a run
+type Ref struct {
+    LocalRef  string `json:"local_ref"`
+    LocalSHA  string `json:"local_sha"`
+    RemoteRef string `json:"remote_ref"`
+    RemoteSHA string `json:"remote_sha"`
+}
+
+// Step represents the recorded result of a single step
+type Step struct {
+    Name     string        `json:"name"`
+    Status   string        `json:"status"`
+    Message  string        `json:"message,omitempty"`
+    Duration time.Duration `json:"duration"`
+    Log      string        `json:"log,omitempty"` // Log file name relative to the run directory
+}
+
+// Run represents a single recorded pre-push run
+type Run struct {
+    ID        string        `json:"id"`
+    Timestamp time.Time     `json:"timestamp"`
+    Stage     string        `json:"stage"`
+    Hook      bool          `json:"hook"`
+    Remote    string        `json:"remote,omitempty"`
+    RemoteURL string        `json:"remote_url,omitempty"`
+    Refs      []Ref         `json:"refs,omitempty"`
+    Success   bool          `json:"success"`
+    Duration  time.Duration `json:"duration"`
+    Steps     []Step        `json:"steps"`
+
+    dir string
+}
+
+// Dir returns the directory of the run
+func (r *Run) Dir() string {
+    return r.dir
+}
+
+// LogPath returns the absolute path of a step log, or empty string if the step has no log.
+// Logs outside of the run directory, in PRE_PUSH_LOG_DIR, are recorded as absolute paths.
+func (r *Run) LogPath(step Step) string {
+    if step.Log == "" || filepath.IsAbs(step.Log) {
+        return step.Log
+    }
+    return filepath.Join(r.dir, step.Log)
+}
+
+// GetStep returns the step with the specified name
+func (r *Run) GetStep(name string) (Step, bool) {
+    for _, step := range r.Steps {
+        if step.Name == name {
+            return step, true
+        }
+    }
+    return Step{}, false
+}
+
+// Store manages run records in a directory (usually .git/pre-push/runs)
+type Store struct {
+    dir       string
+    retention int
+}
+
+// NewStore creates a new run store; retention is the number of runs to keep
+func NewStore(dir string, retention int) *Store {
+    return &Store{
+        dir:       dir,
+        retention: retention,
+    }
+}
+
+// Dir returns the store directory
+func (s *Store) Dir() string {
+    return s.dir
+}
+
+// NewRun creates a new run with its own directory for logs
+func (s *Store) NewRun(stage string) (*Run, error) {
+    if err := os.MkdirAll(s.dir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create history directory: %w", err)
+    }
+
+    now := time.Now()
+    dir, err := os.MkdirTemp(s.dir, now.Format(runIDLayout)+"-")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create run directory: %w", err)
+    }
+
+    return &Run{
+        ID:        filepath.Base(dir),
+        Timestamp: now,
+        Stage:     stage,
+        dir:       dir,
+    }, nil
+}
+
+// Save writes the run record and prunes runs beyond the retention limit
+func (s *Store) Save(run *Run) error {
+    // Store log paths relative to the run directory
+    for i, step := range run.Steps {
+        if step.Log != "" && filepath.IsAbs(step.Log) {
+            if rel, err := filepath.Rel(run.dir, step.Log); err == nil && !strings.HasPrefix(rel, "..") {
+                run.Steps[i].Log = rel
+            }
+        }
+    }
+
+    data, err := json.MarshalIndent(run, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode run: %w", err)
+    }
+
+    if err := os.WriteFile(filepath.Join(run.dir, runFileName), data, 0644); err != nil {
+        return fmt.Errorf("failed to write run: %w", err)
+    }
+
+    return s.Prune()
+}
+
+// Discard removes a run that should not be kept
+func (s *Store) Discard(run *Run) error {
+    return os.RemoveAll(run.dir)
+}
+
+// List returns all recorded runs, newest first
+func (s *Store) List() ([]*Run, error) {
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to read history directory: %w", err)
+    }
+
+    var runs []*Run
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue
+        }
+        run, err := s.Load(entry.Name())
+        if err != nil {
+            // Skip incomplete or corrupted runs
+            continue
+        }
+        runs = append(runs, run)
+    }
+
+    sort.Slice(runs, func(i, j int) bool {
+        if runs[i].Timestamp.Equal(runs[j].Timestamp) {
+            return runs[i].ID > runs[j].ID
+        }
+        return runs[i].Timestamp.After(runs[j].Timestamp)
+    })
+
+    return runs, nil
+}
+
+// Load loads a run by its ID
+func (s *Store) Load(id string) (*Run, error) {
+    dir := filepath.Join(s.dir, id)
+    data, err := os.ReadFile(filepath.Join(dir, runFileName))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+    }
+
+    var run Run
+    if err := json.Unmarshal(data, &run); err != nil {
+        return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+    }
+    run.dir = dir
+
+    return &run, nil
+}
+
+// Last returns the most recent run
+func (s *Store) Last() (*Run, error) {
+    runs, err := s.List()
+    if err != nil {
+        return nil, err
+    }
+    if len(runs) == 0 {
+        return nil, fmt.Errorf("no recorded runs found")
+    }
+    return runs[0], nil
+}
+
+// Prune removes the oldest runs beyond the retention limit
+func (s *Store) Prune() error {
+    if s.retention <= 0 {
+        return nil
+    }
+
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("failed to read history directory: %w", err)
+    }
+
+    // Run IDs start with a sortable timestamp
+    var ids []string
+    for _, entry := range entries {
+        if entry.IsDir() {
+            ids = append(ids, entry.Name())
+        }
+    }
+    sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+    for i := s.retention; i < len(ids); i++ {
+        // Logs outside of the run directory go with the run
+        if run, err := s.Load(ids[i]); err == nil {
+            for _, step := range run.Steps {
+                if filepath.IsAbs(step.Log) {
+                    os.Remove(step.Log)
+                }
+            }
+        }
+        if err := os.RemoveAll(filepath.Join(s.dir, ids[i])); err != nil {
+            return fmt.Errorf("failed to remove run %s: %w", ids[i], err)
+        }
+    }
+
+    return nil
+}
+
+// TailFile returns the last n lines of a file and the number of earlier lines omitted
+func TailFile(path string, n int) ([]string, int, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer file.Close()
+
+    var lines []string
+    total := 0
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for scanner.Scan() {
+        total++
+        if n <= 0 {
+            continue
+        }
+        lines = append(lines, scanner.Text())
+        if len(lines) > n {
+            lines = lines[1:]
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, 0, err
+    }
+
+    return lines, total - len(lines), nil
+}
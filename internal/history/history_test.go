@@ -0,0 +1,136 @@
+package history
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestStoreSaveAndLoad(t *testing.T) {
+    store := NewStore(filepath.Join(t.TempDir(), "runs"), 10)
+
+    run, err := store.NewRun("pre-push")
+    if err != nil {
+        t.Fatalf("Failed to create run: %v", err)
+    }
+
+    logPath := filepath.Join(run.Dir(), "step.log")
+    if err := os.WriteFile(logPath, []byte("line 1\nline 2\n"), 0644); err != nil {
+        t.Fatalf("Failed to write log: %v", err)
+    }
+
+    run.Success = false
+    run.Refs = []Ref{{LocalRef: "refs/heads/main", LocalSHA: "abc", RemoteRef: "refs/heads/main", RemoteSHA: "def"}}
+    run.Steps = []Step{
+        {Name: "ok-step", Status: "OK", Duration: time.Second},
+        {Name: "bad-step", Status: "ERROR", Message: "failed", Log: logPath},
+    }
+
+    if err := store.Save(run); err != nil {
+        t.Fatalf("Failed to save run: %v", err)
+    }
+
+    last, err := store.Last()
+    if err != nil {
+        t.Fatalf("Failed to load last run: %v", err)
+    }
+
+    if last.ID != run.ID {
+        t.Errorf("Expected last run ID '%s', got '%s'", run.ID, last.ID)
+    }
+    if len(last.Steps) != 2 {
+        t.Fatalf("Expected 2 steps, got %d", len(last.Steps))
+    }
+
+    step, exists := last.GetStep("bad-step")
+    if !exists {
+        t.Fatal("Expected bad-step to exist")
+    }
+    if step.Log != "step.log" {
+        t.Errorf("Expected log path relative to run directory, got '%s'", step.Log)
+    }
+    if last.LogPath(step) != logPath {
+        t.Errorf("Expected log path '%s', got '%s'", logPath, last.LogPath(step))
+    }
+}
+
+func TestStorePrune(t *testing.T) {
+    store := NewStore(filepath.Join(t.TempDir(), "runs"), 2)
+
+    for i := 0; i < 4; i++ {
+        run, err := store.NewRun("pre-push")
+        if err != nil {
+            t.Fatalf("Failed to create run: %v", err)
+        }
+        // Make run IDs distinct in time order
+        run.Timestamp = run.Timestamp.Add(time.Duration(i) * time.Second)
+        if err := store.Save(run); err != nil {
+            t.Fatalf("Failed to save run: %v", err)
+        }
+    }
+
+    runs, err := store.List()
+    if err != nil {
+        t.Fatalf("Failed to list runs: %v", err)
+    }
+    if len(runs) != 2 {
+        t.Errorf("Expected 2 runs after pruning, got %d", len(runs))
+    }
+}
+
+func TestStoreLogOutsideRun(t *testing.T) {
+    store := NewStore(filepath.Join(t.TempDir(), "runs"), 1)
+    logDir := t.TempDir()
+
+    run, err := store.NewRun("pre-push")
+    if err != nil {
+        t.Fatalf("Failed to create run: %v", err)
+    }
+    logPath := filepath.Join(logDir, "step.log")
+    if err := os.WriteFile(logPath, []byte("line 1\n"), 0644); err != nil {
+        t.Fatalf("Failed to write log: %v", err)
+    }
+    run.Steps = []Step{{Name: "bad-step", Status: "ERROR", Log: logPath}}
+    if err := store.Save(run); err != nil {
+        t.Fatalf("Failed to save run: %v", err)
+    }
+
+    last, err := store.Last()
+    if err != nil {
+        t.Fatalf("Failed to load last run: %v", err)
+    }
+    step, _ := last.GetStep("bad-step")
+    if last.LogPath(step) != logPath {
+        t.Errorf("Expected log path '%s', got '%s'", logPath, last.LogPath(step))
+    }
+
+    // A newer run prunes the first one together with its log
+    if err := os.Mkdir(filepath.Join(store.dir, "99991231-235959-next"), 0755); err != nil {
+        t.Fatalf("Failed to create run directory: %v", err)
+    }
+    if err := store.Prune(); err != nil {
+        t.Fatalf("Failed to prune: %v", err)
+    }
+    if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+        t.Errorf("Expected log outside of the run directory to be removed with the run")
+    }
+}
+
+func TestTailFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "test.log")
+    if err := os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0644); err != nil {
+        t.Fatalf("Failed to write file: %v", err)
+    }
+
+    lines, omitted, err := TailFile(path, 2)
+    if err != nil {
+        t.Fatalf("Failed to tail file: %v", err)
+    }
+    if omitted != 2 {
+        t.Errorf("Expected 2 omitted lines, got %d", omitted)
+    }
+    if len(lines) != 2 || lines[0] != "c" || lines[1] != "d" {
+        t.Errorf("Expected [c d], got %v", lines)
+    }
+}
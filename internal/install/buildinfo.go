@@ -0,0 +1,96 @@
+package install
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os/exec"
+    "strings"
+    "time"
+
+    "github.com/AlexBurnes/pre-push/internal/version"
+)
+
+// BuildInfo is the build metadata embedded in a pre-push binary
+type BuildInfo struct {
+    Name      string `json:"name"`
+    Version   string `json:"version"`
+    Commit    string `json:"commit"`
+    Date      string `json:"date"`
+    GoVersion string `json:"go"`
+    Platform  string `json:"platform"`
+}
+
+// DowngradeError is returned when an installed hook is newer than the binary installing it
+type DowngradeError struct {
+    Installed string // Version of the installed hook
+    Current   string // Version of the running binary
+}
+
+// Error implements the error interface
+func (e *DowngradeError) Error() string {
+    return fmt.Sprintf("installed hook is pre-push %s, newer than %s", e.Installed, e.Current)
+}
+
+// buildInfoTimeout limits how long an installed binary may take to report its build
+const buildInfoTimeout = 5 * time.Second
+
+// ReadBuildInfo runs a pre-push binary with --build-info. Binaries built
+// before --build-info existed are asked for their version with -V.
+func ReadBuildInfo(path string) (BuildInfo, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), buildInfoTimeout)
+    defer cancel()
+
+    var info BuildInfo
+    output, err := exec.CommandContext(ctx, path, "--build-info").Output()
+    if err == nil && json.Unmarshal(output, &info) == nil && info.Version != "" {
+        return info, nil
+    }
+
+    output, err = exec.CommandContext(ctx, path, "-V").Output()
+    if err != nil {
+        return info, fmt.Errorf("failed to read version of %s: %w", path, err)
+    }
+    info.Version = strings.TrimSpace(string(output))
+    if info.Version == "" {
+        return info, fmt.Errorf("failed to read version of %s", path)
+    }
+    return info, nil
+}
+
+// InstalledVersion returns the pre-push version of the installed hook, or an
+// empty string if no pre-push hook is installed or its version is unknown
+func (i *Installer) InstalledVersion() string {
+    status, err := i.Status()
+    if err != nil {
+        return ""
+    }
+
+    switch status.Kind {
+    case HookShim:
+        return status.Shim.Version
+    case HookCopy:
+        info, err := ReadBuildInfo(i.hookPath)
+        if err != nil {
+            return ""
+        }
+        return info.Version
+    }
+    return ""
+}
+
+// checkDowngrade returns a DowngradeError if the installed hook is a newer
+// pre-push than the current version. Versions that are not semantic versions
+// never count as a downgrade.
+func (i *Installer) checkDowngrade(current string) error {
+    installed := i.InstalledVersion()
+    if installed == "" {
+        return nil
+    }
+
+    result, err := version.New().CompareVersions(installed, current)
+    if err == nil && result > 0 {
+        return &DowngradeError{Installed: installed, Current: current}
+    }
+    return nil
+}
@@ -0,0 +1,79 @@
+package install
+
+import (
+    "errors"
+    "path/filepath"
+    "testing"
+)
+
+// TestCheckDowngrade tests that only a newer installed pre-push hook is a downgrade
+func TestCheckDowngrade(t *testing.T) {
+    tests := []struct {
+        name      string
+        hook      string // Installed hook, empty for none
+        current   string
+        downgrade bool
+    }{
+        {name: "no hook", current: "1.0.0"},
+        {name: "newer shim", hook: Shim{Version: "1.2.0"}.Script(), current: "1.1.0", downgrade: true},
+        {name: "same shim", hook: Shim{Version: "1.2.0"}.Script(), current: "1.2.0"},
+        {name: "older shim", hook: Shim{Version: "1.0.0"}.Script(), current: "1.2.0"},
+        {name: "release after prerelease", hook: Shim{Version: "1.2.0-rc.1"}.Script(), current: "1.2.0"},
+        {name: "prerelease of installed", hook: Shim{Version: "1.2.0"}.Script(), current: "1.2.0-rc.1", downgrade: true},
+        {name: "not semver", hook: Shim{Version: "dev"}.Script(), current: "1.0.0"},
+        {name: "unknown current", hook: Shim{Version: "1.2.0"}.Script(), current: "unknown"},
+        {
+            name:      "newer copy",
+            hook:      "#!/bin/sh\n# hook generated by pre-push\necho '{\"name\":\"pre-push\",\"version\":\"2.0.0\"}'\n",
+            current:   "1.0.0",
+            downgrade: true,
+        },
+        {
+            name:    "copy without build info",
+            hook:    "#!/bin/sh\n# hook generated by pre-push\n[ \"$1\" = -V ] && echo 0.5.0\n",
+            current: "1.0.0",
+        },
+        {name: "foreign", hook: "#!/bin/sh\necho 9.9.9\n", current: "1.0.0"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            installer := &Installer{hookPath: filepath.Join(t.TempDir(), "pre-push")}
+            if test.hook != "" {
+                writeHook(t, installer.hookPath, test.hook)
+            }
+
+            err := installer.checkDowngrade(test.current)
+            var downgrade *DowngradeError
+            if errors.As(err, &downgrade) != test.downgrade {
+                t.Errorf("Expected downgrade %v, got %v", test.downgrade, err)
+            }
+            if !test.downgrade && err != nil {
+                t.Errorf("Expected no error, got %v", err)
+            }
+        })
+    }
+}
+
+// TestInstallShimDowngrade tests that a newer hook is only replaced when forced
+func TestInstallShimDowngrade(t *testing.T) {
+    installer := &Installer{hookPath: filepath.Join(t.TempDir(), "pre-push")}
+    if _, err := installer.InstallShim(Shim{Version: "2.0.0"}, false); err != nil {
+        t.Fatalf("Failed to install shim: %v", err)
+    }
+
+    if _, err := installer.InstallShim(Shim{Version: "1.0.0"}, false); err == nil {
+        t.Error("Expected an older shim not to replace a newer one")
+    }
+    if installer.InstalledVersion() != "2.0.0" {
+        t.Errorf("Expected installed version 2.0.0, got %s", installer.InstalledVersion())
+    }
+
+    written, err := installer.InstallShim(Shim{Version: "1.0.0"}, true)
+    if err != nil || !written {
+        t.Fatalf("Expected a forced install to write the shim, got %v (%v)", written, err)
+    }
+    if installer.InstalledVersion() != "1.0.0" {
+        t.Errorf("Expected installed version 1.0.0, got %s", installer.InstalledVersion())
+    }
+}
@@ -0,0 +1,98 @@
+package install
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+)
+
+// localHookSuffix is appended to the hook path for a backed up foreign hook
+const localHookSuffix = ".local"
+
+// prePushMarkers identify hooks installed by pre-push: the module path is
+// embedded in binary copies, the other markers in generated scripts
+var prePushMarkers = [][]byte{
+    []byte("github.com/AlexBurnes/pre-push"),
+    []byte(shimMarker),
+    []byte("hook generated by pre-push"),
+}
+
+// LocalHookPath returns the path a foreign hook is backed up to
+func LocalHookPath(hookPath string) string {
+    return hookPath + localHookSuffix
+}
+
+// IsPrePushHook checks if the hook at path was installed by pre-push
+func IsPrePushHook(path string) (bool, error) {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return false, err
+    }
+
+    for _, marker := range prePushMarkers {
+        if bytes.Contains(content, marker) {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// BackupForeignHook moves a hook not installed by pre-push to the local hook
+// path so it can be chained; it returns true if a hook was moved
+func BackupForeignHook(hookPath string) (bool, error) {
+    if _, err := os.Stat(hookPath); os.IsNotExist(err) {
+        return false, nil
+    }
+
+    ours, err := IsPrePushHook(hookPath)
+    if err != nil {
+        return false, fmt.Errorf("failed to read existing hook: %w", err)
+    }
+    if ours {
+        return false, nil
+    }
+
+    localPath := LocalHookPath(hookPath)
+    if _, err := os.Stat(localPath); err == nil {
+        return false, fmt.Errorf("existing hook %s cannot be backed up, %s already exists", hookPath, localPath)
+    }
+
+    if err := os.Rename(hookPath, localPath); err != nil {
+        return false, fmt.Errorf("failed to back up existing hook: %w", err)
+    }
+    return true, nil
+}
+
+// RemoveHook removes a hook installed by pre-push and restores a backed up
+// foreign hook; it returns true if a foreign hook was restored
+func RemoveHook(hookPath string) (bool, error) {
+    if _, err := os.Stat(hookPath); err == nil {
+        ours, err := IsPrePushHook(hookPath)
+        if err != nil {
+            return false, fmt.Errorf("failed to read hook: %w", err)
+        }
+        if !ours {
+            return false, fmt.Errorf("hook %s was not installed by pre-push", hookPath)
+        }
+        if err := os.Remove(hookPath); err != nil {
+            return false, fmt.Errorf("failed to remove hook: %w", err)
+        }
+    } else if !os.IsNotExist(err) {
+        return false, err
+    }
+
+    localPath := LocalHookPath(hookPath)
+    if _, err := os.Stat(localPath); os.IsNotExist(err) {
+        return false, nil
+    }
+    if err := os.Rename(localPath, hookPath); err != nil {
+        return false, fmt.Errorf("failed to restore %s: %w", localPath, err)
+    }
+    return true, nil
+}
+
+// HasLocalHook checks if an executable backed up foreign hook exists
+func HasLocalHook(hookPath string) bool {
+    info, err := os.Stat(LocalHookPath(hookPath))
+    return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
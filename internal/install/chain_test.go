@@ -0,0 +1,127 @@
+package install
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "testing"
+)
+
+// initRepo creates an empty repository and changes into it
+func initRepo(t *testing.T) {
+    tempDir := t.TempDir()
+    oldDir, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get current dir: %v", err)
+    }
+    t.Cleanup(func() { os.Chdir(oldDir) })
+    if err := os.Chdir(tempDir); err != nil {
+        t.Fatalf("Failed to change to temp dir: %v", err)
+    }
+
+    if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+        t.Fatalf("Failed to init repository: %v: %s", err, output)
+    }
+}
+
+// writeHook writes an executable hook script
+func writeHook(t *testing.T, path, content string) {
+    if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+        t.Fatalf("Failed to write hook: %v", err)
+    }
+}
+
+// TestBackupAndRemoveHook tests that a foreign hook is backed up on install
+// and restored on uninstall
+func TestBackupAndRemoveHook(t *testing.T) {
+    const foreign = "#!/bin/sh\nexec lint\n"
+
+    tests := []struct {
+        name     string
+        existing string // Hook installed before pre-push, empty for none
+        backedUp bool
+        restored bool
+    }{
+        {name: "no hook"},
+        {name: "foreign hook", existing: foreign, backedUp: true, restored: true},
+        {name: "pre-push shim", existing: Shim{Version: "0.9.0"}.Script()},
+        {name: "generated script", existing: "#!/bin/sh\n# hook generated by pre-push\n"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            initRepo(t)
+            installer, err := New(context.Background(), "pre-push")
+            if err != nil {
+                t.Fatalf("Failed to create installer: %v", err)
+            }
+            hookPath := installer.HookPath()
+            if test.existing != "" {
+                writeHook(t, hookPath, test.existing)
+            }
+
+            backedUp, err := installer.BackupForeignHook()
+            if err != nil || backedUp != test.backedUp {
+                t.Fatalf("Expected backed up %v, got %v (%v)", test.backedUp, backedUp, err)
+            }
+            if _, err := installer.InstallShim(Shim{Version: "1.0.0"}, true); err != nil {
+                t.Fatalf("Failed to install shim: %v", err)
+            }
+            if status, _ := installer.Status(); status.Kind != HookShim || (status.LocalHook != "") != test.backedUp {
+                t.Errorf("Expected a shim chaining the backed up hook %v, got %+v", test.backedUp, status)
+            }
+
+            restored, err := installer.Uninstall()
+            if err != nil || restored != test.restored {
+                t.Fatalf("Expected restored %v, got %v (%v)", test.restored, restored, err)
+            }
+            content, err := os.ReadFile(hookPath)
+            if test.restored {
+                if string(content) != foreign {
+                    t.Errorf("Expected the foreign hook to be restored, got %q (%v)", content, err)
+                }
+            } else if !os.IsNotExist(err) {
+                t.Errorf("Expected no hook after uninstall, got %q (%v)", content, err)
+            }
+            if _, err := os.Stat(LocalHookPath(hookPath)); !os.IsNotExist(err) {
+                t.Errorf("Expected no backed up hook after uninstall, got %v", err)
+            }
+        })
+    }
+}
+
+// TestBackupForeignHookConflict tests that an existing backup is never overwritten
+func TestBackupForeignHookConflict(t *testing.T) {
+    initRepo(t)
+    hookPath, err := HookPath(context.Background(), "pre-commit")
+    if err != nil {
+        t.Fatalf("Failed to get hook path: %v", err)
+    }
+    writeHook(t, hookPath, "#!/bin/sh\nexec lint\n")
+    writeHook(t, LocalHookPath(hookPath), "#!/bin/sh\nexec old-lint\n")
+
+    if _, err := BackupForeignHook(hookPath); err == nil {
+        t.Error("Expected error when the backup path already exists")
+    }
+    content, _ := os.ReadFile(LocalHookPath(hookPath))
+    if string(content) != "#!/bin/sh\nexec old-lint\n" {
+        t.Errorf("Expected existing backup to be kept, got %q", content)
+    }
+}
+
+// TestRemoveForeignHook tests that a hook not installed by pre-push is not removed
+func TestRemoveForeignHook(t *testing.T) {
+    initRepo(t)
+    hookPath, err := HookPath(context.Background(), "pre-push")
+    if err != nil {
+        t.Fatalf("Failed to get hook path: %v", err)
+    }
+    writeHook(t, hookPath, "#!/bin/sh\nexec lint\n")
+
+    if _, err := RemoveHook(hookPath); err == nil {
+        t.Error("Expected error when removing a foreign hook")
+    }
+    if _, err := os.Stat(hookPath); err != nil {
+        t.Errorf("Expected foreign hook to be kept, got %v", err)
+    }
+}
@@ -0,0 +1,151 @@
+package install
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// GlobalTarget selects how a global install makes Git run the hook
+type GlobalTarget string
+
+const (
+    // GlobalTemplate installs the hook into the init.templateDir template, so
+    // every repository created by git init or git clone gets a copy
+    GlobalTemplate GlobalTarget = "template"
+
+    // GlobalHooksPath installs the hook into the global core.hooksPath, so
+    // every repository runs it without its own copy
+    GlobalHooksPath GlobalTarget = "hooks-path"
+)
+
+// ParseGlobalTarget parses a global install target
+func ParseGlobalTarget(s string) (GlobalTarget, error) {
+    switch GlobalTarget(s) {
+    case GlobalTemplate, GlobalHooksPath:
+        return GlobalTarget(s), nil
+    default:
+        return "", fmt.Errorf("unknown global target: %s (must be 'template' or 'hooks-path')", s)
+    }
+}
+
+// configKey returns the global Git configuration key of the target
+func (t GlobalTarget) configKey() string {
+    if t == GlobalHooksPath {
+        return "core.hooksPath"
+    }
+    return "init.templateDir"
+}
+
+// hooksDir returns the hooks directory for a configured directory of the target
+func (t GlobalTarget) hooksDir(dir string) string {
+    if t == GlobalHooksPath {
+        return dir
+    }
+    return filepath.Join(dir, "hooks")
+}
+
+// Global manages a hook installed for all repositories of the user
+type Global struct {
+    Target     GlobalTarget // How Git finds the hook
+    Dir        string       // Directory configured in Git for the target
+    Configured bool         // Dir was already set in the global Git configuration
+    Owned      bool         // Dir is the default directory of pre-push
+}
+
+// NewGlobal resolves the global install of a target. If the global Git
+// configuration already sets the target directory, the hook is installed
+// there; otherwise defaultDir is used and configured on install.
+func NewGlobal(ctx context.Context, target GlobalTarget, defaultDir string) (*Global, error) {
+    global := &Global{Target: target, Dir: defaultDir}
+
+    value, err := globalConfig(ctx, target.configKey())
+    if err != nil {
+        return nil, err
+    }
+    if value != "" {
+        global.Dir = expandHome(value)
+        global.Configured = true
+    }
+    global.Owned = filepath.Clean(global.Dir) == filepath.Clean(defaultDir)
+
+    return global, nil
+}
+
+// HookPath returns the path of the named hook of the global install
+func (g *Global) HookPath(hookName string) string {
+    return filepath.Join(g.Target.hooksDir(g.Dir), hookName)
+}
+
+// Installer returns an installer for the named hook of the global install
+func (g *Global) Installer(hookName string) (*Installer, error) {
+    binaryPath, err := currentBinaryPath()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get current binary path: %w", err)
+    }
+    return &Installer{hookPath: g.HookPath(hookName), binaryPath: binaryPath}, nil
+}
+
+// Configure points the global Git configuration at the directory
+func (g *Global) Configure(ctx context.Context) error {
+    if g.Configured {
+        return nil
+    }
+    if err := runGitConfig(ctx, "--global", g.Target.configKey(), g.Dir); err != nil {
+        return fmt.Errorf("failed to set %s: %w", g.Target.configKey(), err)
+    }
+    g.Configured = true
+    return nil
+}
+
+// Unconfigure removes the global Git configuration if it points at the
+// default directory of pre-push; directories set by the user are kept
+func (g *Global) Unconfigure(ctx context.Context) error {
+    if !g.Configured || !g.Owned {
+        return nil
+    }
+    if err := runGitConfig(ctx, "--global", "--unset", g.Target.configKey()); err != nil {
+        return fmt.Errorf("failed to unset %s: %w", g.Target.configKey(), err)
+    }
+    g.Configured = false
+    return nil
+}
+
+// globalConfig returns a value of the global Git configuration, empty if it is not set
+func globalConfig(ctx context.Context, key string) (string, error) {
+    output, err := exec.CommandContext(ctx, "git", "config", "--global", "--get", key).Output()
+    if err != nil {
+        // Exit code 1 means the key is not set
+        var exitErr *exec.ExitError
+        if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+            return "", nil
+        }
+        return "", fmt.Errorf("failed to read %s: %w", key, err)
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// runGitConfig runs git config with the arguments
+func runGitConfig(ctx context.Context, args ...string) error {
+    output, err := exec.CommandContext(ctx, "git", append([]string{"config"}, args...)...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// expandHome expands a leading ~ the way Git does for path settings
+func expandHome(path string) string {
+    if path != "~" && !strings.HasPrefix(path, "~/") {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return path
+    }
+    return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,136 @@
+package install
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// isolateGlobalConfig points the global Git configuration at an empty file
+func isolateGlobalConfig(t *testing.T) string {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+    t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+    return home
+}
+
+// globalValue returns a value of the global Git configuration
+func globalValue(t *testing.T, key string) string {
+    output, _ := exec.Command("git", "config", "--global", "--get", key).Output()
+    return strings.TrimSpace(string(output))
+}
+
+// TestGlobalInstall tests installing and uninstalling a global hook
+func TestGlobalInstall(t *testing.T) {
+    tests := []struct {
+        name   string
+        target GlobalTarget
+        hooks  string // Hooks directory relative to the configured directory
+    }{
+        {name: "template", target: GlobalTemplate, hooks: "hooks"},
+        {name: "hooks path", target: GlobalHooksPath, hooks: ""},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            home := isolateGlobalConfig(t)
+            ctx := context.Background()
+            defaultDir := filepath.Join(home, "pre-push", string(test.target))
+
+            global, err := NewGlobal(ctx, test.target, defaultDir)
+            if err != nil {
+                t.Fatalf("Failed to resolve global install: %v", err)
+            }
+            if global.Configured || !global.Owned || global.Dir != defaultDir {
+                t.Fatalf("Expected an unconfigured default directory, got %+v", global)
+            }
+
+            installer, err := global.Installer("pre-push")
+            if err != nil {
+                t.Fatalf("Failed to create installer: %v", err)
+            }
+            if installer.HookPath() != filepath.Join(defaultDir, test.hooks, "pre-push") {
+                t.Errorf("Unexpected hook path %s", installer.HookPath())
+            }
+            if _, err := installer.InstallShim(Shim{Version: "1.0.0"}, false); err != nil {
+                t.Fatalf("Failed to install shim: %v", err)
+            }
+            if err := global.Configure(ctx); err != nil {
+                t.Fatalf("Failed to configure: %v", err)
+            }
+            if value := globalValue(t, test.target.configKey()); value != defaultDir {
+                t.Errorf("Expected %s to be %s, got %q", test.target.configKey(), defaultDir, value)
+            }
+
+            global, err = NewGlobal(ctx, test.target, defaultDir)
+            if err != nil || !global.Configured || !global.Owned {
+                t.Fatalf("Expected the configured default directory, got %+v (%v)", global, err)
+            }
+            if _, err := installer.Uninstall(); err != nil {
+                t.Fatalf("Failed to uninstall: %v", err)
+            }
+            if err := global.Unconfigure(ctx); err != nil {
+                t.Fatalf("Failed to unconfigure: %v", err)
+            }
+            if _, err := os.Stat(installer.HookPath()); !os.IsNotExist(err) {
+                t.Errorf("Expected hook to be removed, got %v", err)
+            }
+            if value := globalValue(t, test.target.configKey()); value != "" {
+                t.Errorf("Expected %s to be unset, got %q", test.target.configKey(), value)
+            }
+        })
+    }
+}
+
+// TestGlobalInstallUserDir tests that a directory configured by the user is
+// used and kept on uninstall
+func TestGlobalInstallUserDir(t *testing.T) {
+    home := isolateGlobalConfig(t)
+    ctx := context.Background()
+    if err := runGitConfig(ctx, "--global", "core.hooksPath", "~/hooks"); err != nil {
+        t.Fatalf("Failed to set core.hooksPath: %v", err)
+    }
+
+    global, err := NewGlobal(ctx, GlobalHooksPath, filepath.Join(home, "pre-push"))
+    if err != nil {
+        t.Fatalf("Failed to resolve global install: %v", err)
+    }
+    if !global.Configured || global.Owned || global.Dir != filepath.Join(home, "hooks") {
+        t.Fatalf("Expected the user directory with ~ expanded, got %+v", global)
+    }
+    if global.HookPath("pre-commit") != filepath.Join(home, "hooks", "pre-commit") {
+        t.Errorf("Unexpected hook path %s", global.HookPath("pre-commit"))
+    }
+
+    if err := global.Unconfigure(ctx); err != nil {
+        t.Fatalf("Failed to unconfigure: %v", err)
+    }
+    if value := globalValue(t, "core.hooksPath"); value != "~/hooks" {
+        t.Errorf("Expected core.hooksPath set by the user to be kept, got %q", value)
+    }
+}
+
+// TestParseGlobalTarget tests parsing of global install targets
+func TestParseGlobalTarget(t *testing.T) {
+    tests := []struct {
+        input string
+        want  GlobalTarget
+        err   bool
+    }{
+        {"template", GlobalTemplate, false},
+        {"hooks-path", GlobalHooksPath, false},
+        {"hooks", "", true},
+        {"", "", true},
+    }
+
+    for _, test := range tests {
+        target, err := ParseGlobalTarget(test.input)
+        if target != test.want || (err != nil) != test.err {
+            t.Errorf("ParseGlobalTarget(%q) = %q, %v", test.input, target, err)
+        }
+    }
+}
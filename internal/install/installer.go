@@ -3,187 +3,284 @@ package install
 
 import (
     "context"
-    "crypto/md5"
     "fmt"
     "io"
     "os"
     "path/filepath"
+    "strings"
+
+    "github.com/AlexBurnes/pre-push/internal/repo"
 )
 
 // Installer handles installation and management of Git hooks
 type Installer struct {
     hookPath string
     binaryPath string
-    binaryMD5 string
 }
 
-// New creates a new installer
-func New() *Installer {
-    return &Installer{
-        hookPath: ".git/hooks/pre-push",
-        binaryPath: "", // Will be set to the current binary path
-        binaryMD5: "", // Will be set to the current binary MD5
+// Hooks are the Git hooks pre-push can be installed as. Each hook runs the
+// stage of the same name.
+var Hooks = []string{"pre-commit", "commit-msg", "pre-push", "post-merge"}
+
+// ParseHooks validates a list of hook names
+func ParseHooks(names []string) ([]string, error) {
+    var hooks []string
+    for _, name := range names {
+        name = strings.TrimSpace(name)
+        if !IsHook(name) {
+            return nil, fmt.Errorf("unsupported hook: %s (must be one of %s)", name, strings.Join(Hooks, ", "))
+        }
+        hooks = append(hooks, name)
     }
+    if len(hooks) == 0 {
+        return nil, fmt.Errorf("no hooks given")
+    }
+    return hooks, nil
 }
 
-// Install installs or updates the pre-push hook
-func (i *Installer) Install(ctx context.Context) error {
-    // Check if we're in a git repository
-    if !i.isGitRepository() {
-        return fmt.Errorf("not in a git repository")
+// IsHook checks if pre-push can be installed as the named hook
+func IsHook(name string) bool {
+    for _, hook := range Hooks {
+        if hook == name {
+            return true
+        }
     }
-    
-    // Get the current binary path
-    binaryPath, err := i.getCurrentBinaryPath()
+    return false
+}
+
+// New creates an installer for the named hook of the current repository.
+// The hook is located in the directory Git runs hooks from, which follows
+// core.hooksPath, GIT_DIR, linked worktrees and submodules.
+func New(ctx context.Context, hookName string) (*Installer, error) {
+    hookPath, err := HookPath(ctx, hookName)
     if err != nil {
-        return fmt.Errorf("failed to get current binary path: %w", err)
+        return nil, err
     }
-    i.binaryPath = binaryPath
-    
-    // Calculate MD5 of the current binary
-    binaryMD5, err := i.calculateBinaryMD5()
+
+    binaryPath, err := currentBinaryPath()
     if err != nil {
-        return fmt.Errorf("failed to calculate binary MD5: %w", err)
-    }
-    i.binaryMD5 = binaryMD5
-    
-    // Check if hook already exists and is up to date
-    if i.isHookUpToDate() {
-        fmt.Println("Pre-push hook is already up to date")
-        return nil
-    }
-    
-    // Create the hook directory if it doesn't exist
-    hookDir := filepath.Dir(i.hookPath)
-    if err := os.MkdirAll(hookDir, 0755); err != nil {
-        return fmt.Errorf("failed to create hook directory: %w", err)
-    }
-    
-    // Create the hook script
-    if err := i.createHookScript(); err != nil {
-        return fmt.Errorf("failed to create hook script: %w", err)
-    }
-    
-    // Make the hook executable
-    if err := os.Chmod(i.hookPath, 0755); err != nil {
-        return fmt.Errorf("failed to make hook executable: %w", err)
+        return nil, fmt.Errorf("failed to get current binary path: %w", err)
     }
-    
-    fmt.Println("Pre-push hook installed successfully")
-    return nil
+
+    return &Installer{
+        hookPath: hookPath,
+        binaryPath: binaryPath,
+    }, nil
 }
 
-// Uninstall removes the pre-push hook
-func (i *Installer) Uninstall(ctx context.Context) error {
-    if !i.isGitRepository() {
-        return fmt.Errorf("not in a git repository")
-    }
-    
-    if _, err := os.Stat(i.hookPath); os.IsNotExist(err) {
-        fmt.Println("Pre-push hook is not installed")
-        return nil
-    }
-    
-    if err := os.Remove(i.hookPath); err != nil {
-        return fmt.Errorf("failed to remove hook: %w", err)
+// HookPath returns the path of the named hook of the current repository
+func HookPath(ctx context.Context, hookName string) (string, error) {
+    hooksDir, err := repo.HooksDir(ctx)
+    if err != nil {
+        return "", err
     }
-    
-    fmt.Println("Pre-push hook uninstalled successfully")
-    return nil
+    return filepath.Join(hooksDir, hookName), nil
 }
 
-// isGitRepository checks if the current directory is a git repository
-func (i *Installer) isGitRepository() bool {
-    _, err := os.Stat(".git")
-    return err == nil
+// HookPath returns the path of the hook
+func (i *Installer) HookPath() string {
+    return i.hookPath
 }
 
-// getCurrentBinaryPath gets the path to the current binary
-func (i *Installer) getCurrentBinaryPath() (string, error) {
-    // Get the absolute path to the current binary
-    binaryPath, err := os.Executable()
+// BinaryPath returns the path of the running binary
+func (i *Installer) BinaryPath() string {
+    return i.binaryPath
+}
+
+// IsUpToDate checks if the hook is a copy of the running binary
+func (i *Installer) IsUpToDate() (bool, error) {
+    if _, err := os.Stat(i.hookPath); os.IsNotExist(err) {
+        return false, nil
+    }
+
+    binaryDigest, err := FileSHA256(i.binaryPath)
     if err != nil {
-        return "", err
+        return false, fmt.Errorf("failed to calculate binary digest: %w", err)
     }
-    
-    // Resolve any symlinks
-    resolvedPath, err := filepath.EvalSymlinks(binaryPath)
+
+    hookDigest, err := FileSHA256(i.hookPath)
     if err != nil {
-        return "", err
+        return false, fmt.Errorf("failed to calculate hook digest: %w", err)
     }
-    
-    return resolvedPath, nil
+
+    return binaryDigest == hookDigest, nil
 }
 
-// calculateBinaryMD5 calculates the MD5 hash of the current binary
-func (i *Installer) calculateBinaryMD5() (string, error) {
-    file, err := os.Open(i.binaryPath)
+// Install copies the running binary to the hook unless it is up to date; it
+// returns true if the hook was written. An installed pre-push hook of a newer
+// version than current is not replaced unless force is set; for equal
+// versions the sha256 digest decides. A hook installed by another tool is
+// moved to the local hook path first.
+func (i *Installer) Install(current string, force bool) (bool, error) {
+    upToDate, err := i.IsUpToDate()
     if err != nil {
-        return "", err
+        return false, err
     }
-    defer file.Close()
-    
-    hash := md5.New()
-    if _, err := io.Copy(hash, file); err != nil {
-        return "", err
+    if upToDate {
+        return false, nil
+    }
+
+    if !force {
+        if err := i.checkDowngrade(current); err != nil {
+            return false, err
+        }
+    }
+
+    if err := i.prepare(); err != nil {
+        return false, err
     }
-    
-    return fmt.Sprintf("%x", hash.Sum(nil)), nil
+
+    if err := i.copyBinary(); err != nil {
+        return false, err
+    }
+
+    return true, nil
 }
 
-// isHookUpToDate checks if the hook is already installed and up to date
-func (i *Installer) isHookUpToDate() bool {
-    // Check if hook file exists
-    if _, err := os.Stat(i.hookPath); os.IsNotExist(err) {
-        return false
+// InstallShim writes a shim to the hook unless an identical one is installed;
+// it returns true if the hook was written. An installed pre-push hook of a
+// newer version than the shim is not replaced unless force is set. A hook
+// installed by another tool is moved to the local hook path first.
+func (i *Installer) InstallShim(shim Shim, force bool) (bool, error) {
+    if !force {
+        if err := i.checkDowngrade(shim.Version); err != nil {
+            return false, err
+        }
     }
-    
-    // Calculate MD5 of the hook file
-    hookMD5, err := i.calculateHookMD5()
-    if err != nil {
-        return false
+
+    if err := i.prepare(); err != nil {
+        return false, err
     }
-    
-    // Compare with current binary MD5
-    return hookMD5 == i.binaryMD5
+    return WriteShim(i.hookPath, shim)
 }
 
-// calculateHookMD5 calculates the MD5 hash of the hook file
-func (i *Installer) calculateHookMD5() (string, error) {
-    file, err := os.Open(i.hookPath)
-    if err != nil {
-        return "", err
+// Uninstall removes the hook and restores a hook backed up on install; it
+// returns true if a backed up hook was restored
+func (i *Installer) Uninstall() (bool, error) {
+    return RemoveHook(i.hookPath)
+}
+
+// BackupForeignHook moves a hook installed by another tool to the local hook
+// path; it returns true if a hook was moved
+func (i *Installer) BackupForeignHook() (bool, error) {
+    return BackupForeignHook(i.hookPath)
+}
+
+// prepare creates the hooks directory and backs up a foreign hook
+func (i *Installer) prepare() error {
+    if err := os.MkdirAll(filepath.Dir(i.hookPath), 0755); err != nil {
+        return fmt.Errorf("failed to create hooks directory: %w", err)
     }
-    defer file.Close()
-    
-    hash := md5.New()
-    if _, err := io.Copy(hash, file); err != nil {
-        return "", err
+
+    if _, err := i.BackupForeignHook(); err != nil {
+        return err
     }
-    
-    return fmt.Sprintf("%x", hash.Sum(nil)), nil
+    return nil
 }
 
-// createHookScript copies the current binary to the hook location
-func (i *Installer) createHookScript() error {
+// copyBinary copies the running binary to the hook location. The copy is
+// renamed into place, so a hook that is running can be replaced.
+func (i *Installer) copyBinary() error {
     // Open the current binary
     sourceFile, err := os.Open(i.binaryPath)
     if err != nil {
         return fmt.Errorf("failed to open current binary: %w", err)
     }
     defer sourceFile.Close()
-    
-    // Create the hook file
-    destFile, err := os.OpenFile(i.hookPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+
+    // Create the hook file next to the hook
+    destFile, err := os.CreateTemp(filepath.Dir(i.hookPath), filepath.Base(i.hookPath)+".tmp-")
     if err != nil {
         return fmt.Errorf("failed to create hook file: %w", err)
     }
-    defer destFile.Close()
-    
+    defer os.Remove(destFile.Name())
+
     // Copy the binary to the hook location
     if _, err := io.Copy(destFile, sourceFile); err != nil {
+        destFile.Close()
+        return fmt.Errorf("failed to copy binary to hook: %w", err)
+    }
+    if err := destFile.Close(); err != nil {
         return fmt.Errorf("failed to copy binary to hook: %w", err)
     }
-    
+
+    if err := os.Chmod(destFile.Name(), 0755); err != nil {
+        return fmt.Errorf("failed to make hook executable: %w", err)
+    }
+    if err := os.Rename(destFile.Name(), i.hookPath); err != nil {
+        return fmt.Errorf("failed to replace hook: %w", err)
+    }
+
     return nil
-}
\ No newline at end of file
+}
+
+// currentBinaryPath gets the path to the current binary with symlinks resolved
+func currentBinaryPath() (string, error) {
+    binaryPath, err := os.Executable()
+    if err != nil {
+        return "", err
+    }
+
+    return filepath.EvalSymlinks(binaryPath)
+}
+
+// HookKind describes what is installed at the hook path
+type HookKind string
+
+const (
+    // HookNone means no hook is installed
+    HookNone HookKind = "none"
+
+    // HookCopy is a copy of the pre-push binary
+    HookCopy HookKind = "copy"
+
+    // HookShim is a shim script that runs an installed pre-push binary
+    HookShim HookKind = "shim"
+
+    // HookForeign is a hook installed by another tool
+    HookForeign HookKind = "foreign"
+)
+
+// HookStatus describes the installed hook
+type HookStatus struct {
+    Path      string   // Path of the hook
+    Kind      HookKind // What is installed at the path
+    Shim      Shim     // Shim header, set for shim hooks
+    Binary    string   // Binary the hook runs, empty if it cannot be found
+    LocalHook string   // Path of a chained hook, empty if there is none
+}
+
+// Status inspects the installed hook
+func (i *Installer) Status() (HookStatus, error) {
+    status := HookStatus{Path: i.hookPath, Kind: HookNone}
+    if HasLocalHook(i.hookPath) {
+        status.LocalHook = LocalHookPath(i.hookPath)
+    }
+
+    content, err := os.ReadFile(i.hookPath)
+    if os.IsNotExist(err) {
+        return status, nil
+    }
+    if err != nil {
+        return status, fmt.Errorf("failed to read hook: %w", err)
+    }
+
+    if shim, ok := ParseShim(content); ok {
+        status.Kind = HookShim
+        status.Shim = shim
+        status.Binary = shim.ResolveBinary()
+        return status, nil
+    }
+
+    ours, err := IsPrePushHook(i.hookPath)
+    if err != nil {
+        return status, fmt.Errorf("failed to read hook: %w", err)
+    }
+    if ours {
+        status.Kind = HookCopy
+        status.Binary = i.hookPath
+    } else {
+        status.Kind = HookForeign
+    }
+    return status, nil
+}
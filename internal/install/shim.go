@@ -0,0 +1,172 @@
+package install
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// Mode selects how the hook is installed
+type Mode string
+
+const (
+    // ModeCopy copies the pre-push binary into the hooks directory
+    ModeCopy Mode = "copy"
+
+    // ModeShim writes a small shell script that runs an installed pre-push binary
+    ModeShim Mode = "shim"
+)
+
+// shimMarker identifies hook scripts written by pre-push
+const shimMarker = "# pre-push-shim"
+
+// ParseMode parses an install mode
+func ParseMode(s string) (Mode, error) {
+    switch Mode(s) {
+    case ModeCopy, ModeShim:
+        return Mode(s), nil
+    default:
+        return "", fmt.Errorf("unknown install mode: %s (must be 'copy' or 'shim')", s)
+    }
+}
+
+// Shim describes a hook script that runs an installed pre-push binary
+type Shim struct {
+    Version      string // Version of pre-push that wrote the shim
+    BinaryPath   string // Pinned binary path, empty to use pre-push from PATH
+    BinarySHA256 string // Digest of the pinned binary at install time
+    MinVersion   string // Minimum pre-push version the shim accepts
+    Hook         string // Hook the shim is installed as, empty for pre-push
+}
+
+// Script returns the POSIX shell script of the shim
+func (s Shim) Script() string {
+    var b strings.Builder
+
+    b.WriteString("#!/bin/sh\n")
+    fmt.Fprintf(&b, "%s version=%s\n", shimMarker, s.Version)
+    fmt.Fprintf(&b, "# binary=%s\n", s.BinaryPath)
+    fmt.Fprintf(&b, "# sha256=%s\n", s.BinarySHA256)
+    fmt.Fprintf(&b, "# min-version=%s\n", s.MinVersion)
+    b.WriteString("# Generated by 'pre-push install --mode=shim', do not edit.\n\n")
+
+    // Prefer the pinned binary and fall back to PATH
+    fmt.Fprintf(&b, "PRE_PUSH_BIN=%s\n", shellQuote(s.BinaryPath))
+    b.WriteString(`if [ -z "$PRE_PUSH_BIN" ] || [ ! -x "$PRE_PUSH_BIN" ]; then
+    PRE_PUSH_BIN=$(command -v pre-push 2>/dev/null) || PRE_PUSH_BIN=
+fi
+if [ -z "$PRE_PUSH_BIN" ]; then
+    echo "pre-push: binary not found, install pre-push and run 'pre-push install' again" >&2
+    exit 1
+fi
+`)
+
+    b.WriteString("exec \"$PRE_PUSH_BIN\" hook")
+    if s.Hook != "" && s.Hook != "pre-push" {
+        fmt.Fprintf(&b, " --name %s", s.Hook)
+    }
+    if s.MinVersion != "" {
+        fmt.Fprintf(&b, " --min-version %s", shellQuote(s.MinVersion))
+    }
+    b.WriteString(" \"$@\"\n")
+
+    return b.String()
+}
+
+// ResolveBinary returns the binary the shim runs: the pinned binary if it is
+// executable, otherwise pre-push from PATH; empty if neither is found
+func (s Shim) ResolveBinary() string {
+    if s.BinaryPath != "" {
+        if info, err := os.Stat(s.BinaryPath); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+            return s.BinaryPath
+        }
+    }
+    if path, err := exec.LookPath("pre-push"); err == nil {
+        return path
+    }
+    return ""
+}
+
+// ParseShim reads the shim header of a hook script; ok is false if the
+// content is not a shim written by pre-push
+func ParseShim(content []byte) (shim Shim, ok bool) {
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    for scanner.Scan() {
+        line := scanner.Text()
+        if !strings.HasPrefix(line, "#") {
+            break
+        }
+        if strings.HasPrefix(line, shimMarker+" ") {
+            ok = true
+        }
+
+        key, value, found := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, shimMarker)), "=")
+        if !found {
+            continue
+        }
+        switch strings.TrimPrefix(key, "# ") {
+        case "version":
+            shim.Version = value
+        case "binary":
+            shim.BinaryPath = value
+        case "sha256":
+            shim.BinarySHA256 = value
+        case "min-version":
+            shim.MinVersion = value
+        }
+    }
+    return shim, ok
+}
+
+// FileSHA256 calculates the sha256 digest of a file
+func FileSHA256(path string) (string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+
+    hash := sha256.New()
+    if _, err := io.Copy(hash, file); err != nil {
+        return "", err
+    }
+
+    return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ContentSHA256 calculates the sha256 digest of content
+func ContentSHA256(content []byte) string {
+    sum := sha256.Sum256(content)
+    return hex.EncodeToString(sum[:])
+}
+
+// WriteShim writes a shim to the hook path unless an identical one is already
+// installed; it returns true if the hook was written
+func WriteShim(hookPath string, shim Shim) (bool, error) {
+    script := []byte(shim.Script())
+
+    if installed, err := FileSHA256(hookPath); err == nil && installed == ContentSHA256(script) {
+        return false, nil
+    }
+
+    if err := os.WriteFile(hookPath, script, 0755); err != nil {
+        return false, fmt.Errorf("failed to write hook shim: %w", err)
+    }
+    // WriteFile keeps the mode of an existing file
+    if err := os.Chmod(hookPath, 0755); err != nil {
+        return false, fmt.Errorf("failed to make hook executable: %w", err)
+    }
+
+    return true, nil
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,74 @@
+package install
+
+import (
+    "testing"
+)
+
+// TestParseShim tests that shim headers are read and other hooks are rejected
+func TestParseShim(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        ok      bool
+        want    Shim
+    }{
+        {
+            name: "script",
+            content: Shim{
+                Version:      "1.2.0",
+                BinaryPath:   "/usr/local/bin/pre-push",
+                BinarySHA256: "abc123",
+                MinVersion:   "1.1.0",
+                Hook:         "pre-commit",
+            }.Script(),
+            ok: true,
+            want: Shim{
+                Version:      "1.2.0",
+                BinaryPath:   "/usr/local/bin/pre-push",
+                BinarySHA256: "abc123",
+                MinVersion:   "1.1.0",
+            },
+        },
+        {
+            name:    "path",
+            content: Shim{Version: "1.0.0"}.Script(),
+            ok:      true,
+            want:    Shim{Version: "1.0.0"},
+        },
+        {
+            name:    "header only",
+            content: "#!/bin/sh\n# pre-push-shim version=0.9.0\n# binary=/opt/pre-push\n",
+            ok:      true,
+            want:    Shim{Version: "0.9.0", BinaryPath: "/opt/pre-push"},
+        },
+        {
+            name:    "header ends at first command",
+            content: "#!/bin/sh\n# pre-push-shim version=0.9.0\necho hi\n# binary=/opt/pre-push\n",
+            ok:      true,
+            want:    Shim{Version: "0.9.0"},
+        },
+        {
+            name:    "foreign",
+            content: "#!/bin/sh\n# version=1.0.0\nexec lint\n",
+            ok:      false,
+            want:    Shim{Version: "1.0.0"},
+        },
+        {
+            name:    "empty",
+            content: "",
+            ok:      false,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            shim, ok := ParseShim([]byte(test.content))
+            if ok != test.ok {
+                t.Errorf("Expected ok %v, got %v", test.ok, ok)
+            }
+            if shim != test.want {
+                t.Errorf("Expected %+v, got %+v", test.want, shim)
+            }
+        })
+    }
+}
@@ -0,0 +1,183 @@
+// Package mask redacts the values of secret variables from text shown to
+// the user or stored on disk: debug output, step output, reports and run
+// history.
+//
+// A variable is secret when its name matches one of the patterns, with an
+// env. prefix ignored and case ignored: DefaultPatterns plus the names and
+// patterns of the pre-push settings.
+package mask
+
+import (
+    "bytes"
+    "io"
+    "path"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Mask replaces the value of a secret
+const Mask = "***"
+
+// MinLength is the length below which values are not redacted, short
+// values such as 1 or true would redact unrelated text
+const MinLength = 4
+
+// DefaultPatterns are the names of variables that always hold secrets
+var DefaultPatterns = []string{"*_TOKEN", "*_PASSWORD", "*SECRET*"}
+
+// Masker knows the secret variable names and the secret values seen so
+// far. A nil Masker redacts nothing. A Masker is safe for concurrent use.
+type Masker struct {
+    mu       sync.RWMutex
+    patterns []string
+    values   map[string]bool
+    replacer *strings.Replacer
+}
+
+// New returns a masker for DefaultPatterns and the given names and patterns
+func New(patterns ...string) *Masker {
+    m := &Masker{values: map[string]bool{}}
+    for _, pattern := range append(append([]string(nil), DefaultPatterns...), patterns...) {
+        if pattern = strings.TrimSpace(pattern); pattern != "" {
+            m.patterns = append(m.patterns, strings.ToUpper(strings.TrimPrefix(pattern, "env.")))
+        }
+    }
+    return m
+}
+
+// IsSecret reports whether a variable name matches a secret pattern. The
+// names env.API_TOKEN and API_TOKEN are the same to patterns.
+func (m *Masker) IsSecret(name string) bool {
+    if m == nil {
+        return false
+    }
+    name = strings.ToUpper(strings.TrimPrefix(name, "env."))
+    for _, pattern := range m.patterns {
+        if matched, _ := path.Match(pattern, name); matched {
+            return true
+        }
+    }
+    return false
+}
+
+// Collect records the values of the secret variables
+func (m *Masker) Collect(variables map[string]string) {
+    if m == nil {
+        return
+    }
+    var values []string
+    for name, value := range variables {
+        if m.IsSecret(name) {
+            values = append(values, value)
+        }
+    }
+    m.Add(values...)
+}
+
+// Add records secret values. Values shorter than MinLength are ignored.
+func (m *Masker) Add(values ...string) {
+    if m == nil {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    changed := false
+    for _, value := range values {
+        if len(value) >= MinLength && !m.values[value] {
+            m.values[value] = true
+            changed = true
+        }
+    }
+    if !changed {
+        return
+    }
+
+    // Longer values first, so a value containing another is redacted whole
+    sorted := make([]string, 0, len(m.values))
+    for value := range m.values {
+        sorted = append(sorted, value)
+    }
+    sort.Slice(sorted, func(i, j int) bool {
+        if len(sorted[i]) != len(sorted[j]) {
+            return len(sorted[i]) > len(sorted[j])
+        }
+        return sorted[i] < sorted[j]
+    })
+    pairs := make([]string, 0, 2*len(sorted))
+    for _, value := range sorted {
+        pairs = append(pairs, value, Mask)
+    }
+    m.replacer = strings.NewReplacer(pairs...)
+}
+
+// String redacts the secret values in s
+func (m *Masker) String(s string) string {
+    if m == nil {
+        return s
+    }
+    m.mu.RLock()
+    replacer := m.replacer
+    m.mu.RUnlock()
+    if replacer == nil {
+        return s
+    }
+    return replacer.Replace(s)
+}
+
+// Value returns the value of a variable for display: Mask for a non-empty
+// secret, otherwise the value with secret values redacted
+func (m *Masker) Value(name, value string) string {
+    if m.IsSecret(name) && value != "" {
+        return Mask
+    }
+    return m.String(value)
+}
+
+// Writer returns a writer that redacts secret values line by line before
+// writing to w. Close writes an unterminated last line.
+func (m *Masker) Writer(w io.Writer) io.WriteCloser {
+    return &writer{m: m, w: w}
+}
+
+// writer redacts complete lines, a secret split across writes is kept
+// together by buffering up to the end of the line
+type writer struct {
+    mu  sync.Mutex
+    m   *Masker
+    w   io.Writer
+    buf []byte
+}
+
+// Write buffers p and writes the complete lines redacted
+func (w *writer) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    w.buf = append(w.buf, p...)
+    end := bytes.LastIndexByte(w.buf, '\n')
+    if end == -1 {
+        return len(p), nil
+    }
+    lines := string(w.buf[:end+1])
+    w.buf = append(w.buf[:0], w.buf[end+1:]...)
+    if _, err := io.WriteString(w.w, w.m.String(lines)); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+// Close writes the buffered rest of the output
+func (w *writer) Close() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if len(w.buf) == 0 {
+        return nil
+    }
+    rest := string(w.buf)
+    w.buf = nil
+    _, err := io.WriteString(w.w, w.m.String(rest))
+    return err
+}
@@ -0,0 +1,87 @@
+package mask
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestIsSecret(t *testing.T) {
+    m := New("DEPLOY_KEY", "env.aws_*")
+    cases := map[string]bool{
+        "env.GITHUB_TOKEN":    true,
+        "NPM_TOKEN":           true,
+        "env.DB_PASSWORD":     true,
+        "env.MY_SECRET_VALUE": true,
+        "env.client_secret":   true,
+        "env.DEPLOY_KEY":      true,
+        "deploy_key":          true,
+        "env.AWS_ACCESS_KEY":  true,
+        "env.TOKEN_FILE":      false,
+        "env.PATH":            false,
+        "tag":                 false,
+    }
+    for name, expected := range cases {
+        if got := m.IsSecret(name); got != expected {
+            t.Errorf("IsSecret(%q) = %v, expected %v", name, got, expected)
+        }
+    }
+
+    var none *Masker
+    if none.IsSecret("env.GITHUB_TOKEN") || none.String("abc") != "abc" {
+        t.Error("Expected a nil masker to redact nothing")
+    }
+}
+
+func TestString(t *testing.T) {
+    m := New()
+    m.Collect(map[string]string{
+        "env.GITHUB_TOKEN": "ghp_abc",
+        "env.API_TOKEN":    "ghp_abcdef",
+        "env.DB_PASSWORD":  "yes",
+        "env.HOME":         "/home/user",
+    })
+
+    cases := map[string]string{
+        "token ghp_abc used":     "token *** used",
+        "token ghp_abcdef used":  "token *** used",
+        "password yes is short":  "password yes is short",
+        "home /home/user is not": "home /home/user is not",
+    }
+    for input, expected := range cases {
+        if got := m.String(input); got != expected {
+            t.Errorf("String(%q) = %q, expected %q", input, got, expected)
+        }
+    }
+
+    if got := m.Value("env.GITHUB_TOKEN", "ghp_abc"); got != Mask {
+        t.Errorf("Expected secret value to be masked, got %q", got)
+    }
+    if got := m.Value("url", "https://ghp_abc@example.com"); got != "https://***@example.com" {
+        t.Errorf("Expected secret inside a value to be redacted, got %q", got)
+    }
+    if got := m.Value("env.GITHUB_TOKEN", ""); got != "" {
+        t.Errorf("Expected empty secret to stay empty, got %q", got)
+    }
+}
+
+func TestWriter(t *testing.T) {
+    m := New()
+    m.Add("s3cr3t-value")
+
+    var out bytes.Buffer
+    w := m.Writer(&out)
+    for _, chunk := range []string{"using s3cr", "3t-value\nand ", "s3cr3t-value"} {
+        if _, err := w.Write([]byte(chunk)); err != nil {
+            t.Fatalf("Failed to write: %v", err)
+        }
+    }
+    if out.String() != "using ***\n" {
+        t.Errorf("Expected complete lines to be written redacted, got %q", out.String())
+    }
+    if err := w.Close(); err != nil {
+        t.Fatalf("Failed to close: %v", err)
+    }
+    if out.String() != "using ***\nand ***" {
+        t.Errorf("Expected the last line on close, got %q", out.String())
+    }
+}
@@ -0,0 +1,71 @@
+// Package repo provides access to Git repository locations used by pre-push.
+package repo
+
+import (
+    "context"
+    "fmt"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// stateDirName is the name of the pre-push state directory inside the Git directory
+const stateDirName = "pre-push"
+
+// GitDir returns the absolute path of the Git directory for the current repository
+func GitDir(ctx context.Context) (string, error) {
+    cmd := exec.CommandContext(ctx, "git", "rev-parse", "--absolute-git-dir")
+    output, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("not in a git repository")
+    }
+    
+    gitDir := strings.TrimSpace(string(output))
+    if gitDir == "" {
+        return "", fmt.Errorf("not in a git repository")
+    }
+    
+    return gitDir, nil
+}
+
+// TopLevel returns the absolute path of the working tree root of the current repository
+func TopLevel(ctx context.Context) (string, error) {
+    cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
+    output, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("not in a git working tree")
+    }
+    
+    topLevel := strings.TrimSpace(string(output))
+    if topLevel == "" {
+        return "", fmt.Errorf("not in a git working tree")
+    }
+    
+    return topLevel, nil
+}
+
+// StateDir returns the directory where pre-push keeps its state (.git/pre-push)
+func StateDir(ctx context.Context) (string, error) {
+    gitDir, err := GitDir(ctx)
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(gitDir, stateDirName), nil
+}
+
+// HooksDir returns the absolute path of the directory Git runs hooks from. It
+// follows core.hooksPath, GIT_DIR and the common directory of linked worktrees.
+func HooksDir(ctx context.Context) (string, error) {
+    cmd := exec.CommandContext(ctx, "git", "rev-parse", "--git-path", "hooks")
+    output, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("not in a git repository")
+    }
+    
+    hooksDir := strings.TrimSpace(string(output))
+    if hooksDir == "" {
+        return "", fmt.Errorf("not in a git repository")
+    }
+    
+    return filepath.Abs(hooksDir)
+}
@@ -0,0 +1,150 @@
+package template
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// value is a string, or a list made by split. Lists are rendered joined by
+// spaces. An undefined value is only seen by default.
+type value struct {
+    s       string
+    list    []string
+    isList  bool
+    defined bool
+}
+
+// stringValue returns a defined string value
+func stringValue(s string) value {
+    return value{s: s, defined: true}
+}
+
+// listValue returns a defined list value
+func listValue(list []string) value {
+    return value{list: list, isList: true, defined: true}
+}
+
+// String returns the rendered value
+func (v value) String() string {
+    if v.isList {
+        return strings.Join(v.list, " ")
+    }
+    return v.s
+}
+
+// empty reports whether the value is undefined or empty
+func (v value) empty() bool {
+    if v.isList {
+        return len(v.list) == 0
+    }
+    return !v.defined || v.s == ""
+}
+
+// each applies a string function to a string or to every item of a list
+func (v value) each(fn func(string) string) value {
+    if !v.isList {
+        return stringValue(fn(v.s))
+    }
+    list := make([]string, len(v.list))
+    for i, item := range v.list {
+        list[i] = fn(item)
+    }
+    return listValue(list)
+}
+
+// filterSpec is a filter and the number of arguments it takes
+type filterSpec struct {
+    minArgs, maxArgs int
+    apply            func(v value, args []value) (value, error)
+}
+
+// arity describes the number of arguments of a filter
+func (f filterSpec) arity() string {
+    switch {
+    case f.maxArgs == 0:
+        return "no arguments"
+    case f.minArgs == f.maxArgs:
+        return fmt.Sprintf("%d argument(s)", f.minArgs)
+    default:
+        return fmt.Sprintf("at most %d argument(s)", f.maxArgs)
+    }
+}
+
+// filters are the filters a reference may use
+var filters = map[string]filterSpec{
+    // default("x") replaces an undefined or empty value
+    "default": {1, 1, func(v value, args []value) (value, error) {
+        if v.empty() {
+            return args[0], nil
+        }
+        return v, nil
+    }},
+    "upper": {0, 0, func(v value, args []value) (value, error) {
+        return v.each(strings.ToUpper), nil
+    }},
+    "lower": {0, 0, func(v value, args []value) (value, error) {
+        return v.each(strings.ToLower), nil
+    }},
+    "trim": {0, 0, func(v value, args []value) (value, error) {
+        return v.each(strings.TrimSpace), nil
+    }},
+    // split splits on whitespace, or on its argument
+    "split": {0, 1, func(v value, args []value) (value, error) {
+        if v.isList {
+            return value{}, fmt.Errorf("value is already a list")
+        }
+        if len(args) == 0 {
+            return listValue(strings.Fields(v.s)), nil
+        }
+        if args[0].String() == "" {
+            return value{}, fmt.Errorf("separator is empty")
+        }
+        if v.s == "" {
+            return listValue(nil), nil
+        }
+        return listValue(strings.Split(v.s, args[0].String())), nil
+    }},
+    // join joins a list with spaces, or with its argument
+    "join": {0, 1, func(v value, args []value) (value, error) {
+        if !v.isList {
+            return v, nil
+        }
+        separator := " "
+        if len(args) > 0 {
+            separator = args[0].String()
+        }
+        return stringValue(strings.Join(v.list, separator)), nil
+    }},
+    // shellquote quotes a string, or every item of a list, for a POSIX shell
+    "shellquote": {0, 0, func(v value, args []value) (value, error) {
+        return v.each(shellQuote), nil
+    }},
+}
+
+// filterNames returns the names of the filters in order
+func filterNames() []string {
+    names := make([]string, 0, len(filters))
+    for name := range filters {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// shellQuote quotes a string for a POSIX shell. Strings of safe characters
+// are returned as they are.
+func shellQuote(s string) string {
+    if s == "" {
+        return "''"
+    }
+    safe := true
+    for i := 0; i < len(s) && safe; i++ {
+        c := s[i]
+        safe = isNameByte(c) || strings.IndexByte("@%+=:,/", c) >= 0
+    }
+    if safe {
+        return s
+    }
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
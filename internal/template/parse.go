@@ -0,0 +1,176 @@
+package template
+
+import (
+    "fmt"
+    "strings"
+)
+
+// scanner splits the inside of a reference into tokens: names, quoted
+// strings and the punctuation | ( ) ,
+type scanner struct {
+    source string
+    offset int
+}
+
+// token is a name, a quoted string or punctuation
+type token struct {
+    text   string
+    value  string
+    quoted bool
+}
+
+// next returns the next token, an empty token at the end
+func (s *scanner) next() (token, error) {
+    for s.offset < len(s.source) && strings.ContainsRune(" \t\r\n", rune(s.source[s.offset])) {
+        s.offset++
+    }
+    if s.offset == len(s.source) {
+        return token{}, nil
+    }
+
+    start := s.offset
+    switch c := s.source[start]; {
+    case strings.IndexByte("|(),", c) >= 0:
+        s.offset++
+        return token{text: string(c)}, nil
+    case c == '"' || c == '\'':
+        var b strings.Builder
+        for s.offset++; s.offset < len(s.source); s.offset++ {
+            switch r := s.source[s.offset]; {
+            case r == c:
+                s.offset++
+                return token{text: s.source[start:s.offset], value: b.String(), quoted: true}, nil
+            case r == '\\' && s.offset+1 < len(s.source):
+                s.offset++
+                switch e := s.source[s.offset]; e {
+                case 'n':
+                    b.WriteByte('\n')
+                case 't':
+                    b.WriteByte('\t')
+                case '\\', '"', '\'':
+                    b.WriteByte(e)
+                default:
+                    b.WriteByte('\\')
+                    b.WriteByte(e)
+                }
+            default:
+                b.WriteByte(r)
+            }
+        }
+        return token{}, fmt.Errorf("unterminated string %s", s.source[start:])
+    case isNameByte(c):
+        for s.offset < len(s.source) && isNameByte(s.source[s.offset]) {
+            s.offset++
+        }
+        return token{text: s.source[start:s.offset], value: s.source[start:s.offset]}, nil
+    default:
+        return token{}, fmt.Errorf("unexpected %q", c)
+    }
+}
+
+// isNameByte reports whether c may be part of a variable or filter name
+func isNameByte(c byte) bool {
+    return c == '_' || c == '.' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseReference parses a ${{ operand | filter(args) | ... }} reference
+func parseReference(source string) (*reference, error) {
+    ref := &reference{source: source}
+    s := &scanner{source: source[3 : len(source)-2]}
+    fail := func(format string, args ...any) (*reference, error) {
+        return nil, fmt.Errorf("%s: %s", source, fmt.Sprintf(format, args...))
+    }
+
+    tok, err := s.next()
+    if err != nil {
+        return fail("%v", err)
+    }
+    if tok.text == "" {
+        return fail("empty variable reference")
+    }
+    if ref.operand, err = operandOf(tok); err != nil {
+        return fail("%v", err)
+    }
+
+    for {
+        if tok, err = s.next(); err != nil {
+            return fail("%v", err)
+        }
+        if tok.text == "" {
+            return ref, nil
+        }
+        if tok.text != "|" {
+            return fail("expected | or }}, got %s", tok.text)
+        }
+        if tok, err = s.next(); err != nil {
+            return fail("%v", err)
+        }
+        spec, ok := filters[tok.text]
+        if tok.quoted || !ok {
+            return fail("unknown filter %q, filters are %s", tok.text, strings.Join(filterNames(), ", "))
+        }
+        f := filter{name: tok.text}
+
+        offset := s.offset
+        if tok, err = s.next(); err != nil {
+            return fail("%v", err)
+        }
+        if tok.text == "(" {
+            if f.args, err = parseArgs(s); err != nil {
+                return fail("%s: %v", f.name, err)
+            }
+        } else {
+            s.offset = offset
+        }
+        if len(f.args) < spec.minArgs || len(f.args) > spec.maxArgs {
+            return fail("%s expects %s, got %d", f.name, spec.arity(), len(f.args))
+        }
+        ref.filters = append(ref.filters, f)
+    }
+}
+
+// parseArgs parses the arguments of a filter after the opening (
+func parseArgs(s *scanner) ([]operand, error) {
+    var args []operand
+    for {
+        tok, err := s.next()
+        if err != nil {
+            return nil, err
+        }
+        if tok.text == ")" && len(args) == 0 {
+            return args, nil
+        }
+        arg, err := operandOf(tok)
+        if err != nil {
+            return nil, err
+        }
+        args = append(args, arg)
+
+        if tok, err = s.next(); err != nil {
+            return nil, err
+        }
+        switch tok.text {
+        case ")":
+            return args, nil
+        case ",":
+        case "":
+            return nil, fmt.Errorf("missing )")
+        default:
+            return nil, fmt.Errorf("expected , or ), got %s", tok.text)
+        }
+    }
+}
+
+// operandOf returns the operand a token names
+func operandOf(tok token) (operand, error) {
+    switch {
+    case tok.quoted:
+        return operand{literal: tok.value, quoted: true}, nil
+    case tok.text != "" && isNameByte(tok.text[0]):
+        return operand{name: tok.value}, nil
+    case tok.text == "":
+        return operand{}, fmt.Errorf("expected a variable or a string, got end of reference")
+    default:
+        return operand{}, fmt.Errorf("expected a variable or a string, got %s", tok.text)
+    }
+}
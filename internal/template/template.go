@@ -0,0 +1,220 @@
+// Package template renders the ${{ }} references of configuration strings.
+// A reference names a variable or a quoted literal, optionally followed by
+// filters:
+//
+//	${{ tag }}
+//	${{ env.TARGET | default("linux") | upper }}
+//	${{ staged_files | split | shellquote }}
+//
+// $${{ is written as a literal ${{. Values are inserted once and never
+// rendered again, so a value containing ${{ stays as it is.
+package template
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Mode decides what happens to references of undefined variables
+type Mode int
+
+const (
+    Strict  Mode = iota // An undefined variable is an error
+    Lenient             // An undefined variable is empty
+)
+
+// Template is a parsed string
+type Template struct {
+    source string
+    parts  []part
+}
+
+// part is a literal text or a reference
+type part struct {
+    text string
+    ref  *reference
+}
+
+// reference is a ${{ }} reference: an operand followed by filters
+type reference struct {
+    source  string // Source of the reference including ${{ }}
+    operand operand
+    filters []filter
+}
+
+// operand is a variable name or a quoted literal
+type operand struct {
+    name    string
+    literal string
+    quoted  bool
+}
+
+// filter is a filter with its arguments
+type filter struct {
+    name string
+    args []operand
+}
+
+// Parse parses a string. Errors name the offending reference.
+func Parse(source string) (*Template, error) {
+    t := &Template{source: source}
+    text := strings.Builder{}
+    rest := source
+    for {
+        start := strings.Index(rest, "${{")
+        if start == -1 {
+            text.WriteString(rest)
+            break
+        }
+        if start > 0 && rest[start-1] == '$' {
+            // $${{ is a literal ${{
+            text.WriteString(rest[:start-1])
+            text.WriteString("${{")
+            rest = rest[start+3:]
+            continue
+        }
+        text.WriteString(rest[:start])
+
+        end, err := referenceEnd(rest[start:])
+        if err != nil {
+            return nil, err
+        }
+        ref, err := parseReference(rest[start : start+end])
+        if err != nil {
+            return nil, err
+        }
+        if text.Len() > 0 {
+            t.parts = append(t.parts, part{text: text.String()})
+            text.Reset()
+        }
+        t.parts = append(t.parts, part{ref: ref})
+        rest = rest[start+end:]
+    }
+    if text.Len() > 0 {
+        t.parts = append(t.parts, part{text: text.String()})
+    }
+    return t, nil
+}
+
+// referenceEnd returns the length of the reference at the start of s, up to
+// and including the }} that closes it outside of quotes
+func referenceEnd(s string) (int, error) {
+    var quote byte
+    for i := 3; i < len(s); i++ {
+        switch c := s[i]; {
+        case quote != 0 && c == '\\':
+            i++
+        case quote != 0:
+            if c == quote {
+                quote = 0
+            }
+        case c == '"' || c == '\'':
+            quote = c
+        case c == '}' && i+1 < len(s) && s[i+1] == '}':
+            return i + 2, nil
+        }
+    }
+    if quote != 0 {
+        return 0, fmt.Errorf("unterminated string in %s", s)
+    }
+    return 0, fmt.Errorf("unclosed variable reference: %s", s)
+}
+
+// Render parses and executes a string
+func Render(source string, variables map[string]string, mode Mode) (string, error) {
+    t, err := Parse(source)
+    if err != nil {
+        return "", err
+    }
+    return t.Execute(variables, mode)
+}
+
+// Execute renders the template with the variables
+func (t *Template) Execute(variables map[string]string, mode Mode) (string, error) {
+    var b strings.Builder
+    for _, p := range t.parts {
+        if p.ref == nil {
+            b.WriteString(p.text)
+            continue
+        }
+        value, err := p.ref.eval(variables, mode)
+        if err != nil {
+            return "", fmt.Errorf("%s: %w", p.ref.source, err)
+        }
+        b.WriteString(value.String())
+    }
+    return b.String(), nil
+}
+
+// Variables returns the names of the variables the template references
+func (t *Template) Variables() []string {
+    var names []string
+    add := func(o operand) {
+        if !o.quoted {
+            names = append(names, o.name)
+        }
+    }
+    for _, p := range t.parts {
+        if p.ref == nil {
+            continue
+        }
+        add(p.ref.operand)
+        for _, f := range p.ref.filters {
+            for _, arg := range f.args {
+                add(arg)
+            }
+        }
+    }
+    return names
+}
+
+// String returns the source of the template
+func (t *Template) String() string {
+    return t.source
+}
+
+// eval evaluates a reference
+func (r *reference) eval(variables map[string]string, mode Mode) (value, error) {
+    v := r.operand.eval(variables)
+    for _, f := range r.filters {
+        if !v.defined && f.name != "default" {
+            if mode == Strict {
+                return value{}, fmt.Errorf("undefined variable: %s", r.operand.name)
+            }
+            v = stringValue("")
+        }
+        args := make([]value, len(f.args))
+        for i, arg := range f.args {
+            args[i] = arg.eval(variables)
+            if !args[i].defined {
+                if mode == Strict {
+                    return value{}, fmt.Errorf("undefined variable: %s", arg.name)
+                }
+                args[i] = stringValue("")
+            }
+        }
+        var err error
+        if v, err = filters[f.name].apply(v, args); err != nil {
+            return value{}, fmt.Errorf("%s: %w", f.name, err)
+        }
+    }
+    if !v.defined {
+        if mode == Strict {
+            return value{}, fmt.Errorf("undefined variable: %s", r.operand.name)
+        }
+        v = stringValue("")
+    }
+    return v, nil
+}
+
+// eval returns the value of an operand
+func (o operand) eval(variables map[string]string) value {
+    if o.quoted {
+        return stringValue(o.literal)
+    }
+    s, ok := variables[o.name]
+    if !ok {
+        return value{}
+    }
+    return stringValue(s)
+}
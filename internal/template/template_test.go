@@ -0,0 +1,88 @@
+package template
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestRender(t *testing.T) {
+    variables := map[string]string{
+        "tag":          "v1.2.0",
+        "branch":       "main",
+        "empty":        "",
+        "staged_files": "a.go  it's.go\tdir/b c.go",
+        "modules":      "pre-push,buildfab",
+        "loop":         "${{ loop }}",
+    }
+
+    tests := []struct {
+        source   string
+        expected string
+    }{
+        {"echo ${{ tag }} on ${{branch}}", "echo v1.2.0 on main"},
+        {`${{ env.TARGET | default("linux") }}`, "linux"},
+        {`${{ empty | default('none') }}`, "none"},
+        {`${{ tag | default("x") }}`, "v1.2.0"},
+        {`${{ missing | default(branch) | upper }}`, "MAIN"},
+        {`${{ "  Mixed  " | trim | lower }}`, "mixed"},
+        {`${{ modules | split(",") | join(";") }}`, "pre-push;buildfab"},
+        {`${{ modules | split(",") | upper }}`, "PRE-PUSH BUILDFAB"},
+        {`gofmt -l ${{ staged_files | split | shellquote }}`, `gofmt -l a.go 'it'\''s.go' dir/b c.go`},
+        {`${{ empty | shellquote }}`, "''"},
+        {`echo $${{ tag }}`, "echo ${{ tag }}"},
+        {`${{ "}}" }}`, "}}"},
+        {`${{ loop }}`, "${{ loop }}"},
+    }
+    for _, test := range tests {
+        result, err := Render(test.source, variables, Strict)
+        if err != nil {
+            t.Errorf("Failed to render %s: %v", test.source, err)
+            continue
+        }
+        if result != test.expected {
+            t.Errorf("Expected %s to render %q, got %q", test.source, test.expected, result)
+        }
+    }
+}
+
+func TestRenderUndefined(t *testing.T) {
+    if _, err := Render("echo ${{ missing | upper }}", nil, Strict); err == nil || !strings.Contains(err.Error(), "undefined variable: missing") {
+        t.Errorf("Expected an undefined variable error, got %v", err)
+    }
+
+    result, err := Render("echo [${{ missing | upper }}] [${{ missing }}]", nil, Lenient)
+    if err != nil || result != "echo [] []" {
+        t.Errorf("Expected undefined variables to be empty, got %q, %v", result, err)
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        source  string
+        message string
+    }{
+        {"echo ${{ tag ", "unclosed variable reference"},
+        {`${{ "tag }}`, "unterminated string"},
+        {"${{ }}", "empty variable reference"},
+        {"${{ tag | uper }}", `unknown filter "uper"`},
+        {"${{ tag | default }}", "default expects 1 argument(s), got 0"},
+        {"${{ tag | upper(1) }}", "upper expects no arguments, got 1"},
+        {"${{ tag branch }}", "expected | or }}, got branch"},
+        {`${{ tag | default("a" }}`, "missing )"},
+    }
+    for _, test := range tests {
+        if _, err := Parse(test.source); err == nil || !strings.Contains(err.Error(), test.message) {
+            t.Errorf("Expected %q for %s, got %v", test.message, test.source, err)
+        }
+    }
+}
+
+func TestVariables(t *testing.T) {
+    tmpl, err := Parse(`${{ tag }} ${{ env.X | default(branch) }} ${{ "lit" }}`)
+    if err != nil {
+        t.Fatalf("Failed to parse: %v", err)
+    }
+    if names := strings.Join(tmpl.Variables(), " "); names != "tag env.X branch" {
+        t.Errorf("Expected tag env.X branch, got %s", names)
+    }
+}
@@ -98,6 +98,11 @@ func (u *UI) PrintProjectCheck(projectName, version string) {
     u.Printf("Checking \033[1m%s\033[0m (\033[1m%s\033[0m) before push\n", projectName, version)
 }
 
+// PrintConfigFile prints the configuration file the checks are loaded from
+func (u *UI) PrintConfigFile(path string) {
+    u.Printf("\033[90mUsing configuration %s\033[0m\n", path)
+}
+
 // PrintStageHeader prints the header for a stage
 func (u *UI) PrintStageHeader(stageName string) {
     u.Printf("\n\033[36m🚀 Running stage: %s\033[0m\n", stageName)
@@ -156,6 +161,23 @@ func (u *UI) PrintCommandOutput(output string) {
     }
 }
 
+// PrintOutputTail prints the last lines of a failed step's output and the path to its full log
+func (u *UI) PrintOutputTail(stepName string, lines []string, omitted int, logPath string) {
+    if len(lines) == 0 && logPath == "" {
+        return
+    }
+
+    if omitted > 0 {
+        u.Printf("\033[90m   ... %d earlier lines of %s output omitted\033[0m\n", omitted, stepName)
+    }
+    for _, line := range lines {
+        u.Printf("   │ %s\n", line)
+    }
+    if logPath != "" {
+        u.Printf("\033[90m   Full log: %s\033[0m\n", logPath)
+    }
+}
+
 // PrintRepro prints reproduction instructions for a failed step
 func (u *UI) PrintRepro(stepName, repro string) {
     u.Printf("\n🔍 To reproduce %s:\n", stepName)
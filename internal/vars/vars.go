@@ -0,0 +1,300 @@
+// Package vars provides the variables of configurations and conditions:
+// platform, version, Git, pushed refs, Git hook input and environment.
+// It is the only producer of variables, the executor, the config package
+// and 'pre-push vars' all use it.
+package vars
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strings"
+
+    "github.com/AlexBurnes/buildfab/pkg/buildfab"
+    "github.com/AlexBurnes/pre-push/internal/mask"
+    "github.com/AlexBurnes/pre-push/internal/version"
+)
+
+// Source is where a variable comes from
+type Source string
+
+const (
+    SourcePlatform Source = "platform" // buildfab and Go runtime platform detection
+    SourceVersion  Source = "version"  // version-go and the project configuration
+    SourceGit      Source = "git"      // The current tag and branch of the repository
+    SourcePush     Source = "push"     // The pushed tags and branches
+    SourceHook     Source = "hook"     // The input of the Git hook
+    SourceEnv      Source = "env"      // The environment
+)
+
+// Variable is a variable with its value and source
+type Variable struct {
+    Name   string `json:"name"`
+    Value  string `json:"value"`
+    Source Source `json:"source"`
+    Secret bool   `json:"secret,omitempty"`
+}
+
+// Push describes the refs of a push
+type Push struct {
+    Tags     []string
+    Branches []string
+}
+
+// Provider collects the variables. The zero value collects the variables
+// outside of a push and a hook.
+type Provider struct {
+    Push    *Push             // Pushed refs, nil outside of a push
+    Hook    map[string]string // Variables of the Git hook input
+    Environ []string          // Environment as KEY=value, os.Environ() if nil
+    Mask    *mask.Masker      // Decides which variables are secret, mask.New() if nil
+}
+
+// Separator separates the items of list variables such as modules, tags
+// and branches
+const Separator = ","
+
+// Collect returns the variables sorted by name. A later source overrides
+// an earlier one: platform, version, Git or push, hook, environment and
+// the buildfab version variables.
+func (p *Provider) Collect(ctx context.Context) []Variable {
+    set := map[string]Variable{}
+    add := func(source Source, name, value string) {
+        set[name] = Variable{Name: name, Value: value, Source: source}
+    }
+
+    // Platform
+    for _, v := range Platform() {
+        set[v.Name] = v
+    }
+
+    // Version
+    if info, err := version.GetVersionInfo(ctx); err == nil && info != nil {
+        if info.Version != "" {
+            add(SourceVersion, "version", info.Version)
+            add(SourceVersion, "version.version", info.Version)
+        }
+        if info.Project != "" {
+            add(SourceVersion, "project", info.Project)
+            add(SourceVersion, "version.project", info.Project)
+        }
+        if info.Module != "" {
+            add(SourceVersion, "module", info.Module)
+            add(SourceVersion, "version.module", info.Module)
+        }
+        if len(info.Modules) > 0 {
+            add(SourceVersion, "modules", strings.Join(info.Modules, Separator))
+            add(SourceVersion, "version.modules", strings.Join(info.Modules, Separator))
+        }
+        if info.BuildType != "" {
+            add(SourceVersion, "version.build-type", info.BuildType)
+        }
+        if info.VersionType != "" {
+            add(SourceVersion, "version.version-type", info.VersionType)
+        }
+    }
+
+    // Git, or the pushed refs
+    detector := version.New()
+    tag, tagErr := detector.DetectCurrentVersion(ctx)
+    branch, branchErr := detector.DetectCurrentBranch(ctx)
+    if p.Push != nil {
+        if len(p.Push.Tags) == 1 {
+            add(SourcePush, "tag", p.Push.Tags[0])
+        }
+        if len(p.Push.Tags) > 0 {
+            add(SourcePush, "tags", strings.Join(p.Push.Tags, Separator))
+        }
+        if len(p.Push.Branches) == 1 {
+            add(SourcePush, "branch", p.Push.Branches[0])
+        }
+        if len(p.Push.Branches) > 0 {
+            add(SourcePush, "branches", strings.Join(p.Push.Branches, Separator))
+        }
+        if branchErr == nil {
+            add(SourceGit, "version.branch", branch)
+        }
+        if tagErr == nil {
+            add(SourceGit, "version.tag", tag)
+        }
+    } else {
+        if tagErr == nil {
+            add(SourceGit, "tag", tag)
+        }
+        if branchErr == nil {
+            add(SourceGit, "branch", branch)
+        }
+    }
+
+    // Git hook input
+    for name, value := range p.Hook {
+        add(SourceHook, name, value)
+    }
+
+    // Environment
+    for _, v := range Environment(p.Environ) {
+        set[v.Name] = v
+    }
+
+    // buildfab version variables: version.major, version.rawversion, ...
+    for name, value := range buildfab.AddVersionVariables(nil) {
+        add(SourceVersion, name, value)
+    }
+
+    masker := p.Mask
+    if masker == nil {
+        masker = mask.New()
+    }
+    variables := make([]Variable, 0, len(set))
+    for _, v := range set {
+        v.Secret = masker.IsSecret(v.Name)
+        variables = append(variables, v)
+    }
+    return sorted(variables)
+}
+
+// Platform returns the platform variables
+func Platform() []Variable {
+    var variables []Variable
+    add := func(name, value string) {
+        variables = append(variables, Variable{Name: name, Value: value, Source: SourcePlatform})
+    }
+    if platform := buildfab.GetPlatformVariables(); platform != nil {
+        add("platform", platform.Platform)
+        add("arch", platform.Arch)
+        add("os", platform.OS)
+        add("os_version", platform.OSVersion)
+        add("cpu", fmt.Sprintf("%d", platform.CPU))
+    }
+    add("platform.os", runtime.GOOS)
+    add("platform.arch", runtime.GOARCH)
+    add("platform.goos", runtime.GOOS)
+    add("platform.goarch", runtime.GOARCH)
+    add("platform.go", runtime.Version())
+    add("platform.os.name", osName())
+    add("platform.arch.name", archName())
+    add("platform.shell", shellName())
+    return sorted(variables)
+}
+
+// Environment returns the environment variables as env.KEY, with the case
+// of the keys kept. environ is os.Environ() if nil.
+func Environment(environ []string) []Variable {
+    if environ == nil {
+        environ = os.Environ()
+    }
+    set := map[string]Variable{}
+    for _, entry := range environ {
+        if key, value, ok := strings.Cut(entry, "="); ok && key != "" {
+            set["env."+key] = Variable{Name: "env." + key, Value: value, Source: SourceEnv}
+        }
+    }
+    variables := make([]Variable, 0, len(set))
+    for _, v := range set {
+        variables = append(variables, v)
+    }
+    return sorted(variables)
+}
+
+// sorted sorts variables by name
+func sorted(variables []Variable) []Variable {
+    sort.Slice(variables, func(i, j int) bool {
+        return variables[i].Name < variables[j].Name
+    })
+    return variables
+}
+
+// Map returns the variables as a map of name to value
+func Map(variables []Variable) map[string]string {
+    result := make(map[string]string, len(variables))
+    for _, v := range variables {
+        result[v.Name] = v.Value
+    }
+    return result
+}
+
+// FromSource returns the variables of the given sources
+func FromSource(variables []Variable, sources ...Source) []Variable {
+    var result []Variable
+    for _, v := range variables {
+        for _, source := range sources {
+            if v.Source == source {
+                result = append(result, v)
+                break
+            }
+        }
+    }
+    return result
+}
+
+// Filter returns the variables whose names start with the prefix
+func Filter(variables []Variable, prefix string) []Variable {
+    var result []Variable
+    for _, v := range variables {
+        if strings.HasPrefix(v.Name, prefix) {
+            result = append(result, v)
+        }
+    }
+    return result
+}
+
+// Masked returns the value of a variable, or mask.Mask for a non-empty secret
+func (v Variable) Masked() string {
+    if v.Secret && v.Value != "" {
+        return mask.Mask
+    }
+    return v.Value
+}
+
+// osName returns the name of the operating system, the distribution on Linux
+func osName() string {
+    switch runtime.GOOS {
+    case "linux":
+        if data, err := os.ReadFile("/etc/os-release"); err == nil {
+            for _, line := range strings.Split(string(data), "\n") {
+                if name, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+                    return strings.Trim(name, "\"")
+                }
+            }
+        }
+        return "Linux"
+    case "darwin":
+        return "macOS"
+    case "windows":
+        return "Windows"
+    default:
+        return runtime.GOOS
+    }
+}
+
+// archName returns the conventional name of the architecture
+func archName() string {
+    switch runtime.GOARCH {
+    case "amd64":
+        return "x86_64"
+    case "arm64":
+        return "aarch64"
+    case "386":
+        return "i386"
+    default:
+        return runtime.GOARCH
+    }
+}
+
+// shellName returns the name of the shell of the user
+func shellName() string {
+    if shell := os.Getenv("SHELL"); shell != "" {
+        return filepath.Base(shell)
+    }
+    switch runtime.GOOS {
+    case "windows":
+        return "cmd"
+    case "darwin":
+        return "zsh"
+    default:
+        return "bash"
+    }
+}
@@ -0,0 +1,73 @@
+package vars
+
+import (
+    "context"
+    "testing"
+
+    "github.com/AlexBurnes/pre-push/internal/mask"
+)
+
+func TestCollect(t *testing.T) {
+    provider := &Provider{
+        Push:    &Push{Tags: []string{"v1.2.0"}, Branches: []string{"main", "dev"}},
+        Hook:    map[string]string{"hook": "pre-push", "staged_files": "a.go b.go"},
+        Environ: []string{"CI=true", "Home_Dir=/home/user", "GITHUB_TOKEN=secret"},
+    }
+    variables := provider.Collect(context.Background())
+
+    byName := map[string]Variable{}
+    for i, v := range variables {
+        if i > 0 && variables[i-1].Name >= v.Name {
+            t.Errorf("Expected variables sorted by name, got %s before %s", variables[i-1].Name, v.Name)
+        }
+        byName[v.Name] = v
+    }
+
+    expected := []Variable{
+        {Name: "tag", Value: "v1.2.0", Source: SourcePush},
+        {Name: "tags", Value: "v1.2.0", Source: SourcePush},
+        {Name: "branches", Value: "main,dev", Source: SourcePush},
+        {Name: "hook", Value: "pre-push", Source: SourceHook},
+        {Name: "staged_files", Value: "a.go b.go", Source: SourceHook},
+        {Name: "env.CI", Value: "true", Source: SourceEnv},
+        {Name: "env.Home_Dir", Value: "/home/user", Source: SourceEnv},
+        {Name: "env.GITHUB_TOKEN", Value: "secret", Source: SourceEnv, Secret: true},
+    }
+    for _, want := range expected {
+        if got := byName[want.Name]; got != want {
+            t.Errorf("Expected %+v, got %+v", want, got)
+        }
+    }
+    if _, exists := byName["branch"]; exists {
+        t.Error("Expected no branch variable for a push of two branches")
+    }
+    if _, exists := byName["env.ci"]; exists {
+        t.Error("Expected environment keys to keep their case")
+    }
+    if v := byName["platform"]; v.Source != SourcePlatform || v.Value == "" {
+        t.Errorf("Expected the platform variable, got %+v", v)
+    }
+
+    if env := FromSource(variables, SourceEnv); len(env) != 3 {
+        t.Errorf("Expected 3 environment variables, got %v", env)
+    }
+    if hook := Filter(variables, "hook"); len(hook) != 1 || hook[0].Name != "hook" {
+        t.Errorf("Expected the hook variable, got %v", hook)
+    }
+}
+
+func TestCollectSecrets(t *testing.T) {
+    provider := &Provider{
+        Environ: []string{"API_TOKEN=abcdef", "DEPLOY_KEY=key", "HOME=/home/user"},
+        Mask:    mask.New("DEPLOY_KEY"),
+    }
+    for _, v := range FromSource(provider.Collect(context.Background()), SourceEnv) {
+        expected := v.Name != "env.HOME"
+        if v.Secret != expected {
+            t.Errorf("Expected %s to be secret %v", v.Name, expected)
+        }
+        if expected && v.Masked() != mask.Mask {
+            t.Errorf("Expected %s to be masked, got %s", v.Name, v.Masked())
+        }
+    }
+}
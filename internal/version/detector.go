@@ -5,6 +5,7 @@ import (
     "context"
     "fmt"
     "os/exec"
+    "strconv"
     "strings"
 )
 
@@ -76,20 +77,106 @@ func (d *Detector) ValidateVersion(version string) error {
     return nil
 }
 
-// CompareVersions compares two version strings
+// CompareVersions compares two semantic versions and returns -1, 0 or 1.
+// Build metadata is ignored and a prerelease sorts before its release.
 func (d *Detector) CompareVersions(v1, v2 string) (int, error) {
-    // Remove 'v' prefix for comparison
-    v1 = strings.TrimPrefix(v1, "v")
-    v2 = strings.TrimPrefix(v2, "v")
-    
-    // Simple string comparison for now
-    // TODO: Implement proper semantic version comparison
-    if v1 == v2 {
-        return 0, nil
-    } else if v1 < v2 {
-        return -1, nil
-    } else {
-        return 1, nil
+    a, err := parseSemver(v1)
+    if err != nil {
+        return 0, err
+    }
+    b, err := parseSemver(v2)
+    if err != nil {
+        return 0, err
+    }
+    
+    for i := 0; i < 3; i++ {
+        if a.core[i] != b.core[i] {
+            if a.core[i] < b.core[i] {
+                return -1, nil
+            }
+            return 1, nil
+        }
+    }
+    
+    return comparePrerelease(a.prerelease, b.prerelease), nil
+}
+
+// semver represents a parsed semantic version
+type semver struct {
+    core       [3]int
+    prerelease []string
+}
+
+// parseSemver parses a version like v1.2.3-rc.1+build
+func parseSemver(version string) (semver, error) {
+    var result semver
+    
+    s := strings.TrimPrefix(version, "v")
+    if i := strings.IndexByte(s, '+'); i >= 0 {
+        s = s[:i]
+    }
+    if i := strings.IndexByte(s, '-'); i >= 0 {
+        result.prerelease = strings.Split(s[i+1:], ".")
+        s = s[:i]
+    }
+    
+    parts := strings.Split(s, ".")
+    if len(parts) == 0 || len(parts) > 3 {
+        return semver{}, fmt.Errorf("invalid semantic version: %s", version)
+    }
+    for i, part := range parts {
+        n, err := strconv.Atoi(part)
+        if err != nil || n < 0 {
+            return semver{}, fmt.Errorf("invalid semantic version: %s", version)
+        }
+        result.core[i] = n
+    }
+    
+    return result, nil
+}
+
+// comparePrerelease compares prerelease identifiers by semantic versioning precedence
+func comparePrerelease(a, b []string) int {
+    switch {
+    case len(a) == 0 && len(b) == 0:
+        return 0
+    case len(a) == 0:
+        return 1
+    case len(b) == 0:
+        return -1
+    }
+    
+    for i := 0; i < len(a) && i < len(b); i++ {
+        if a[i] == b[i] {
+            continue
+        }
+        na, errA := strconv.Atoi(a[i])
+        nb, errB := strconv.Atoi(b[i])
+        switch {
+        case errA == nil && errB == nil:
+            if na < nb {
+                return -1
+            }
+            return 1
+        case errA == nil:
+            // Numeric identifiers sort before alphanumeric ones
+            return -1
+        case errB == nil:
+            return 1
+        case a[i] < b[i]:
+            return -1
+        default:
+            return 1
+        }
+    }
+    
+    switch {
+    case len(a) < len(b):
+        return -1
+    case len(a) > len(b):
+        return 1
+    default:
+        return 0
     }
 }
 
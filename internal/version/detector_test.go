@@ -0,0 +1,37 @@
+package version
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+    detector := New()
+    
+    tests := []struct {
+        v1, v2   string
+        expected int
+    }{
+        {"v1.2.3", "v1.2.3", 0},
+        {"v1.2.3", "v1.10.0", -1},
+        {"v2.0.0", "v1.99.99", 1},
+        {"v1.0.0-rc.1", "v1.0.0", -1},
+        {"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+        {"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+        {"v1.0.0-1", "v1.0.0-alpha", -1},
+        {"v1.0.0+build.1", "v1.0.0+build.2", 0},
+        {"1.2", "v1.2.0", 0},
+    }
+    
+    for _, test := range tests {
+        result, err := detector.CompareVersions(test.v1, test.v2)
+        if err != nil {
+            t.Errorf("CompareVersions(%s, %s) failed: %v", test.v1, test.v2, err)
+            continue
+        }
+        if result != test.expected {
+            t.Errorf("CompareVersions(%s, %s) = %d, expected %d", test.v1, test.v2, result, test.expected)
+        }
+    }
+    
+    if _, err := detector.CompareVersions("unknown", "v1.0.0"); err == nil {
+        t.Error("Expected error for invalid version")
+    }
+}
@@ -6,10 +6,19 @@ package prepush
 import (
     "context"
     "fmt"
+
+    "github.com/AlexBurnes/pre-push/internal/expr"
 )
 
+// SchemaVersion is the configuration schema version of this release.
+// Configurations without a schema field are version 1; 'pre-push config
+// migrate' rewrites them to this version.
+const SchemaVersion = 2
+
 // Config represents the pre-push configuration
 type Config struct {
+    Schema int `yaml:"schema,omitempty"` // Configuration schema version, 0 means 1
+    
     Project struct {
         Name    string   `yaml:"name"`
         Modules []string `yaml:"modules"`
@@ -31,8 +40,8 @@ type Action struct {
 // Stage represents a collection of steps to execute
 type Stage struct {
     Steps   []Step `yaml:"steps"`
-    Verbose bool   `yaml:"verbose,omitempty"`
-    Debug   bool   `yaml:"debug,omitempty"`
+    Verbose bool   `yaml:"verbose,omitempty"` // Deprecated: schema 1 only, use --verbose
+    Debug   bool   `yaml:"debug,omitempty"`   // Deprecated: schema 1 only, use --debug
 }
 
 // Step represents a single step in a stage
@@ -84,6 +93,26 @@ func (s Status) String() string {
     }
 }
 
+// ParseStatus parses the string representation of a status
+func ParseStatus(s string) (Status, error) {
+    switch s {
+    case "PENDING":
+        return StatusPending, nil
+    case "RUNNING":
+        return StatusRunning, nil
+    case "OK":
+        return StatusOK, nil
+    case "WARN":
+        return StatusWarn, nil
+    case "ERROR":
+        return StatusError, nil
+    case "SKIPPED":
+        return StatusSkipped, nil
+    default:
+        return StatusPending, fmt.Errorf("unknown status: %s", s)
+    }
+}
+
 // Executor defines the interface for executing pre-push checks
 type Executor interface {
     // RunStage executes a specific stage
@@ -117,6 +146,10 @@ func NewConfig() *Config {
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
+    if c.Schema > SchemaVersion {
+        return fmt.Errorf("configuration schema %d is newer than the supported schema %d, update pre-push", c.Schema, SchemaVersion)
+    }
+    
     if c.Project.Name == "" {
         return fmt.Errorf("project name is required")
     }
@@ -165,11 +198,9 @@ func (c *Config) Validate() error {
                 return fmt.Errorf("step %d in stage %s has invalid onerror value: %s (must be 'stop' or 'warn')", i+1, stageName, step.OnError)
             }
             
-            // Validate only field contains valid values
-            for _, onlyValue := range step.Only {
-                if onlyValue != "release" && onlyValue != "prerelease" && onlyValue != "patch" && onlyValue != "minor" && onlyValue != "major" {
-                    return fmt.Errorf("step %d in stage %s has invalid only value: %s (must be 'release', 'prerelease', 'patch', 'minor', or 'major')", i+1, stageName, onlyValue)
-                }
+            // Conditions are type checked, only entries are version types or expressions
+            if _, err := expr.CompileCondition(step.If, step.Only, expr.StepVariables(nil)); err != nil {
+                return fmt.Errorf("step %d in stage %s has an invalid condition: %w", i+1, stageName, err)
             }
         }
     }